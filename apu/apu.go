@@ -0,0 +1,300 @@
+package apu
+
+import "github.com/duyquang6/go-retroid/mmu"
+
+const (
+	CLOCK_HZ = 4194304
+	// the frame sequencer clocks length, sweep and envelope units at 512Hz
+	FRAME_SEQUENCER_CYCLES = 8192
+)
+
+const (
+	NR10 uint16 = 0xFF10
+	NR11 uint16 = 0xFF11
+	NR12 uint16 = 0xFF12
+	NR13 uint16 = 0xFF13
+	NR14 uint16 = 0xFF14
+	NR21 uint16 = 0xFF16
+	NR22 uint16 = 0xFF17
+	NR23 uint16 = 0xFF18
+	NR24 uint16 = 0xFF19
+	NR30 uint16 = 0xFF1A
+	NR31 uint16 = 0xFF1B
+	NR32 uint16 = 0xFF1C
+	NR33 uint16 = 0xFF1D
+	NR34 uint16 = 0xFF1E
+	NR41 uint16 = 0xFF20
+	NR42 uint16 = 0xFF21
+	NR43 uint16 = 0xFF22
+	NR44 uint16 = 0xFF23
+	NR50 uint16 = 0xFF24
+	NR51 uint16 = 0xFF25
+	NR52 uint16 = 0xFF26
+
+	WAVE_RAM uint16 = 0xFF30
+)
+
+// bits that always read back as 1, indexed from NR10
+var readMasks = [0x20]byte{
+	0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10-NR14
+	0xFF, 0x3F, 0x00, 0xFF, 0xBF, // NR20-NR24
+	0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30-NR34
+	0xFF, 0xFF, 0x00, 0x00, 0xBF, // NR40-NR44
+	0x00, 0x00, 0x70, // NR50-NR52
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+}
+
+type APU struct {
+	mem *mmu.Memory
+
+	// 0xFF10-0xFF3F as last written
+	regs    [0x30]byte
+	enabled bool
+
+	ch1, ch2 pulse
+	ch3      wave
+	ch4      noise
+
+	seqClock int
+	seqStep  int
+
+	// interleaved stereo output at sampleRate, 0 disables sampling;
+	// sampleClock counts the cycles towards the next native sample
+	sampleRate  int
+	sampleClock int
+	resample    resampler
+	buffer      []float32
+}
+
+func New(mem *mmu.Memory) *APU {
+	a := &APU{mem: mem}
+	a.ch1.hasSweep = true
+	a.ch3.ram = a.regs[0x20:]
+	for addr := NR10; addr < 0xFF40; addr++ {
+		mem.MapIO(addr, func() byte { return a.read(addr) }, func(v byte) { a.write(addr, v) })
+	}
+	return a
+}
+
+// SetSampleRate sets the rate of the samples produced, 0 stops sampling.
+// The native output is resampled to it, see resampler.
+func (a *APU) SetSampleRate(hz int) {
+	a.sampleRate = hz
+	a.sampleClock = 0
+	if hz > 0 {
+		a.resample = newResampler(hz)
+	}
+}
+
+func (a *APU) SampleRate() int {
+	return a.sampleRate
+}
+
+// Samples drains the interleaved stereo samples produced so far.
+func (a *APU) Samples() []float32 {
+	samples := a.buffer
+	a.buffer = nil
+	return samples
+}
+
+func (a *APU) Step(cycles int) {
+	for cycles > 0 {
+		n := min(cycles, FRAME_SEQUENCER_CYCLES-a.seqClock)
+		if a.sampleRate > 0 {
+			// cycles until the next native sample
+			n = min(n, NATIVE_CYCLES-a.sampleClock)
+		}
+		cycles -= n
+
+		if a.enabled {
+			a.ch1.step(n)
+			a.ch2.step(n)
+			a.ch3.step(n)
+			a.ch4.step(n)
+		}
+
+		a.seqClock += n
+		if a.seqClock >= FRAME_SEQUENCER_CYCLES {
+			a.seqClock -= FRAME_SEQUENCER_CYCLES
+			a.clockFrameSequencer()
+		}
+
+		if a.sampleRate > 0 {
+			a.sampleClock += n
+			if a.sampleClock == NATIVE_CYCLES {
+				a.sampleClock = 0
+				l, r := a.mix()
+				a.buffer = a.resample.push(a.buffer, l, r)
+			}
+		}
+	}
+}
+
+func (a *APU) clockFrameSequencer() {
+	if !a.enabled {
+		return
+	}
+	if a.seqStep%2 == 0 {
+		a.ch1.enabled = a.ch1.length.clock() && a.ch1.enabled
+		a.ch2.enabled = a.ch2.length.clock() && a.ch2.enabled
+		a.ch3.enabled = a.ch3.length.clock() && a.ch3.enabled
+		a.ch4.enabled = a.ch4.length.clock() && a.ch4.enabled
+	}
+	if a.seqStep == 2 || a.seqStep == 6 {
+		a.ch1.clockSweep()
+	}
+	if a.seqStep == 7 {
+		a.ch1.env.clock()
+		a.ch2.env.clock()
+		a.ch4.env.clock()
+	}
+	a.seqStep = (a.seqStep + 1) & 7
+}
+
+// mix converts the channel outputs through their DACs and applies the
+// NR51 panning and NR50 master volume.
+func (a *APU) mix() (left, right float32) {
+	if !a.enabled {
+		return 0, 0
+	}
+	outputs := [4]float32{
+		dac(a.ch1.output(), a.ch1.enabled),
+		dac(a.ch2.output(), a.ch2.enabled),
+		dac(a.ch3.output(), a.ch3.enabled),
+		dac(a.ch4.output(), a.ch4.enabled),
+	}
+
+	nr51, nr50 := a.regs[NR51-NR10], a.regs[NR50-NR10]
+	for i, out := range outputs {
+		if nr51&(0x10<<i) != 0 {
+			left += out
+		}
+		if nr51&(0x01<<i) != 0 {
+			right += out
+		}
+	}
+	left *= float32((nr50>>4)&0x07+1) / 8 / 4
+	right *= float32(nr50&0x07+1) / 8 / 4
+	return left, right
+}
+
+func dac(digital int, on bool) float32 {
+	if !on {
+		return 0
+	}
+	return float32(digital)/7.5 - 1
+}
+
+func (a *APU) read(addr uint16) byte {
+	offset := addr - NR10
+	switch {
+	case addr == NR52:
+		status := readMasks[offset]
+		if a.enabled {
+			status |= 0x80
+		}
+		for i, on := range []bool{a.ch1.enabled, a.ch2.enabled, a.ch3.enabled, a.ch4.enabled} {
+			if on {
+				status |= 1 << i
+			}
+		}
+		return status
+	case addr >= WAVE_RAM:
+		return a.regs[offset]
+	}
+	return a.regs[offset] | readMasks[offset]
+}
+
+func (a *APU) write(addr uint16, value byte) {
+	offset := addr - NR10
+	if addr >= WAVE_RAM {
+		a.regs[offset] = value
+		return
+	}
+	if addr == NR52 {
+		a.setPower(value&0x80 != 0)
+		return
+	}
+	if !a.enabled {
+		// registers are read-only while powered off
+		return
+	}
+	a.regs[offset] = value
+
+	switch addr {
+	case NR10:
+		a.ch1.sweepReg = value
+	case NR11:
+		a.ch1.duty = value >> 6
+		a.ch1.length.counter = 64 - int(value&0x3F)
+	case NR12:
+		a.ch1.dac = value&0xF8 != 0
+		a.ch1.enabled = a.ch1.enabled && a.ch1.dac
+	case NR13:
+		a.ch1.freq = a.ch1.freq&0x700 | int(value)
+	case NR14:
+		a.ch1.freq = a.ch1.freq&0xFF | int(value&0x07)<<8
+		a.ch1.length.enabled = value&0x40 != 0
+		if value&0x80 != 0 {
+			a.ch1.trigger(a.regs[NR12-NR10])
+		}
+	case NR21:
+		a.ch2.duty = value >> 6
+		a.ch2.length.counter = 64 - int(value&0x3F)
+	case NR22:
+		a.ch2.dac = value&0xF8 != 0
+		a.ch2.enabled = a.ch2.enabled && a.ch2.dac
+	case NR23:
+		a.ch2.freq = a.ch2.freq&0x700 | int(value)
+	case NR24:
+		a.ch2.freq = a.ch2.freq&0xFF | int(value&0x07)<<8
+		a.ch2.length.enabled = value&0x40 != 0
+		if value&0x80 != 0 {
+			a.ch2.trigger(a.regs[NR22-NR10])
+		}
+	case NR30:
+		a.ch3.dac = value&0x80 != 0
+		a.ch3.enabled = a.ch3.enabled && a.ch3.dac
+	case NR31:
+		a.ch3.length.counter = 256 - int(value)
+	case NR32:
+		a.ch3.level = (value >> 5) & 0x03
+	case NR33:
+		a.ch3.freq = a.ch3.freq&0x700 | int(value)
+	case NR34:
+		a.ch3.freq = a.ch3.freq&0xFF | int(value&0x07)<<8
+		a.ch3.length.enabled = value&0x40 != 0
+		if value&0x80 != 0 {
+			a.ch3.trigger()
+		}
+	case NR41:
+		a.ch4.length.counter = 64 - int(value&0x3F)
+	case NR42:
+		a.ch4.dac = value&0xF8 != 0
+		a.ch4.enabled = a.ch4.enabled && a.ch4.dac
+	case NR43:
+		a.ch4.nr43 = value
+	case NR44:
+		a.ch4.length.enabled = value&0x40 != 0
+		if value&0x80 != 0 {
+			a.ch4.trigger(a.regs[NR42-NR10])
+		}
+	}
+}
+
+func (a *APU) setPower(on bool) {
+	if a.enabled && !on {
+		// powering off clears every register but wave RAM
+		for i := range a.regs[:NR52-NR10] {
+			a.regs[i] = 0
+		}
+		a.ch1 = pulse{hasSweep: true}
+		a.ch2 = pulse{}
+		a.ch3 = wave{ram: a.regs[0x20:]}
+		a.ch4 = noise{}
+	}
+	if !a.enabled && on {
+		a.seqStep = 0
+	}
+	a.enabled = on
+}
@@ -0,0 +1,58 @@
+package apu
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+func TestAPU_TriggerAndPowerOff(t *testing.T) {
+	mem := mmu.New()
+	a := New(mem)
+
+	mem.Write(NR52, 0x80)
+	mem.Write(NR22, 0xF0) // DAC on, volume 15
+	mem.Write(NR21, 0x3F) // length 1
+	mem.Write(NR24, 0xC0) // trigger with length enabled
+
+	if got := mem.Read(NR52); got != 0xF2 {
+		t.Fatalf("NR52 = %02X after trigger, want F2", got)
+	}
+
+	// the length counter expires on the first length clock
+	a.Step(FRAME_SEQUENCER_CYCLES)
+	if got := mem.Read(NR52); got != 0xF0 {
+		t.Errorf("NR52 = %02X after length expiry, want F0", got)
+	}
+
+	mem.Write(NR52, 0x00)
+	if got := mem.Read(NR22); got != 0x00 {
+		t.Errorf("NR22 = %02X after power off, want 00", got)
+	}
+	mem.Write(NR22, 0xF0)
+	if got := mem.Read(NR22); got != 0x00 {
+		t.Errorf("NR22 writable while powered off: %02X", got)
+	}
+}
+
+func TestResampler_Linear(t *testing.T) {
+	// one output sample per two native samples, starting on the first
+	r := resampler{step: 2}
+	var out []float32
+	for _, v := range []float32{2, 4, 6, 8} {
+		out = r.push(out, v, -v)
+	}
+	want := []float32{0, 0, 4, -4, 8, -8}
+	if !slices.Equal(out, want) {
+		t.Errorf("output = %v, want %v", out, want)
+	}
+
+	r = resampler{step: 0.5}
+	out = r.push(nil, 2, 0)
+	out = r.push(out, 4, 0)
+	want = []float32{0, 0, 1, 0, 2, 0, 3, 0, 4, 0}
+	if !slices.Equal(out, want) {
+		t.Errorf("upsampled output = %v, want %v", out, want)
+	}
+}
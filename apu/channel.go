@@ -0,0 +1,235 @@
+package apu
+
+var dutyWaveforms = [4][8]byte{
+	{0, 0, 0, 0, 0, 0, 0, 1}, // 12.5%
+	{1, 0, 0, 0, 0, 0, 0, 1}, // 25%
+	{1, 0, 0, 0, 0, 1, 1, 1}, // 50%
+	{0, 1, 1, 1, 1, 1, 1, 0}, // 75%
+}
+
+var noiseDivisors = [8]int{8, 16, 32, 48, 64, 80, 96, 112}
+
+type envelope struct {
+	volume   int
+	period   int
+	timer    int
+	increase bool
+}
+
+func (e *envelope) trigger(nrx2 byte) {
+	e.volume = int(nrx2 >> 4)
+	e.increase = nrx2&0x08 != 0
+	e.period = int(nrx2 & 0x07)
+	e.timer = e.period
+}
+
+func (e *envelope) clock() {
+	if e.period == 0 {
+		return
+	}
+	e.timer--
+	if e.timer > 0 {
+		return
+	}
+	e.timer = e.period
+	if e.increase && e.volume < 15 {
+		e.volume++
+	} else if !e.increase && e.volume > 0 {
+		e.volume--
+	}
+}
+
+type length struct {
+	counter int
+	enabled bool
+}
+
+// clock returns false once the counter expires and the channel turns off.
+func (l *length) clock() bool {
+	if !l.enabled || l.counter == 0 {
+		return true
+	}
+	l.counter--
+	return l.counter != 0
+}
+
+type pulse struct {
+	enabled bool
+	dac     bool
+	duty    byte
+	dutyPos int
+	freq    int
+	timer   int
+	length  length
+	env     envelope
+
+	// frequency sweep, channel 1 only
+	hasSweep     bool
+	sweepReg     byte
+	sweepTimer   int
+	sweepEnabled bool
+	shadowFreq   int
+}
+
+func (p *pulse) period() int {
+	return (2048 - p.freq) * 4
+}
+
+func (p *pulse) step(cycles int) {
+	p.timer -= cycles
+	for p.timer <= 0 {
+		p.timer += p.period()
+		p.dutyPos = (p.dutyPos + 1) & 7
+	}
+}
+
+func (p *pulse) trigger(nrx2 byte) {
+	p.enabled = p.dac
+	if p.length.counter == 0 {
+		p.length.counter = 64
+	}
+	p.timer = p.period()
+	p.env.trigger(nrx2)
+
+	if p.hasSweep {
+		p.shadowFreq = p.freq
+		p.sweepTimer = p.sweepPeriod()
+		shift := p.sweepReg & 0x07
+		p.sweepEnabled = p.sweepReg&0x70 != 0 || shift != 0
+		if shift != 0 {
+			p.sweepCalc()
+		}
+	}
+}
+
+func (p *pulse) sweepPeriod() int {
+	if period := int(p.sweepReg>>4) & 0x07; period != 0 {
+		return period
+	}
+	return 8
+}
+
+// sweepCalc computes the next swept frequency, disabling the channel on
+// overflow.
+func (p *pulse) sweepCalc() int {
+	delta := p.shadowFreq >> (p.sweepReg & 0x07)
+	freq := p.shadowFreq + delta
+	if p.sweepReg&0x08 != 0 {
+		freq = p.shadowFreq - delta
+	}
+	if freq > 2047 {
+		p.enabled = false
+	}
+	return freq
+}
+
+func (p *pulse) clockSweep() {
+	p.sweepTimer--
+	if p.sweepTimer > 0 {
+		return
+	}
+	p.sweepTimer = p.sweepPeriod()
+	if !p.sweepEnabled || p.sweepReg&0x70 == 0 {
+		return
+	}
+	freq := p.sweepCalc()
+	if freq <= 2047 && p.sweepReg&0x07 != 0 {
+		p.freq, p.shadowFreq = freq, freq
+		p.sweepCalc()
+	}
+}
+
+func (p *pulse) output() int {
+	if !p.enabled {
+		return 0
+	}
+	return int(dutyWaveforms[p.duty][p.dutyPos]) * p.env.volume
+}
+
+type wave struct {
+	enabled bool
+	dac     bool
+	freq    int
+	timer   int
+	pos     int
+	// NR32 output level: 0 mute, 1 100%, 2 50%, 3 25%
+	level  byte
+	length length
+	ram    []byte
+}
+
+func (w *wave) period() int {
+	return (2048 - w.freq) * 2
+}
+
+func (w *wave) step(cycles int) {
+	w.timer -= cycles
+	for w.timer <= 0 {
+		w.timer += w.period()
+		w.pos = (w.pos + 1) & 31
+	}
+}
+
+func (w *wave) trigger() {
+	w.enabled = w.dac
+	if w.length.counter == 0 {
+		w.length.counter = 256
+	}
+	w.timer = w.period()
+	w.pos = 0
+}
+
+func (w *wave) output() int {
+	if !w.enabled || w.level == 0 {
+		return 0
+	}
+	sample := w.ram[w.pos/2]
+	if w.pos%2 == 0 {
+		sample >>= 4
+	}
+	return int(sample&0x0F) >> (w.level - 1)
+}
+
+type noise struct {
+	enabled bool
+	dac     bool
+	nr43    byte
+	timer   int
+	lfsr    uint16
+	length  length
+	env     envelope
+}
+
+func (n *noise) period() int {
+	return noiseDivisors[n.nr43&0x07] << (n.nr43 >> 4)
+}
+
+func (n *noise) step(cycles int) {
+	n.timer -= cycles
+	for n.timer <= 0 {
+		n.timer += n.period()
+		bit := (n.lfsr ^ n.lfsr>>1) & 1
+		n.lfsr = n.lfsr>>1 | bit<<14
+		if n.nr43&0x08 != 0 {
+			// 7-bit mode
+			n.lfsr = n.lfsr&^(1<<6) | bit<<6
+		}
+	}
+}
+
+func (n *noise) trigger(nrx2 byte) {
+	n.enabled = n.dac
+	if n.length.counter == 0 {
+		n.length.counter = 64
+	}
+	n.timer = n.period()
+	n.lfsr = 0x7FFF
+	n.env.trigger(nrx2)
+}
+
+func (n *noise) output() int {
+	if !n.enabled {
+		return 0
+	}
+	return int(^n.lfsr&1) * n.env.volume
+}
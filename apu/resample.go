@@ -0,0 +1,35 @@
+package apu
+
+// the channels are mixed once every NATIVE_CYCLES, 131072Hz, and that
+// output is resampled to the host rate
+const (
+	NATIVE_CYCLES = 32
+	NATIVE_HZ     = CLOCK_HZ / NATIVE_CYCLES
+)
+
+// resampler converts the native stereo output to another rate by linear
+// interpolation between consecutive native samples.
+type resampler struct {
+	// native samples per output sample
+	step float64
+	// position of the next output sample, in native samples after prev
+	pos          float64
+	prevL, prevR float32
+}
+
+func newResampler(hz int) resampler {
+	return resampler{step: float64(NATIVE_HZ) / float64(hz)}
+}
+
+// push takes the next native sample and appends the output samples due
+// up to it to out.
+func (r *resampler) push(out []float32, l, rt float32) []float32 {
+	for r.pos <= 1 {
+		f := float32(r.pos)
+		out = append(out, r.prevL+(l-r.prevL)*f, r.prevR+(rt-r.prevR)*f)
+		r.pos += r.step
+	}
+	r.pos--
+	r.prevL, r.prevR = l, rt
+	return out
+}
@@ -0,0 +1,149 @@
+package apu
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// the channel states mirror the channel structs with exported fields so
+// gob can encode them
+type envelopeState struct {
+	Volume, Period, Timer int
+	Increase              bool
+}
+
+type lengthState struct {
+	Counter int
+	Enabled bool
+}
+
+type pulseState struct {
+	Enabled, DAC bool
+	Duty         byte
+	DutyPos      int
+	Freq, Timer  int
+	Length       lengthState
+	Env          envelopeState
+
+	SweepReg     byte
+	SweepTimer   int
+	SweepEnabled bool
+	ShadowFreq   int
+}
+
+type waveState struct {
+	Enabled, DAC     bool
+	Freq, Timer, Pos int
+	Level            byte
+	Length           lengthState
+}
+
+type noiseState struct {
+	Enabled, DAC bool
+	NR43         byte
+	Timer        int
+	LFSR         uint16
+	Length       lengthState
+	Env          envelopeState
+}
+
+// state is the part of the APU a save state holds, wave RAM included in
+// Regs. Samples not yet drained are dropped and resampling starts over.
+type state struct {
+	Regs     [0x30]byte
+	Enabled  bool
+	Ch1, Ch2 pulseState
+	Ch3      waveState
+	Ch4      noiseState
+
+	SeqClock, SeqStep int
+	SampleClock       int
+}
+
+func (e envelope) state() envelopeState {
+	return envelopeState{e.volume, e.period, e.timer, e.increase}
+}
+
+func (e *envelope) restore(s envelopeState) {
+	e.volume, e.period, e.timer, e.increase = s.Volume, s.Period, s.Timer, s.Increase
+}
+
+func (l length) state() lengthState {
+	return lengthState{l.counter, l.enabled}
+}
+
+func (l *length) restore(s lengthState) {
+	l.counter, l.enabled = s.Counter, s.Enabled
+}
+
+func (p *pulse) state() pulseState {
+	return pulseState{
+		Enabled: p.enabled, DAC: p.dac, Duty: p.duty, DutyPos: p.dutyPos,
+		Freq: p.freq, Timer: p.timer, Length: p.length.state(), Env: p.env.state(),
+		SweepReg: p.sweepReg, SweepTimer: p.sweepTimer, SweepEnabled: p.sweepEnabled,
+		ShadowFreq: p.shadowFreq,
+	}
+}
+
+func (p *pulse) restore(s pulseState) {
+	p.enabled, p.dac, p.duty, p.dutyPos = s.Enabled, s.DAC, s.Duty, s.DutyPos
+	p.freq, p.timer = s.Freq, s.Timer
+	p.length.restore(s.Length)
+	p.env.restore(s.Env)
+	p.sweepReg, p.sweepTimer, p.sweepEnabled = s.SweepReg, s.SweepTimer, s.SweepEnabled
+	p.shadowFreq = s.ShadowFreq
+}
+
+func (w *wave) state() waveState {
+	return waveState{
+		Enabled: w.enabled, DAC: w.dac, Freq: w.freq, Timer: w.timer, Pos: w.pos,
+		Level: w.level, Length: w.length.state(),
+	}
+}
+
+func (w *wave) restore(s waveState) {
+	w.enabled, w.dac, w.freq, w.timer, w.pos = s.Enabled, s.DAC, s.Freq, s.Timer, s.Pos
+	w.level = s.Level
+	w.length.restore(s.Length)
+}
+
+func (n *noise) state() noiseState {
+	return noiseState{
+		Enabled: n.enabled, DAC: n.dac, NR43: n.nr43, Timer: n.timer, LFSR: n.lfsr,
+		Length: n.length.state(), Env: n.env.state(),
+	}
+}
+
+func (n *noise) restore(s noiseState) {
+	n.enabled, n.dac, n.nr43, n.timer, n.lfsr = s.Enabled, s.DAC, s.NR43, s.Timer, s.LFSR
+	n.length.restore(s.Length)
+	n.env.restore(s.Env)
+}
+
+func (a *APU) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state{
+		Regs: a.regs, Enabled: a.enabled,
+		Ch1: a.ch1.state(), Ch2: a.ch2.state(), Ch3: a.ch3.state(), Ch4: a.ch4.state(),
+		SeqClock: a.seqClock, SeqStep: a.seqStep, SampleClock: a.sampleClock,
+	})
+	return buf.Bytes(), err
+}
+
+func (a *APU) UnmarshalBinary(data []byte) error {
+	var s state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	a.regs, a.enabled = s.Regs, s.Enabled
+	a.ch1.restore(s.Ch1)
+	a.ch2.restore(s.Ch2)
+	a.ch3.restore(s.Ch3)
+	a.ch4.restore(s.Ch4)
+	a.seqClock, a.seqStep, a.sampleClock = s.SeqClock, s.SeqStep, s.SampleClock
+	a.buffer = nil
+	if a.sampleRate > 0 {
+		a.resample = newResampler(a.sampleRate)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	TYPE_ROM_ONLY               byte = 0x00
+	TYPE_ROM_RAM                byte = 0x08
+	TYPE_ROM_RAM_BATTERY        byte = 0x09
+	TYPE_MBC3_TIMER_BATTERY     byte = 0x0F
+	TYPE_MBC3_TIMER_RAM_BATTERY byte = 0x10
+	TYPE_MBC3                   byte = 0x11
+	TYPE_MBC3_RAM               byte = 0x12
+	TYPE_MBC3_RAM_BATTERY       byte = 0x13
+)
+
+// BankKind is the bank register an MBC write changed.
+type BankKind int
+
+const (
+	BANK_ROM BankKind = iota
+	BANK_RAM
+	// MBC3 RTC register mapped in place of a RAM bank, bank is 0x08-0x0C
+	BANK_RTC
+)
+
+var ErrNoRTC = errors.New("cartridge has no real-time clock")
+
+// Logo is the Nintendo logo bitmap the boot ROM expects at 0x0104-0x0133.
+var Logo = [48]byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83,
+	0x00, 0x0C, 0x00, 0x0D, 0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E,
+	0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99, 0xBB, 0xBB, 0x67, 0x63,
+	0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+// mbc is the memory bank controller that maps the ROM (0x0000-0x7FFF)
+// and external RAM (0xA000-0xBFFF) windows onto the cartridge storage.
+type mbc interface {
+	read(address uint16) byte
+	write(address uint16, value byte)
+	// ramOffset maps an address of the RAM window to external RAM under
+	// the current banking, ok is false when it reaches no RAM byte
+	ramOffset(address uint16) (offset int, ok bool)
+}
+
+// Patch overrides the byte read at a ROM address, only when the original
+// byte equals Compare if HasCompare is set.
+type Patch struct {
+	Address    uint16
+	Value      byte
+	Compare    byte
+	HasCompare bool
+}
+
+type Cartridge struct {
+	rom []byte
+	ram []byte
+	mbc mbc
+
+	patches []Patch
+
+	// external RAM modified since the last ClearDirty
+	dirty   bool
+	onDirty func()
+}
+
+func New(rom []byte) (*Cartridge, error) {
+	// pad small images (test programs) up to the 32KB ROM window
+	if len(rom) < 0x8000 {
+		padded := make([]byte, 0x8000)
+		copy(padded, rom)
+		rom = padded
+	}
+
+	c := &Cartridge{rom: rom}
+	c.ram = make([]byte, ramSize(rom[0x0149]))
+
+	switch c.Type() {
+	case TYPE_ROM_ONLY, TYPE_ROM_RAM, TYPE_ROM_RAM_BATTERY:
+		c.mbc = &romOnly{rom: c.rom, ram: c.ram}
+	case TYPE_MBC3, TYPE_MBC3_RAM, TYPE_MBC3_RAM_BATTERY:
+		c.mbc = newMBC3(c.rom, c.ram, false)
+	case TYPE_MBC3_TIMER_BATTERY, TYPE_MBC3_TIMER_RAM_BATTERY:
+		c.mbc = newMBC3(c.rom, c.ram, true)
+	default:
+		return nil, fmt.Errorf("unsupported cartridge type 0x%02X", c.Type())
+	}
+
+	return c, nil
+}
+
+func (c *Cartridge) Title() string {
+	title := c.rom[0x0134:0x0144]
+	for i, b := range title {
+		if b == 0 {
+			return string(title[:i])
+		}
+	}
+	return string(title)
+}
+
+func (c *Cartridge) Type() byte {
+	return c.rom[0x0147]
+}
+
+// VerifyLogo reports whether the header logo matches the canonical one.
+// A DMG boot ROM locks up when it doesn't.
+func (c *Cartridge) VerifyLogo() bool {
+	return [48]byte(c.rom[0x0104:0x0134]) == Logo
+}
+
+// CGB reports whether the header flag at 0x0143 marks the game as CGB
+// enhanced or CGB only.
+func (c *Cartridge) CGB() bool {
+	return c.rom[0x0143]&0x80 != 0
+}
+
+func (c *Cartridge) AddPatch(p Patch) {
+	c.patches = append(c.patches, p)
+}
+
+func (c *Cartridge) ClearPatches() {
+	c.patches = nil
+}
+
+func (c *Cartridge) Read(address uint16) byte {
+	value := c.mbc.read(address)
+	if len(c.patches) > 0 && address < 0x8000 {
+		for _, p := range c.patches {
+			if p.Address == address && (!p.HasCompare || p.Compare == value) {
+				return p.Value
+			}
+		}
+	}
+	return value
+}
+
+func (c *Cartridge) Write(address uint16, value byte) {
+	// only external RAM makes the save dirty, not the RTC registers
+	// mapped in its place
+	offset, ok := c.mbc.ramOffset(address)
+	if address < 0xA000 || c.dirty || !ok {
+		c.mbc.write(address, value)
+		return
+	}
+	before := c.ram[offset]
+	c.mbc.write(address, value)
+	if c.ram[offset] != before {
+		c.dirty = true
+		if c.onDirty != nil {
+			c.onDirty()
+		}
+	}
+}
+
+// Reset puts the bank controller back in its power-on state. RAM, the
+// real-time clock and patches are kept.
+func (c *Cartridge) Reset() {
+	if m, ok := c.mbc.(*mbc3); ok {
+		rtc, onBankSwitch := m.rtc, m.onBankSwitch
+		*m = *newMBC3(c.rom, c.ram, false)
+		m.rtc, m.onBankSwitch = rtc, onBankSwitch
+	}
+}
+
+// OnBankSwitch sets a callback invoked whenever a bank register of the MBC
+// changes value. Cartridges without an MBC never invoke it.
+func (c *Cartridge) OnBankSwitch(fn func(kind BankKind, bank int)) {
+	if m, ok := c.mbc.(*mbc3); ok {
+		m.onBankSwitch = fn
+	}
+}
+
+// RAM returns a copy of the external RAM, the save of battery-backed
+// cartridges.
+func (c *Cartridge) RAM() []byte {
+	return append([]byte(nil), c.ram...)
+}
+
+// LoadRAM restores external RAM from a save.
+func (c *Cartridge) LoadRAM(data []byte) error {
+	if len(data) != len(c.ram) {
+		return fmt.Errorf("save is %d bytes, cartridge RAM is %d", len(data), len(c.ram))
+	}
+	copy(c.ram, data)
+	return nil
+}
+
+// HasBattery reports whether the cartridge keeps its RAM powered, i.e.
+// whether it holds a save.
+func (c *Cartridge) HasBattery() bool {
+	switch c.Type() {
+	case TYPE_ROM_RAM_BATTERY, TYPE_MBC3_TIMER_BATTERY, TYPE_MBC3_TIMER_RAM_BATTERY, TYPE_MBC3_RAM_BATTERY:
+		return true
+	}
+	return false
+}
+
+// Dirty reports whether external RAM changed since the last ClearDirty.
+func (c *Cartridge) Dirty() bool {
+	return c.dirty
+}
+
+func (c *Cartridge) ClearDirty() {
+	c.dirty = false
+}
+
+// OnDirty sets a callback invoked when external RAM first changes after
+// being clean.
+func (c *Cartridge) OnDirty(fn func()) {
+	c.onDirty = fn
+}
+
+// SetRTC sets the real-time clock to the time of day of t, with the day
+// counter set to t's day of the year.
+func (c *Cartridge) SetRTC(t time.Time) error {
+	m, ok := c.mbc.(*mbc3)
+	if !ok || m.rtc == nil {
+		return ErrNoRTC
+	}
+	m.rtc.setTime(t)
+	return nil
+}
+
+// SetClock replaces the wall clock the real-time clock counts with,
+// keeping its current value. Cartridges without an RTC ignore it.
+func (c *Cartridge) SetClock(now func() time.Time) {
+	m, ok := c.mbc.(*mbc3)
+	if !ok || m.rtc == nil {
+		return
+	}
+	seconds := m.rtc.counter()
+	m.rtc.now = now
+	m.rtc.set(seconds)
+}
+
+// RTC returns the live (unlatched) real-time clock counters.
+func (c *Cartridge) RTC() (days, h, m, s int, ok bool) {
+	mc, ok := c.mbc.(*mbc3)
+	if !ok || mc.rtc == nil {
+		return 0, 0, 0, 0, false
+	}
+	days, h, m, s = mc.rtc.clock()
+	return days, h, m, s, true
+}
+
+func ramSize(code byte) int {
+	switch code {
+	case 0x01:
+		return 0x800
+	case 0x02:
+		return 0x2000
+	case 0x03:
+		return 0x8000
+	case 0x04:
+		return 0x20000
+	case 0x05:
+		return 0x10000
+	}
+	return 0
+}
+
+type romOnly struct {
+	rom []byte
+	ram []byte
+}
+
+func (r *romOnly) read(address uint16) byte {
+	if address < 0x8000 {
+		return r.rom[address]
+	}
+	offset := int(address - 0xA000)
+	if offset < len(r.ram) {
+		return r.ram[offset]
+	}
+	return 0xFF
+}
+
+func (r *romOnly) ramOffset(address uint16) (int, bool) {
+	offset := int(address) - 0xA000
+	return offset, offset >= 0 && offset < len(r.ram)
+}
+
+func (r *romOnly) write(address uint16, value byte) {
+	if address < 0x8000 {
+		return
+	}
+	offset := int(address - 0xA000)
+	if offset < len(r.ram) {
+		r.ram[offset] = value
+	}
+}
@@ -0,0 +1,21 @@
+package cartridge
+
+import "testing"
+
+func TestCartridge_VerifyLogo(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0104:], Logo[:])
+
+	cart, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cart.VerifyLogo() {
+		t.Error("VerifyLogo() = false for the canonical logo")
+	}
+
+	rom[0x0133] ^= 0x01
+	if cart.VerifyLogo() {
+		t.Error("VerifyLogo() = true for a corrupted logo")
+	}
+}
@@ -0,0 +1,190 @@
+package cartridge
+
+import "time"
+
+type mbc3 struct {
+	rom []byte
+	ram []byte
+
+	ramEnabled bool
+	romBank    int
+	// 0x00-0x03 selects a RAM bank, 0x08-0x0C an RTC register
+	ramBank byte
+
+	rtc *rtc
+
+	onBankSwitch func(kind BankKind, bank int)
+}
+
+func newMBC3(rom, ram []byte, hasRTC bool) *mbc3 {
+	m := &mbc3{rom: rom, ram: ram, romBank: 1}
+	if hasRTC {
+		m.rtc = &rtc{now: time.Now}
+		m.rtc.sync = m.rtc.now()
+	}
+	return m
+}
+
+func (m *mbc3) read(address uint16) byte {
+	switch {
+	case address < 0x4000:
+		return m.rom[address]
+	case address < 0x8000:
+		offset := m.romBank*0x4000 + int(address-0x4000)
+		return m.rom[offset%len(m.rom)]
+	case address >= 0xA000 && address < 0xC000:
+		if !m.ramEnabled {
+			return 0xFF
+		}
+		if m.ramBank >= 0x08 {
+			if m.rtc == nil || m.ramBank > 0x0C {
+				return 0xFF
+			}
+			return m.rtc.latched[m.ramBank-0x08]
+		}
+		offset := int(m.ramBank)*0x2000 + int(address-0xA000)
+		if offset < len(m.ram) {
+			return m.ram[offset]
+		}
+	}
+	return 0xFF
+}
+
+func (m *mbc3) write(address uint16, value byte) {
+	switch {
+	case address < 0x2000: // RAM and timer enable
+		m.ramEnabled = value&0x0F == 0x0A
+	case address < 0x4000: // ROM bank number
+		bank := int(value & 0x7F)
+		if bank == 0 {
+			bank = 1
+		}
+		if bank != m.romBank {
+			m.romBank = bank
+			m.bankSwitched(BANK_ROM, bank)
+		}
+	case address < 0x6000: // RAM bank number or RTC register select
+		if value != m.ramBank {
+			m.ramBank = value
+			if value >= 0x08 {
+				m.bankSwitched(BANK_RTC, int(value))
+			} else {
+				m.bankSwitched(BANK_RAM, int(value))
+			}
+		}
+	case address < 0x8000: // latch clock data on a 0x00 -> 0x01 write sequence
+		if m.rtc != nil {
+			m.rtc.writeLatch(value)
+		}
+	case address >= 0xA000 && address < 0xC000:
+		if !m.ramEnabled {
+			return
+		}
+		if m.ramBank >= 0x08 {
+			if m.rtc != nil && m.ramBank <= 0x0C {
+				m.rtc.writeRegister(m.ramBank-0x08, value)
+			}
+			return
+		}
+		offset := int(m.ramBank)*0x2000 + int(address-0xA000)
+		if offset < len(m.ram) {
+			m.ram[offset] = value
+		}
+	}
+}
+
+func (m *mbc3) ramOffset(address uint16) (int, bool) {
+	if address < 0xA000 || address >= 0xC000 || m.ramBank >= 0x08 {
+		return 0, false
+	}
+	offset := int(m.ramBank)*0x2000 + int(address-0xA000)
+	return offset, offset < len(m.ram)
+}
+
+func (m *mbc3) bankSwitched(kind BankKind, bank int) {
+	if m.onBankSwitch != nil {
+		m.onBankSwitch(kind, bank)
+	}
+}
+
+const (
+	RTC_S  byte = 0x00
+	RTC_M  byte = 0x01
+	RTC_H  byte = 0x02
+	RTC_DL byte = 0x03
+	RTC_DH byte = 0x04
+
+	secondsPerDay = 24 * 60 * 60
+	// the day counter is 9 bits wide
+	rtcWrap = 512 * secondsPerDay
+)
+
+type rtc struct {
+	now func() time.Time
+
+	// counter value in seconds at the wall-clock time sync
+	seconds int64
+	sync    time.Time
+	halted  bool
+	carry   bool
+
+	latched   [5]byte
+	lastLatch byte
+}
+
+func (r *rtc) counter() int64 {
+	total := r.seconds
+	if !r.halted {
+		total += int64(r.now().Sub(r.sync) / time.Second)
+	}
+	if total >= rtcWrap {
+		r.carry = true
+		r.seconds -= (total / rtcWrap) * rtcWrap
+		total %= rtcWrap
+	}
+	return total
+}
+
+func (r *rtc) set(seconds int64) {
+	r.seconds = seconds
+	r.sync = r.now()
+}
+
+func (r *rtc) setTime(t time.Time) {
+	r.set(int64(t.YearDay()-1)*secondsPerDay + int64(t.Hour()*3600+t.Minute()*60+t.Second()))
+	r.carry = false
+}
+
+func (r *rtc) clock() (days, h, m, s int) {
+	total := int(r.counter())
+	return total / secondsPerDay, total / 3600 % 24, total / 60 % 60, total % 60
+}
+
+func (r *rtc) registers() [5]byte {
+	days, h, m, s := r.clock()
+	dh := byte(days>>8) & 0x01
+	if r.halted {
+		dh |= 0x40
+	}
+	if r.carry {
+		dh |= 0x80
+	}
+	return [5]byte{byte(s), byte(m), byte(h), byte(days), dh}
+}
+
+func (r *rtc) writeLatch(value byte) {
+	if r.lastLatch == 0x00 && value == 0x01 {
+		r.latched = r.registers()
+	}
+	r.lastLatch = value
+}
+
+func (r *rtc) writeRegister(reg byte, value byte) {
+	regs := r.registers()
+	regs[reg] = value
+
+	days := int64(regs[RTC_DL]) | int64(regs[RTC_DH]&0x01)<<8
+	r.set(days*secondsPerDay + int64(regs[RTC_H]%24)*3600 + int64(regs[RTC_M]%60)*60 + int64(regs[RTC_S]%60))
+	r.halted = regs[RTC_DH]&0x40 != 0
+	r.carry = regs[RTC_DH]&0x80 != 0
+}
@@ -0,0 +1,101 @@
+package cartridge
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestCartridge(t *testing.T, cartType byte) *Cartridge {
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = cartType
+	rom[0x0149] = 0x03
+	cart, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cart
+}
+
+func TestMBC3_RTCLatch(t *testing.T) {
+	cart := newTestCartridge(t, TYPE_MBC3_TIMER_RAM_BATTERY)
+	now := time.Date(2024, time.February, 3, 13, 37, 42, 0, time.UTC)
+	cart.mbc.(*mbc3).rtc.now = func() time.Time { return now }
+
+	if err := cart.SetRTC(now); err != nil {
+		t.Fatal(err)
+	}
+
+	cart.Write(0x0000, 0x0A) // enable RAM/RTC
+	cart.Write(0x6000, 0x00)
+	cart.Write(0x6000, 0x01) // latch
+
+	// time keeps running but the latched registers must not change
+	now = now.Add(5 * time.Second)
+
+	want := map[byte]byte{0x08: 42, 0x09: 37, 0x0A: 13, 0x0B: 33, 0x0C: 0x00}
+	for reg, value := range want {
+		cart.Write(0x4000, reg)
+		if got := cart.Read(0xA000); got != value {
+			t.Errorf("RTC register %02X = %d, want %d", reg, got, value)
+		}
+	}
+
+	days, h, m, s, ok := cart.RTC()
+	if !ok || days != 33 || h != 13 || m != 37 || s != 47 {
+		t.Errorf("RTC() = %d %d:%d:%d (ok=%v), want 33 13:37:47", days, h, m, s, ok)
+	}
+}
+
+func TestMBC3_NoRTC(t *testing.T) {
+	cart := newTestCartridge(t, TYPE_MBC3_RAM_BATTERY)
+
+	if err := cart.SetRTC(time.Now()); err != ErrNoRTC {
+		t.Errorf("SetRTC() error = %v, want %v", err, ErrNoRTC)
+	}
+	if _, _, _, _, ok := cart.RTC(); ok {
+		t.Error("RTC() ok = true for a cartridge without RTC")
+	}
+}
+
+func TestMBC3_OnBankSwitch(t *testing.T) {
+	cart := newTestCartridge(t, TYPE_MBC3_TIMER_RAM_BATTERY)
+
+	type event struct {
+		kind BankKind
+		bank int
+	}
+	var got []event
+	cart.OnBankSwitch(func(kind BankKind, bank int) { got = append(got, event{kind, bank}) })
+
+	cart.Write(0x2000, 0x05)
+	cart.Write(0x2000, 0x05) // unchanged
+	cart.Write(0x2000, 0x00) // maps bank 1
+	cart.Write(0x4000, 0x02)
+	cart.Write(0x4000, 0x08)
+	cart.Write(0x2000, 0x01) // already bank 1
+
+	want := []event{{BANK_ROM, 5}, {BANK_ROM, 1}, {BANK_RAM, 2}, {BANK_RTC, 0x08}}
+	if !slices.Equal(got, want) {
+		t.Errorf("bank switches = %v, want %v", got, want)
+	}
+}
+
+func TestMBC3_RTCWriteNotDirty(t *testing.T) {
+	cart := newTestCartridge(t, TYPE_MBC3_TIMER_RAM_BATTERY)
+	dirty := 0
+	cart.OnDirty(func() { dirty++ })
+
+	cart.Write(0x0000, 0x0A) // enable RAM/RTC
+	cart.Write(0x4000, 0x08) // RTC seconds
+	cart.Write(0xA000, 0x15)
+	if cart.Dirty() || dirty != 0 {
+		t.Fatalf("RTC write made RAM dirty (%d callbacks)", dirty)
+	}
+
+	cart.Write(0x4000, 0x01) // RAM bank 1
+	cart.Write(0xA000, 0x15)
+	if !cart.Dirty() || dirty != 1 {
+		t.Errorf("Dirty() = %v with %d callbacks after a RAM write, want true and 1", cart.Dirty(), dirty)
+	}
+}
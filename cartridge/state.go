@@ -0,0 +1,66 @@
+package cartridge
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+)
+
+// state is the part of the cartridge a save state holds: external RAM and
+// the bank controller registers. The ROM and patches are not included.
+type state struct {
+	RAM []byte
+
+	RAMEnabled bool
+	ROMBank    int
+	RAMBank    byte
+
+	HasRTC              bool
+	RTCSeconds          int64
+	RTCSync             time.Time
+	RTCHalted, RTCCarry bool
+	RTCLatched          [5]byte
+	RTCLastLatch        byte
+}
+
+func (c *Cartridge) MarshalBinary() ([]byte, error) {
+	s := state{RAM: c.ram}
+	if m, ok := c.mbc.(*mbc3); ok {
+		s.RAMEnabled, s.ROMBank, s.RAMBank = m.ramEnabled, m.romBank, m.ramBank
+		if r := m.rtc; r != nil {
+			s.HasRTC = true
+			s.RTCSeconds, s.RTCSync = r.seconds, r.sync
+			s.RTCHalted, s.RTCCarry = r.halted, r.carry
+			s.RTCLatched, s.RTCLastLatch = r.latched, r.lastLatch
+		}
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(s)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores a state saved from a cartridge of the same type.
+func (c *Cartridge) UnmarshalBinary(data []byte) error {
+	var s state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	if err := c.LoadRAM(s.RAM); err != nil {
+		return err
+	}
+	m, ok := c.mbc.(*mbc3)
+	if !ok {
+		return nil
+	}
+	if s.HasRTC != (m.rtc != nil) {
+		return fmt.Errorf("state real-time clock %v, cartridge has %v", s.HasRTC, m.rtc != nil)
+	}
+	m.ramEnabled, m.romBank, m.ramBank = s.RAMEnabled, s.ROMBank, s.RAMBank
+	if r := m.rtc; r != nil {
+		r.seconds, r.sync = s.RTCSeconds, s.RTCSync
+		r.halted, r.carry = s.RTCHalted, s.RTCCarry
+		r.latched, r.lastLatch = s.RTCLatched, s.RTCLastLatch
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package cheat
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cartridge"
+)
+
+func TestDecodeGameGenie(t *testing.T) {
+	tests := []struct {
+		code string
+		want cartridge.Patch
+	}{
+		{"C3A-12F", cartridge.Patch{Address: 0x0A12, Value: 0xC3}},
+		{"00A-17B-C49", cartridge.Patch{Address: 0x4A17, Value: 0x00, Compare: 0xC8, HasCompare: true}},
+	}
+	for _, tc := range tests {
+		got, err := DecodeGameGenie(tc.code)
+		if err != nil {
+			t.Errorf("DecodeGameGenie(%q) error: %v", tc.code, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("DecodeGameGenie(%q) = %+v, want %+v", tc.code, got, tc.want)
+		}
+	}
+
+	if _, err := DecodeGameGenie("XYZ-123"); err == nil {
+		t.Error("invalid digits accepted")
+	}
+}
+
+func TestDecodeGameShark(t *testing.T) {
+	got, err := DecodeGameShark("010238CD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (GameShark{Bank: 0x01, Value: 0x02, Address: 0xCD38}); got != want {
+		t.Errorf("DecodeGameShark() = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyIPS(t *testing.T) {
+	rom := []byte{0, 1, 2, 3, 4, 5}
+	patch := []byte("PATCH")
+	patch = append(patch, 0x00, 0x00, 0x01, 0x00, 0x02, 0xAA, 0xBB)       // 2 bytes at 1
+	patch = append(patch, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x03, 0xCC) // RLE x3 at 5
+	patch = append(patch, "EOF"...)
+
+	got, err := ApplyIPS(rom, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0, 0xAA, 0xBB, 3, 4, 0xCC, 0xCC, 0xCC}; !slices.Equal(got, want) {
+		t.Errorf("ApplyIPS() = % X, want % X", got, want)
+	}
+	if rom[1] != 1 {
+		t.Error("ApplyIPS modified the input ROM")
+	}
+}
@@ -0,0 +1,42 @@
+package cheat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/duyquang6/go-retroid/cartridge"
+)
+
+// DecodeGameGenie decodes a "VVA-AAA" or "VVA-AAA-CXC" Game Genie code into
+// a ROM patch. VV is the new value, the address digits are scrambled as
+// ((A6 ^ 0xF) << 12) | A3 << 8 | A4 << 4 | A5 and the compare byte is
+// stored rotated left by 2 and XORed with 0xBA.
+func DecodeGameGenie(code string) (cartridge.Patch, error) {
+	digits := strings.ReplaceAll(strings.ToUpper(code), "-", "")
+	if len(digits) != 6 && len(digits) != 9 {
+		return cartridge.Patch{}, fmt.Errorf("game genie code %q: want 6 or 9 hex digits", code)
+	}
+	n := make([]uint16, len(digits))
+	for i, d := range digits {
+		v, err := strconv.ParseUint(string(d), 16, 4)
+		if err != nil {
+			return cartridge.Patch{}, fmt.Errorf("game genie code %q: %w", code, err)
+		}
+		n[i] = uint16(v)
+	}
+
+	p := cartridge.Patch{
+		Value:   byte(n[0]<<4 | n[1]),
+		Address: (n[5]^0xF)<<12 | n[2]<<8 | n[3]<<4 | n[4],
+	}
+	if p.Address >= 0x8000 {
+		return cartridge.Patch{}, fmt.Errorf("game genie code %q: address 0x%04X outside ROM", code, p.Address)
+	}
+	if len(n) == 9 {
+		gi := byte(n[6]<<4 | n[8])
+		p.Compare = (gi>>2 | gi<<6) ^ 0xBA
+		p.HasCompare = true
+	}
+	return p, nil
+}
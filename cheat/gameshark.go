@@ -0,0 +1,31 @@
+package cheat
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// GameShark is a RAM patch, re-applied every frame.
+type GameShark struct {
+	// external RAM bank for addresses in 0xA000-0xBFFF
+	Bank    byte
+	Value   byte
+	Address uint16
+}
+
+// DecodeGameShark decodes a "BBVVLLHH" GameShark code: RAM bank, value and
+// the little-endian address.
+func DecodeGameShark(code string) (GameShark, error) {
+	if len(code) != 8 {
+		return GameShark{}, fmt.Errorf("gameshark code %q: want 8 hex digits", code)
+	}
+	raw, err := strconv.ParseUint(code, 16, 32)
+	if err != nil {
+		return GameShark{}, fmt.Errorf("gameshark code %q: %w", code, err)
+	}
+	return GameShark{
+		Bank:    byte(raw >> 24),
+		Value:   byte(raw >> 16),
+		Address: uint16(raw>>8)&0xFF | uint16(raw&0xFF)<<8,
+	}, nil
+}
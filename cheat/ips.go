@@ -0,0 +1,59 @@
+package cheat
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrBadIPS = errors.New("ips: missing PATCH header")
+
+// ApplyIPS returns a copy of rom with an IPS patch applied, growing it when
+// records write past its end.
+func ApplyIPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < 5 || string(patch[:5]) != "PATCH" {
+		return nil, ErrBadIPS
+	}
+	out := append([]byte(nil), rom...)
+
+	pos := 5
+	for {
+		if pos+3 > len(patch) {
+			return nil, errors.New("ips: missing EOF marker")
+		}
+		if string(patch[pos:pos+3]) == "EOF" {
+			break
+		}
+		if pos+5 > len(patch) {
+			return nil, fmt.Errorf("ips: truncated record at %d", pos)
+		}
+		offset := int(patch[pos])<<16 | int(patch[pos+1])<<8 | int(patch[pos+2])
+		size := int(patch[pos+3])<<8 | int(patch[pos+4])
+		pos += 5
+
+		var data []byte
+		if size == 0 {
+			// RLE record: run length and the byte to repeat
+			if pos+3 > len(patch) {
+				return nil, fmt.Errorf("ips: truncated RLE record at %d", pos)
+			}
+			run := int(patch[pos])<<8 | int(patch[pos+1])
+			data = make([]byte, run)
+			for i := range data {
+				data[i] = patch[pos+2]
+			}
+			pos += 3
+		} else {
+			if pos+size > len(patch) {
+				return nil, fmt.Errorf("ips: truncated record data at %d", pos)
+			}
+			data = patch[pos : pos+size]
+			pos += size
+		}
+
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+	return out, nil
+}
@@ -0,0 +1,33 @@
+package cpu
+
+import (
+	"maps"
+	"slices"
+)
+
+// AddBreakpoint marks an address; AtBreakpoint reports when PC reaches it.
+func (c *CPU) AddBreakpoint(address uint16) {
+	if c.breakpoints == nil {
+		c.breakpoints = make(map[uint16]struct{})
+	}
+	c.breakpoints[address] = struct{}{}
+}
+
+func (c *CPU) RemoveBreakpoint(address uint16) {
+	delete(c.breakpoints, address)
+}
+
+// Breakpoints returns the breakpoint addresses in ascending order.
+func (c *CPU) Breakpoints() []uint16 {
+	return slices.Sorted(maps.Keys(c.breakpoints))
+}
+
+func (c *CPU) ClearBreakpoints() {
+	c.breakpoints = nil
+}
+
+// AtBreakpoint reports whether the next instruction is on a breakpoint.
+func (c *CPU) AtBreakpoint() bool {
+	_, ok := c.breakpoints[c.PC]
+	return ok
+}
@@ -0,0 +1,63 @@
+package cpu
+
+import "log/slog"
+
+// Bus is the memory seen by the CPU. mmu.Memory is the bus of a GameBoy,
+// tests and fuzzers can plug in flat RAM instead.
+type Bus interface {
+	Read(address uint16) byte
+	Write(address uint16, value byte)
+}
+
+// Idler is implemented by buses that need to know about M-cycles the CPU
+// spends without accessing memory, to keep other components in step.
+type Idler interface {
+	Idle()
+}
+
+// idle spends an internal M-cycle, telling the bus if it cares.
+func (c *CPU) idle() {
+	if i, ok := c.bus.(Idler); ok {
+		i.Idle()
+	}
+}
+
+// SetBus routes the CPU's memory accesses through bus, for instance to
+// clock other components before each access. Memory() is unchanged.
+func (c *CPU) SetBus(bus Bus) {
+	c.bus = bus
+}
+
+// Registers is a snapshot of the CPU register file.
+type Registers struct {
+	A, F, B, C, D, E, H, L byte
+	PC, SP                 uint16
+	IME                    bool
+}
+
+func (c *CPU) Registers() Registers {
+	return Registers{
+		A: c.A, F: c.F, B: c.B, C: c.C, D: c.D, E: c.E, H: c.H, L: c.L,
+		PC: c.PC, SP: c.SP, IME: c.IME,
+	}
+}
+
+func (c *CPU) SetRegisters(r Registers) {
+	c.A, c.F, c.B, c.C, c.D, c.E, c.H, c.L = r.A, r.F, r.B, r.C, r.D, r.E, r.H, r.L
+	c.PC, c.SP, c.IME = r.PC, r.SP, r.IME
+}
+
+// ExecuteOnce runs the instruction at regs.PC on bus and returns the
+// resulting registers and the T-cycles it took.
+func ExecuteOnce(regs Registers, bus Bus) (Registers, int) {
+	c := &CPU{bus: bus, logger: slog.New(slog.DiscardHandler)}
+	c.SetRegisters(regs)
+	cycles := c.Step()
+	return c.Registers(), cycles
+}
+
+func (c *CPU) triggerOAMBug(address uint16) {
+	if c.mem != nil {
+		c.mem.TriggerOAMBug(address)
+	}
+}
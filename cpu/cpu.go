@@ -1,6 +1,7 @@
 package cpu
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"log/slog"
@@ -22,27 +23,72 @@ type CPU struct {
 	// interupt master enable
 	IME bool
 
-	mem *mmu.Memory
+	// bus is what instructions read and write, mem the same memory when
+	// the CPU runs inside a GameBoy and nil otherwise
+	bus    Bus
+	mem    *mmu.Memory
+	logger *slog.Logger
 
-	stopped bool
+	// HALT waits for any pending interrupt, STOP for a joypad one; both
+	// idle without fetching
+	halted, stopped bool
+	// an illegal opcode hangs the CPU until reset
+	locked bool
+
+	// T-cycles consumed by the instruction being executed
+	cycles int
+	// EI takes effect once the following instruction completes
+	imeScheduled bool
+	// HALT bug: the next fetch does not increment PC
+	haltBug bool
+
+	runawayLimit    int
+	nopRun, loopRun int
+	onRunaway       func(pc uint16)
+
+	breakpoints map[uint16]struct{}
+
+	// called on STOP; returning true means STOP switched the CPU speed
+	// and resumes immediately
+	onStop func() bool
+
+	onIMEChange func(enabled bool, cause IMECause)
 }
 
 func New(mem *mmu.Memory) *CPU {
-	// follow Gameboy BIOS spec
-	return &CPU{
-		A:       0x01,   // Accumulator
-		F:       0xB0,   // Flags
-		B:       0x00,   // General-purpose register B
-		C:       0x13,   // General-purpose register C
-		D:       0x00,   // General-purpose register D
-		E:       0xD8,   // General-purpose register E
-		H:       0x01,   // General-purpose register H
-		L:       0x4D,   // General-purpose register L
-		PC:      0x0100, // Program Counter starts at 0x0100
-		SP:      0xFFFE, // Stack Pointer starts at 0xFFFE
-		mem:     mem,    // Memory reference
-		stopped: false,  // CPU is not stopped initially
-	}
+	c := &CPU{bus: mem, mem: mem, logger: slog.New(slog.DiscardHandler)}
+	c.Reset()
+	return c
+}
+
+// Reset puts the registers in the state the DMG boot ROM leaves them in.
+func (c *CPU) Reset() {
+	c.A = 0x01    // Accumulator
+	c.F = 0xB0    // Flags
+	c.B = 0x00    // General-purpose register B
+	c.C = 0x13    // General-purpose register C
+	c.D = 0x00    // General-purpose register D
+	c.E = 0xD8    // General-purpose register E
+	c.H = 0x01    // General-purpose register H
+	c.L = 0x4D    // General-purpose register L
+	c.PC = 0x0100 // Program Counter starts at 0x0100
+	c.SP = 0xFFFE // Stack Pointer starts at 0xFFFE
+	c.IME = false // Interrupts disabled
+	c.imeScheduled = false
+	c.halted = false
+	c.haltBug = false
+	c.stopped = false // CPU is not stopped initially
+	c.locked = false
+}
+
+// OnStop registers the handler run by the STOP instruction, used for the
+// CGB speed switch.
+func (c *CPU) OnStop(fn func() bool) {
+	c.onStop = fn
+}
+
+func (c *CPU) SetLogger(logger *slog.Logger) {
+	c.logger = logger
 }
 
 func (c *CPU) Memory() *mmu.Memory {
@@ -50,27 +96,96 @@ func (c *CPU) Memory() *mmu.Memory {
 }
 
 func (c *CPU) Fetch() byte {
-	opcode := c.mem.Read(c.PC)
+	opcode := c.bus.Read(c.PC)
+	if c.haltBug {
+		c.haltBug = false
+		return opcode
+	}
 	c.PC++
 
 	return opcode
 }
 
-func (c *CPU) Step() {
-	c.Execute(c.Fetch())
+// Step executes the next instruction and returns the T-cycles it took.
+func (c *CPU) Step() int {
+	if c.locked {
+		return 4
+	}
+	if c.halted || c.stopped {
+		// waking up takes a cycle, a pending interrupt is serviced before
+		// the next instruction
+		c.wake()
+		return 4
+	}
+	pc := c.PC
+	opcode := c.Fetch()
+	cycles := c.Execute(opcode)
+	if c.runawayLimit > 0 {
+		c.checkRunaway(pc, opcode)
+	}
+	return cycles
+}
+
+// lockUp hangs the CPU like hardware does on an illegal opcode: nothing
+// runs anymore, not even interrupts, until Reset.
+func (c *CPU) lockUp(opcode byte) {
+	c.logger.Error("illegal opcode, CPU locked up", "opcode", fmt.Sprintf("0x%02X", opcode), "pc", c.PC-1)
+	c.locked = true
+	c.IME = false
+}
+
+// wake ends HALT once an enabled interrupt is requested, regardless of
+// IME, and STOP once the joypad interrupt is.
+func (c *CPU) wake() {
+	pending := c.pending()
+	if c.halted && pending != 0 {
+		c.halted = false
+	}
+	if c.stopped && pending&INT_JOYPAD != 0 {
+		c.stopped = false
+	}
+}
+
+// Halted reports whether HALT or STOP is waiting for an interrupt.
+func (c *CPU) Halted() bool {
+	return c.halted || c.stopped
+}
+
+// Locked reports whether an illegal opcode hung the CPU.
+func (c *CPU) Locked() bool {
+	return c.locked
 }
 
-func (c *CPU) Execute(opcode byte) {
+// RunInstructions executes n instructions back to back, without syncing any
+// other component, and returns the total T-cycles.
+func (c *CPU) RunInstructions(n int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += c.Step()
+	}
+	return total
+}
+
+// Execute runs an already fetched opcode. On hardware the next opcode is
+// fetched during the last M-cycle of the current one, before interrupts
+// are checked. That prefetch is why the instruction following EI always
+// runs before a pending interrupt is serviced: IME only turns on once it
+// completes.
+func (c *CPU) Execute(opcode byte) int {
+	c.cycles = opcodeCycles[opcode]
+	enableIME := c.imeScheduled
+
 	switch opcode {
 	// 8 bit instruction
 	case 0x00: // NOP, do nothing
 	case 0x01: // LD BC, d16
-		c.B = c.mem.Read(c.PC + 1)
-		c.C = c.mem.Read(c.PC)
+		c.B = c.bus.Read(c.PC + 1)
+		c.C = c.bus.Read(c.PC)
 		c.PC += 2
 	case 0x02: // LD (BC), A
-		c.mem.Write(c.BC(), c.A)
+		c.bus.Write(c.BC(), c.A)
 	case 0x03: // INC BC
+		c.triggerOAMBug(c.BC())
 		c.WriteBC(c.BC() + 1)
 	case 0x04: // INC B
 		c.inc(&c.B)
@@ -88,23 +203,16 @@ func (c *CPU) Execute(opcode byte) {
 			c.A |= 0x01
 		}
 	case 0x08: // LD (a16), SP
-		c.mem.Write(c.PC, byte(c.SP&0x00FF))
-		c.mem.Write(c.PC+1, byte((c.SP&0xFF00)>>8))
+		address := uint16(c.bus.Read(c.PC+1))<<8 | uint16(c.bus.Read(c.PC))
+		c.bus.Write(address, byte(c.SP&0x00FF))
+		c.bus.Write(address+1, byte((c.SP&0xFF00)>>8))
 		c.PC += 2
 	case 0x09: // ADD HL, BC
-		old := c.HL()
-		sum := uint32(c.HL()) + uint32(c.BC())
-		c.WriteHL(uint16(sum & 0xFFFF))
-		c.F &= 0x80
-		if (old&0x00FF)+(c.BC()&0x00FF) > 0x00FF {
-			c.F |= FLAG_HALFCARRY
-		}
-		if sum > 0xFFFF {
-			c.F |= FLAG_CARRY
-		}
+		c.addHL(c.BC())
 	case 0x0A: // LD A, (BC)
-		c.A = c.mem.Read(c.BC())
+		c.A = c.bus.Read(c.BC())
 	case 0x0B: // DEC BC
+		c.triggerOAMBug(c.BC())
 		c.WriteBC(c.BC() - 1)
 	case 0x0C: // INC C
 		c.inc(&c.C)
@@ -124,16 +232,20 @@ func (c *CPU) Execute(opcode byte) {
 
 	// 0x1X
 	case 0x10: // STOP
-		c.stopped = true
 		c.PC++
-		slog.Info("CPU stopped, awaiting interrupt")
+		if c.onStop != nil && c.onStop() {
+			break
+		}
+		c.stopped = true
+		c.logger.Info("CPU stopped, awaiting interrupt")
 	case 0x11: // LD DE, d16
-		c.D = c.mem.Read(c.PC + 1)
-		c.E = c.mem.Read(c.PC)
+		c.D = c.bus.Read(c.PC + 1)
+		c.E = c.bus.Read(c.PC)
 		c.PC += 2
 	case 0x12: // LD (DE), A
-		c.mem.Write(c.DE(), c.A)
+		c.bus.Write(c.DE(), c.A)
 	case 0x13: // INC DE
+		c.triggerOAMBug(c.DE())
 		c.WriteDE(c.DE() + 1)
 	case 0x14: // INC D
 		c.inc(&c.D)
@@ -155,20 +267,12 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x18: // JR s8
 		c.jr()
 	case 0x19: // ADD HL, DE
-		old := c.HL()
-		sum := uint32(c.HL()) + uint32(c.DE())
-		c.WriteHL(uint16(sum & 0xFFFF))
-		c.F &= 0x80
-		if (old&0x00FF)+(c.DE()&0x00FF) > 0x00FF {
-			c.F |= FLAG_HALFCARRY
-		}
-		if sum > 0xFFFF {
-			c.F |= FLAG_CARRY
-		}
+		c.addHL(c.DE())
 	case 0x1A: // LD A, (DE)
-		c.A = c.mem.Read(c.DE())
+		c.A = c.bus.Read(c.DE())
 	case 0x1B: // DEC DE
-		c.WriteBC(c.DE() - 1)
+		c.triggerOAMBug(c.DE())
+		c.WriteDE(c.DE() - 1)
 	case 0x1C: // INC E
 		c.inc(&c.E)
 	case 0x1D: // DEC E
@@ -187,17 +291,16 @@ func (c *CPU) Execute(opcode byte) {
 		}
 	// 0x2X
 	case 0x20: // JR NZ, s8
-		if c.F&FLAG_ZERO == 0 {
-			c.jr()
-		}
+		c.jrIf(c.F&FLAG_ZERO == 0)
 	case 0x21: // LD HL,d16
-		c.H = c.mem.Read(c.PC + 1)
-		c.L = c.mem.Read(c.PC)
+		c.H = c.bus.Read(c.PC + 1)
+		c.L = c.bus.Read(c.PC)
 		c.PC += 2
 	case 0x22: // LD (HL+),A
-		c.mem.Write(c.HL(), c.A)
+		c.bus.Write(c.HL(), c.A)
 		c.WriteHL(c.HL() + 1)
 	case 0x23: // INC HL
+		c.triggerOAMBug(c.HL())
 		c.WriteHL(c.HL() + 1)
 	case 0x24: // INC H
 		c.inc(&c.H)
@@ -206,50 +309,36 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x26: // LD H,d8
 		c.ldXNN(&c.H)
 	case 0x27: // DAA
+		// adjust by the digits that overflowed, judged on the original A
 		if c.F&FLAG_SUBTRACT == 0 {
-			// Addition
-			if (c.A&0x0F) > 9 || (c.F&FLAG_HALFCARRY) != 0 {
-				c.A += 0x06
-			}
-			if c.A > 0x99 || (c.F&FLAG_CARRY) != 0 {
+			if c.A > 0x99 || c.F&FLAG_CARRY != 0 {
 				c.A += 0x60
 				c.F |= FLAG_CARRY
 			}
-		} else {
-			if (c.F & FLAG_HALFCARRY) != 0 {
-				c.A -= 0x06
+			if c.A&0x0F > 0x09 || c.F&FLAG_HALFCARRY != 0 {
+				c.A += 0x06
 			}
-			if (c.F & FLAG_CARRY) != 0 {
+		} else {
+			if c.F&FLAG_CARRY != 0 {
 				c.A -= 0x60
 			}
+			if c.F&FLAG_HALFCARRY != 0 {
+				c.A -= 0x06
+			}
 		}
-		// Reset H to 0
-		c.F &= ^FLAG_HALFCARRY
-
+		c.F &^= FLAG_HALFCARRY | FLAG_ZERO
 		if c.A == 0 {
 			c.F |= FLAG_ZERO
-		} else {
-			c.F &= ^FLAG_ZERO
 		}
 	case 0x28: // JR Z,s8
-		if c.F&FLAG_ZERO != 0 {
-			c.jr()
-		}
+		c.jrIf(c.F&FLAG_ZERO != 0)
 	case 0x29: // ADD HL,HL
-		old := c.HL()
-		sum := uint32(c.HL()) + uint32(c.HL())
-		c.WriteHL(uint16(sum & 0xFFFF))
-		c.F &= 0x80
-		if (old&0x00FF)+(old&0x00FF) > 0x00FF {
-			c.F |= FLAG_HALFCARRY
-		}
-		if sum > 0xFFFF {
-			c.F |= FLAG_CARRY
-		}
+		c.addHL(c.HL())
 	case 0x2A: // LD A,(HL+)
-		c.A = c.mem.Read(c.HL())
+		c.A = c.bus.Read(c.HL())
 		c.WriteHL(c.HL() + 1)
 	case 0x2B: // DEC HL
+		c.triggerOAMBug(c.HL())
 		c.WriteHL(c.HL() - 1)
 	case 0x2C: // INC L
 		c.inc(&c.L)
@@ -263,70 +352,41 @@ func (c *CPU) Execute(opcode byte) {
 
 	// 0x3X
 	case 0x30: // JR NC, s8
-		if (c.F & FLAG_CARRY) != 0 {
-			c.jr()
-		}
+		c.jrIf(c.F&FLAG_CARRY == 0)
 	case 0x31: // LD SP,d16
-		low := c.mem.Read(c.PC)
-		high := c.mem.Read(c.PC + 1)
+		low := c.bus.Read(c.PC)
+		high := c.bus.Read(c.PC + 1)
 		c.SP = uint16(high)<<8 | uint16(low)
 		c.PC += 2
 	case 0x32: // LD (HL-),A
-		c.mem.Write(c.HL(), c.A)
+		c.bus.Write(c.HL(), c.A)
 		c.WriteHL(c.HL() - 1)
 	case 0x33: // INC SP
+		c.triggerOAMBug(c.SP)
 		c.SP++
 	case 0x34: // INC (HL)
-		val := c.mem.Read(c.HL())
-		old := val
-		val++
-		c.mem.Write(c.HL(), val)
-
-		c.F &= 0x1F
-		if val == 0 {
-			c.F |= FLAG_ZERO
-		}
-		if old&0x0F == 0x0F {
-			c.F |= FLAG_HALFCARRY
-		}
+		val := c.bus.Read(c.HL())
+		c.inc(&val)
+		c.bus.Write(c.HL(), val)
 	case 0x35: // DEC (HL)
-		val := c.mem.Read(c.HL())
-		old := val
-		val--
-		c.mem.Write(c.HL(), val)
-
-		if val == 0 {
-			c.F |= FLAG_ZERO
-		}
-		c.F |= FLAG_SUBTRACT
-		if old&0x0F == 0 {
-			c.F |= FLAG_HALFCARRY
-		}
+		val := c.bus.Read(c.HL())
+		c.dec(&val)
+		c.bus.Write(c.HL(), val)
 	case 0x36: // LD (HL),d8
-		val := c.mem.Read(c.PC)
-		c.mem.Write(c.HL(), val)
+		val := c.bus.Read(c.PC)
+		c.bus.Write(c.HL(), val)
 		c.PC++
 	case 0x37: // SCF
 		c.F = (c.F & FLAG_ZERO) | FLAG_CARRY
 	case 0x38: // JR C,s8
-		if c.F&FLAG_CARRY != 0 {
-			c.jr()
-		}
+		c.jrIf(c.F&FLAG_CARRY != 0)
 	case 0x39: // ADD HL,SP
-		old := c.HL()
-		sum := uint32(c.HL()) + uint32(c.SP)
-		c.WriteHL(uint16(sum & 0xFFFF))
-		c.F &= 0x80
-		if (old&0x00FF)+(uint16(c.SP)&0x00FF) > 0x00FF {
-			c.F |= FLAG_HALFCARRY
-		}
-		if sum > 0xFFFF {
-			c.F |= FLAG_CARRY
-		}
+		c.addHL(c.SP)
 	case 0x3A: // LD A,(HL-)
-		c.A = c.mem.Read(c.HL())
+		c.A = c.bus.Read(c.HL())
 		c.WriteHL(c.HL() - 1)
 	case 0x3B: // DEC SP
+		c.triggerOAMBug(c.SP)
 		c.SP--
 	case 0x3C: // INC A
 		c.inc(&c.A)
@@ -351,7 +411,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x45: // LD B,L
 		c.B = c.L
 	case 0x46: // LD B,(HL)
-		c.B = c.mem.Read(c.HL())
+		c.B = c.bus.Read(c.HL())
 	case 0x47: // LD B,A
 		c.B = c.A
 
@@ -369,7 +429,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x4D: // LD C,L
 		c.C = c.L
 	case 0x4E: // LD C,(HL)
-		c.C = c.mem.Read(c.HL())
+		c.C = c.bus.Read(c.HL())
 	case 0x4F: // LD C,A
 		c.C = c.A
 
@@ -387,7 +447,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x55: // LD D,L
 		c.D = c.L
 	case 0x56: // LD D,(HL)
-		c.D = c.mem.Read(c.HL())
+		c.D = c.bus.Read(c.HL())
 	case 0x57: // LD D,A
 		c.D = c.A
 
@@ -405,7 +465,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x5D: // LD E,L
 		c.E = c.L
 	case 0x5E: // LD E,(HL)
-		c.E = c.mem.Read(c.HL())
+		c.E = c.bus.Read(c.HL())
 	case 0x5F: // LD E,A
 		c.E = c.A
 
@@ -423,7 +483,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x65: // LD H,L
 		c.H = c.L
 	case 0x66: // LD H,(HL)
-		c.H = c.mem.Read(c.HL())
+		c.H = c.bus.Read(c.HL())
 	case 0x67: // LD H,A
 		c.H = c.A
 
@@ -441,27 +501,27 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x6D: // LD L,L
 		// NOP effectively
 	case 0x6E: // LD L,(HL)
-		c.L = c.mem.Read(c.HL())
+		c.L = c.bus.Read(c.HL())
 	case 0x6F: // LD L,A
 		c.L = c.A
 
 	// 0x7X - Load instructions to/from memory and A
 	case 0x70: // LD (HL),B
-		c.mem.Write(c.HL(), c.B)
+		c.bus.Write(c.HL(), c.B)
 	case 0x71: // LD (HL),C
-		c.mem.Write(c.HL(), c.C)
+		c.bus.Write(c.HL(), c.C)
 	case 0x72: // LD (HL),D
-		c.mem.Write(c.HL(), c.D)
+		c.bus.Write(c.HL(), c.D)
 	case 0x73: // LD (HL),E
-		c.mem.Write(c.HL(), c.E)
+		c.bus.Write(c.HL(), c.E)
 	case 0x74: // LD (HL),H
-		c.mem.Write(c.HL(), c.H)
+		c.bus.Write(c.HL(), c.H)
 	case 0x75: // LD (HL),L
-		c.mem.Write(c.HL(), c.L)
+		c.bus.Write(c.HL(), c.L)
 	case 0x76: // HALT
-		c.stopped = true
+		c.halt(enableIME)
 	case 0x77: // LD (HL),A
-		c.mem.Write(c.HL(), c.A)
+		c.bus.Write(c.HL(), c.A)
 	case 0x78: // LD A,B
 		c.A = c.B
 	case 0x79: // LD A,C
@@ -475,7 +535,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x7D: // LD A,L
 		c.A = c.L
 	case 0x7E: // LD A,(HL)
-		c.A = c.mem.Read(c.HL())
+		c.A = c.bus.Read(c.HL())
 	case 0x7F: // LD A,A
 		// NOP effectively
 
@@ -493,7 +553,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x85: // ADD A,L
 		c.add(&c.A, c.L)
 	case 0x86: // ADD A,(HL)
-		c.add(&c.A, c.mem.Read(c.HL()))
+		c.add(&c.A, c.bus.Read(c.HL()))
 	case 0x87: // ADD A,A
 		c.add(&c.A, c.A)
 	case 0x88: // ADC A,B
@@ -509,7 +569,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x8D: // ADC A,L
 		c.addCarry(&c.A, c.L)
 	case 0x8E: // ADC A,(HL)
-		c.addCarry(&c.A, c.mem.Read(c.HL()))
+		c.addCarry(&c.A, c.bus.Read(c.HL()))
 	case 0x8F: // ADC A,A
 		c.addCarry(&c.A, c.A)
 
@@ -527,7 +587,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x95: // SUB L ~ SUB A, L
 		c.sub(&c.A, c.L)
 	case 0x96: // SUB (HL) ~ SUB A, (HL)
-		c.sub(&c.A, c.mem.Read(c.HL()))
+		c.sub(&c.A, c.bus.Read(c.HL()))
 	case 0x97: // SUB A ~ SUB A, A
 		c.sub(&c.A, c.A)
 	case 0x98: // SBC A, B
@@ -543,7 +603,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0x9D: // SBC A,L
 		c.subCarry(&c.A, c.L)
 	case 0x9E: // SBC A,(HL)
-		c.subCarry(&c.A, c.mem.Read(c.HL()))
+		c.subCarry(&c.A, c.bus.Read(c.HL()))
 	case 0x9F: // SBC A,A
 		c.subCarry(&c.A, c.A)
 
@@ -561,7 +621,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xA5: // AND L
 		c.and(&c.A, c.L)
 	case 0xA6: // AND (HL)
-		c.and(&c.A, c.mem.Read(c.HL()))
+		c.and(&c.A, c.bus.Read(c.HL()))
 	case 0xA7: // AND A
 		c.and(&c.A, c.A)
 	case 0xA8: // XOR B
@@ -577,7 +637,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xAD: // XOR L
 		c.xor(&c.A, c.L)
 	case 0xAE: // XOR (HL)
-		c.xor(&c.A, c.mem.Read(c.HL()))
+		c.xor(&c.A, c.bus.Read(c.HL()))
 	case 0xAF: // XOR A
 		c.xor(&c.A, c.A)
 
@@ -595,7 +655,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xB5: // OR L
 		c.or(&c.A, c.L)
 	case 0xB6: // OR (HL)
-		c.or(&c.A, c.mem.Read(c.HL()))
+		c.or(&c.A, c.bus.Read(c.HL()))
 	case 0xB7: // OR A
 		c.or(&c.A, c.A)
 	case 0xB8: // CP B
@@ -611,7 +671,7 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xBD: // CP L
 		c.cp(c.A, c.L)
 	case 0xBE: // CP (HL)
-		c.cp(c.A, c.mem.Read(c.HL()))
+		c.cp(c.A, c.bus.Read(c.HL()))
 	case 0xBF: // CP A
 		c.cp(c.A, c.A)
 
@@ -619,32 +679,34 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xC0: // RET NZ
 		if c.F&FLAG_ZERO == 0 {
 			c.ret()
+			c.cycles += RET_TAKEN_CYCLES
 		}
 	case 0xC1: // POP BC
-		low := c.mem.Read(c.SP)
-		high := c.mem.Read(c.SP + 1)
+		low := c.bus.Read(c.SP)
+		high := c.bus.Read(c.SP + 1)
 		c.WriteBC(uint16(high)<<8 | uint16(low))
 		c.SP += 2
 	case 0xC2: // JP NZ, a16
 		if c.F&FLAG_ZERO == 0 {
 			c.jp()
+			c.cycles += JP_TAKEN_CYCLES
 		} else {
-			c.PC++
+			c.PC += 2
 		}
 	case 0xC3: // JP a16
 		c.jp()
 	case 0xC4: // CALL NZ, a16
 		if c.F&FLAG_ZERO == 0 {
 			c.call()
+			c.cycles += CALL_TAKEN_CYCLES
 		} else {
 			c.PC += 2
 		}
 	case 0xC5: // PUSH BC
-		c.SP -= 2
-		c.mem.Write(c.SP, c.C)
-		c.mem.Write(c.SP+1, c.B)
+		c.idle()
+		c.push(uint16(c.B)<<8 | uint16(c.C))
 	case 0xC6: // ADD A, d8
-		c.add(&c.A, c.mem.Read(c.PC))
+		c.add(&c.A, c.bus.Read(c.PC))
 		c.PC++
 	case 0xC7: // RST 0
 		c.rst()
@@ -652,25 +714,28 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xC8: // RET Z
 		if c.F&FLAG_ZERO != 0 {
 			c.ret()
+			c.cycles += RET_TAKEN_CYCLES
 		}
 	case 0xC9: // RET
 		c.ret()
 	case 0xCA: // JP Z, a16
 		if c.F&FLAG_ZERO != 0 {
 			c.jp()
+			c.cycles += JP_TAKEN_CYCLES
 		} else {
 			c.PC += 2
 		}
 	case 0xCC: // CALL Z, a16
 		if c.F&FLAG_ZERO != 0 {
 			c.call()
+			c.cycles += CALL_TAKEN_CYCLES
 		} else {
 			c.PC += 2
 		}
 	case 0xCD: // CALL a16
 		c.call()
 	case 0xCE: // ADC A, d8
-		c.addCarry(&c.A, c.mem.Read(c.PC))
+		c.addCarry(&c.A, c.bus.Read(c.PC))
 		c.PC++
 	case 0xCF: // RST 1
 		c.rst()
@@ -680,32 +745,34 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xD0: // RET NC
 		if c.F&FLAG_CARRY == 0 {
 			c.ret()
+			c.cycles += RET_TAKEN_CYCLES
 		}
 	case 0xD1: // POP DE
-		low := c.mem.Read(c.SP)
-		high := c.mem.Read(c.SP + 1)
+		low := c.bus.Read(c.SP)
+		high := c.bus.Read(c.SP + 1)
 		c.WriteDE(uint16(high)<<8 | uint16(low))
 		c.SP += 2
 	case 0xD2: // JP NC, a16
 		if c.F&FLAG_CARRY == 0 {
 			c.jp()
+			c.cycles += JP_TAKEN_CYCLES
 		} else {
 			c.PC += 2
 		}
 	case 0xD3: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xD3")
+		c.lockUp(0xD3)
 	case 0xD4: // CALL NC, a16
 		if c.F&FLAG_CARRY == 0 {
 			c.call()
+			c.cycles += CALL_TAKEN_CYCLES
 		} else {
 			c.PC += 2
 		}
 	case 0xD5: // PUSH DE
-		c.SP -= 2
-		c.mem.Write(c.SP, c.E)
-		c.mem.Write(c.SP+1, c.D)
+		c.idle()
+		c.push(uint16(c.D)<<8 | uint16(c.E))
 	case 0xD6: // SUB d8
-		c.sub(&c.A, c.mem.Read(c.PC))
+		c.sub(&c.A, c.bus.Read(c.PC))
 		c.PC++
 	case 0xD7: // RST 2
 		c.rst()
@@ -713,28 +780,31 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xD8: // RET C
 		if c.F&FLAG_CARRY != 0 {
 			c.ret()
+			c.cycles += RET_TAKEN_CYCLES
 		}
 	case 0xD9: // RETI
 		c.ret()
-		c.IME = true // Enable interrupts
+		c.setIME(true, IME_RETI)
 	case 0xDA: // JP C, a16
 		if c.F&FLAG_CARRY != 0 {
 			c.jp()
+			c.cycles += JP_TAKEN_CYCLES
 		} else {
 			c.PC += 2
 		}
 	case 0xDB: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xDB")
+		c.lockUp(0xDB)
 	case 0xDC: // CALL C, a16
 		if c.F&FLAG_CARRY != 0 {
 			c.call()
+			c.cycles += CALL_TAKEN_CYCLES
 		} else {
 			c.PC += 2
 		}
 	case 0xDD: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xDD")
+		c.lockUp(0xDD)
 	case 0xDE: // SBC A, d8
-		c.subCarry(&c.A, c.mem.Read(c.PC))
+		c.subCarry(&c.A, c.bus.Read(c.PC))
 		c.PC++
 	case 0xDF: // RST 3
 		c.rst()
@@ -742,33 +812,32 @@ func (c *CPU) Execute(opcode byte) {
 
 	// 0xEX - LD, PUSH, etc.
 	case 0xE0: // LD (a8), A
-		addr := 0xFF00 + uint16(c.mem.Read(c.PC))
-		c.mem.Write(addr, c.A)
+		addr := 0xFF00 + uint16(c.bus.Read(c.PC))
+		c.bus.Write(addr, c.A)
 		c.PC++
 	case 0xE1: // POP HL
-		low := c.mem.Read(c.SP)
-		high := c.mem.Read(c.SP + 1)
+		low := c.bus.Read(c.SP)
+		high := c.bus.Read(c.SP + 1)
 		c.WriteHL(uint16(high)<<8 | uint16(low))
 		c.SP += 2
 	case 0xE2: // LD (C), A
 		addr := 0xFF00 + uint16(c.C)
-		c.mem.Write(addr, c.A)
+		c.bus.Write(addr, c.A)
 	case 0xE3: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xE3")
+		c.lockUp(0xE3)
 	case 0xE4: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xE4")
+		c.lockUp(0xE4)
 	case 0xE5: // PUSH HL
-		c.SP -= 2
-		c.mem.Write(c.SP, c.L)
-		c.mem.Write(c.SP+1, c.H)
+		c.idle()
+		c.push(uint16(c.H)<<8 | uint16(c.L))
 	case 0xE6: // AND d8
-		c.and(&c.A, c.mem.Read(c.PC))
+		c.and(&c.A, c.bus.Read(c.PC))
 		c.PC++
 	case 0xE7: // RST 4
 		c.rst()
 		c.PC = 0x0020
 	case 0xE8: // ADD SP, r8
-		offset := int8(c.mem.Read(c.PC))
+		offset := int8(c.bus.Read(c.PC))
 		c.PC++
 		oldSP := c.SP
 		c.SP = uint16(int32(c.SP) + int32(offset))
@@ -782,17 +851,17 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xE9: // JP (HL)
 		c.PC = c.HL()
 	case 0xEA: // LD (a16), A
-		addr := uint16(c.mem.Read(c.PC)) | uint16(c.mem.Read(c.PC+1))<<8
-		c.mem.Write(addr, c.A)
+		addr := uint16(c.bus.Read(c.PC)) | uint16(c.bus.Read(c.PC+1))<<8
+		c.bus.Write(addr, c.A)
 		c.PC += 2
 	case 0xEB: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xEB")
+		c.lockUp(0xEB)
 	case 0xEC: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xEC")
+		c.lockUp(0xEC)
 	case 0xED: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xED")
+		c.lockUp(0xED)
 	case 0xEE: // XOR d8
-		c.xor(&c.A, c.mem.Read(c.PC))
+		c.xor(&c.A, c.bus.Read(c.PC))
 		c.PC++
 	case 0xEF: // RST 5
 		c.rst()
@@ -800,34 +869,34 @@ func (c *CPU) Execute(opcode byte) {
 
 	// 0xFX - LD, CP, etc.
 	case 0xF0: // LDH A, (a8)
-		addr := 0xFF00 + uint16(c.mem.Read(c.PC))
-		c.A = c.mem.Read(addr)
+		addr := 0xFF00 + uint16(c.bus.Read(c.PC))
+		c.A = c.bus.Read(addr)
 		c.PC++
 	case 0xF1: // POP AF
-		low := c.mem.Read(c.SP)
-		high := c.mem.Read(c.SP + 1)
+		low := c.bus.Read(c.SP)
+		high := c.bus.Read(c.SP + 1)
 		c.A = high
 		c.F = low & 0xF0
 		c.SP += 2
 	case 0xF2: // LD A, (C)
 		addr := 0xFF00 + uint16(c.C)
-		c.A = c.mem.Read(addr)
+		c.A = c.bus.Read(addr)
 	case 0xF3: // DI
-		c.IME = false // Disable interrupts
+		c.setIME(false, IME_DI)
+		c.imeScheduled = false
 	case 0xF4: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xF4")
+		c.lockUp(0xF4)
 	case 0xF5: // PUSH AF
-		c.SP -= 2
-		c.mem.Write(c.SP, c.F)
-		c.mem.Write(c.SP+1, c.A)
+		c.idle()
+		c.push(uint16(c.A)<<8 | uint16(c.F))
 	case 0xF6: // OR d8
-		c.or(&c.A, c.mem.Read(c.PC))
+		c.or(&c.A, c.bus.Read(c.PC))
 		c.PC++
 	case 0xF7: // RST 6
 		c.rst()
 		c.PC = 0x0030
 	case 0xF8: // LD HL, SP+s8
-		offset := int8(c.mem.Read(c.PC))
+		offset := int8(c.bus.Read(c.PC))
 		c.PC++
 		result := uint16(int32(c.SP) + int32(offset))
 		c.WriteHL(result)
@@ -841,17 +910,17 @@ func (c *CPU) Execute(opcode byte) {
 	case 0xF9: // LD SP, HL
 		c.SP = c.HL()
 	case 0xFA: // LD A, (a16)
-		addr := uint16(c.mem.Read(c.PC)) | uint16(c.mem.Read(c.PC+1))<<8
-		c.A = c.mem.Read(addr)
+		addr := uint16(c.bus.Read(c.PC)) | uint16(c.bus.Read(c.PC+1))<<8
+		c.A = c.bus.Read(addr)
 		c.PC += 2
 	case 0xFB: // EI
-		c.IME = true // Enable interrupts
+		c.imeScheduled = true // Enable interrupts after the next instruction
 	case 0xFC: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xFC")
+		c.lockUp(0xFC)
 	case 0xFD: // Unused (illegal opcode)
-		log.Fatalf("Illegal opcode: 0xFD")
+		c.lockUp(0xFD)
 	case 0xFE: // CP d8
-		c.cp(c.A, c.mem.Read(c.PC))
+		c.cp(c.A, c.bus.Read(c.PC))
 		c.PC++
 	case 0xFF: // RST 7
 		c.rst()
@@ -862,12 +931,20 @@ func (c *CPU) Execute(opcode byte) {
 	default:
 		log.Fatalf("opcode unhandled %04X\n", opcode)
 	}
-	slog.Debug(fmt.Sprintf("opcode: 0x%04X, PC: 0x%04X  A: 0x%02X  B: 0x%02X  F: 0x%02X", opcode, c.PC, c.A, c.B, c.F))
+	if enableIME && c.imeScheduled {
+		c.setIME(true, IME_EI)
+		c.imeScheduled = false
+	}
+	if c.logger.Enabled(context.Background(), slog.LevelDebug) {
+		c.logger.Debug(fmt.Sprintf("opcode: 0x%04X, PC: 0x%04X  A: 0x%02X  B: 0x%02X  F: 0x%02X", opcode, c.PC, c.A, c.B, c.F))
+	}
+	return c.cycles
 }
 
 func (c *CPU) handleCBx() {
-	opcode := c.mem.Read(c.PC)
+	opcode := c.bus.Read(c.PC)
 	c.PC++
+	c.cycles += cbCycles(opcode)
 
 	switch opcode {
 	case 0x00: // RLC B
@@ -883,9 +960,9 @@ func (c *CPU) handleCBx() {
 	case 0x05: // RLC L
 		c.rlc(&c.L)
 	case 0x06: // RLC (HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.rlc(&val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x07: // RLC A
 		c.rlc(&c.A)
 	case 0x08: // RRC B
@@ -901,9 +978,9 @@ func (c *CPU) handleCBx() {
 	case 0x0D: // RRC L
 		c.rrc(&c.L)
 	case 0x0E: // RRC (HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.rrc(&val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x0F: // RRC A
 		c.rrc(&c.A)
 	case 0x10: // RL B
@@ -919,9 +996,9 @@ func (c *CPU) handleCBx() {
 	case 0x15: // RL L
 		c.rl(&c.L)
 	case 0x16: // RL (HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.rl(&val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x17: // RL A
 		c.rl(&c.A)
 	case 0x18: // RR B
@@ -937,9 +1014,9 @@ func (c *CPU) handleCBx() {
 	case 0x1D: // RR L
 		c.rr(&c.L)
 	case 0x1E: // RR (HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.rr(&val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x1F: // RR A
 		c.rr(&c.A)
 
@@ -956,9 +1033,9 @@ func (c *CPU) handleCBx() {
 	case 0x25: // SLA L
 		c.sla(&c.L)
 	case 0x26: // SLA (HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.sla(&val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x27: // SLA A
 		c.sla(&c.A)
 	case 0x28: // SRA B
@@ -974,9 +1051,9 @@ func (c *CPU) handleCBx() {
 	case 0x2D: // SRA L
 		c.sra(&c.L)
 	case 0x2E: // SRA (HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.sra(&val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x2F: // SRA A
 		c.sra(&c.A)
 
@@ -993,9 +1070,9 @@ func (c *CPU) handleCBx() {
 	case 0x35: // SWAP L
 		c.swap(&c.L)
 	case 0x36: // SWAP (HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.swap(&val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x37: // SWAP A
 		c.swap(&c.A)
 	case 0x38: // SRL B
@@ -1011,9 +1088,9 @@ func (c *CPU) handleCBx() {
 	case 0x3D: // SRL L
 		c.srl(&c.L)
 	case 0x3E: // SRL (HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.srl(&val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x3F: // SRL A
 		c.srl(&c.A)
 
@@ -1031,7 +1108,7 @@ func (c *CPU) handleCBx() {
 	case 0x45: // BIT 0,L
 		c.bit(0, c.L)
 	case 0x46: // BIT 0,(HL)
-		c.bit(0, c.mem.Read(c.HL()))
+		c.bit(0, c.bus.Read(c.HL()))
 	case 0x47: // BIT 0,A
 		c.bit(0, c.A)
 
@@ -1048,7 +1125,7 @@ func (c *CPU) handleCBx() {
 	case 0x4D: // BIT 1,L
 		c.bit(1, c.L)
 	case 0x4E: // BIT 1,(HL)
-		c.bit(1, c.mem.Read(c.HL()))
+		c.bit(1, c.bus.Read(c.HL()))
 	case 0x4F: // BIT 1,A
 		c.bit(1, c.A)
 
@@ -1065,7 +1142,7 @@ func (c *CPU) handleCBx() {
 	case 0x55: // BIT 2,L
 		c.bit(2, c.L)
 	case 0x56: // BIT 2,(HL)
-		c.bit(2, c.mem.Read(c.HL()))
+		c.bit(2, c.bus.Read(c.HL()))
 	case 0x57: // BIT 2,A
 		c.bit(2, c.A)
 
@@ -1082,7 +1159,7 @@ func (c *CPU) handleCBx() {
 	case 0x5D: // BIT 3,L
 		c.bit(3, c.L)
 	case 0x5E: // BIT 3,(HL)
-		c.bit(3, c.mem.Read(c.HL()))
+		c.bit(3, c.bus.Read(c.HL()))
 	case 0x5F: // BIT 3,A
 		c.bit(3, c.A)
 
@@ -1099,7 +1176,7 @@ func (c *CPU) handleCBx() {
 	case 0x65: // BIT 4,L
 		c.bit(4, c.L)
 	case 0x66: // BIT 4,(HL)
-		c.bit(4, c.mem.Read(c.HL()))
+		c.bit(4, c.bus.Read(c.HL()))
 	case 0x67: // BIT 4,A
 		c.bit(4, c.A)
 
@@ -1116,7 +1193,7 @@ func (c *CPU) handleCBx() {
 	case 0x6D: // BIT 5,L
 		c.bit(5, c.L)
 	case 0x6E: // BIT 5,(HL)
-		c.bit(5, c.mem.Read(c.HL()))
+		c.bit(5, c.bus.Read(c.HL()))
 	case 0x6F: // BIT 5,A
 		c.bit(5, c.A)
 
@@ -1133,7 +1210,7 @@ func (c *CPU) handleCBx() {
 	case 0x75: // BIT 6,L
 		c.bit(6, c.L)
 	case 0x76: // BIT 6,(HL)
-		c.bit(6, c.mem.Read(c.HL()))
+		c.bit(6, c.bus.Read(c.HL()))
 	case 0x77: // BIT 6,A
 		c.bit(6, c.A)
 
@@ -1150,7 +1227,7 @@ func (c *CPU) handleCBx() {
 	case 0x7D: // BIT 7,L
 		c.bit(7, c.L)
 	case 0x7E: // BIT 7,(HL)
-		c.bit(7, c.mem.Read(c.HL()))
+		c.bit(7, c.bus.Read(c.HL()))
 	case 0x7F: // BIT 7,A
 		c.bit(7, c.A)
 
@@ -1168,9 +1245,9 @@ func (c *CPU) handleCBx() {
 	case 0x85: // RES 0,L
 		c.res(0, &c.L)
 	case 0x86: // RES 0,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.res(0, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x87: // RES 0,A
 		c.res(0, &c.A)
 	case 0x88: // RES 1,B
@@ -1186,9 +1263,9 @@ func (c *CPU) handleCBx() {
 	case 0x8D: // RES 1,L
 		c.res(1, &c.L)
 	case 0x8E: // RES 1,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.res(1, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x8F: // RES 1,A
 		c.res(1, &c.A)
 	case 0x90: // RES 2,B
@@ -1204,9 +1281,9 @@ func (c *CPU) handleCBx() {
 	case 0x95: // RES 2,L
 		c.res(2, &c.L)
 	case 0x96: // RES 2,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.res(2, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x97: // RES 2,A
 		c.res(2, &c.A)
 	case 0x98: // RES 3,B
@@ -1222,9 +1299,9 @@ func (c *CPU) handleCBx() {
 	case 0x9D: // RES 3,L
 		c.res(3, &c.L)
 	case 0x9E: // RES 3,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.res(3, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0x9F: // RES 3,A
 		c.res(3, &c.A)
 	case 0xA0: // RES 4,B
@@ -1240,9 +1317,9 @@ func (c *CPU) handleCBx() {
 	case 0xA5: // RES 4,L
 		c.res(4, &c.L)
 	case 0xA6: // RES 4,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.res(4, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xA7: // RES 4,A
 		c.res(4, &c.A)
 	case 0xA8: // RES 5,B
@@ -1258,9 +1335,9 @@ func (c *CPU) handleCBx() {
 	case 0xAD: // RES 5,L
 		c.res(5, &c.L)
 	case 0xAE: // RES 5,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.res(5, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xAF: // RES 5,A
 		c.res(5, &c.A)
 	case 0xB0: // RES 6,B
@@ -1276,9 +1353,9 @@ func (c *CPU) handleCBx() {
 	case 0xB5: // RES 6,L
 		c.res(6, &c.L)
 	case 0xB6: // RES 6,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.res(6, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xB7: // RES 6,A
 		c.res(6, &c.A)
 	case 0xB8: // RES 7,B
@@ -1294,9 +1371,9 @@ func (c *CPU) handleCBx() {
 	case 0xBD: // RES 7,L
 		c.res(7, &c.L)
 	case 0xBE: // RES 7,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.res(7, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xBF: // RES 7,A
 		c.res(7, &c.A)
 
@@ -1314,9 +1391,9 @@ func (c *CPU) handleCBx() {
 	case 0xC5: // SET 0,L
 		c.set(0, &c.L)
 	case 0xC6: // SET 0,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.set(0, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xC7: // SET 0,A
 		c.set(0, &c.A)
 	case 0xC8: // SET 1,B
@@ -1332,9 +1409,9 @@ func (c *CPU) handleCBx() {
 	case 0xCD: // SET 1,L
 		c.set(1, &c.L)
 	case 0xCE: // SET 1,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.set(1, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xCF: // SET 1,A
 		c.set(1, &c.A)
 	case 0xD0: // SET 2,B
@@ -1350,9 +1427,9 @@ func (c *CPU) handleCBx() {
 	case 0xD5: // SET 2,L
 		c.set(2, &c.L)
 	case 0xD6: // SET 2,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.set(2, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xD7: // SET 2,A
 		c.set(2, &c.A)
 	case 0xD8: // SET 3,B
@@ -1368,9 +1445,9 @@ func (c *CPU) handleCBx() {
 	case 0xDD: // SET 3,L
 		c.set(3, &c.L)
 	case 0xDE: // SET 3,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.set(3, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xDF: // SET 3,A
 		c.set(3, &c.A)
 	case 0xE0: // SET 4,B
@@ -1386,9 +1463,9 @@ func (c *CPU) handleCBx() {
 	case 0xE5: // SET 4,L
 		c.set(4, &c.L)
 	case 0xE6: // SET 4,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.set(4, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xE7: // SET 4,A
 		c.set(4, &c.A)
 	case 0xE8: // SET 5,B
@@ -1404,9 +1481,9 @@ func (c *CPU) handleCBx() {
 	case 0xED: // SET 5,L
 		c.set(5, &c.L)
 	case 0xEE: // SET 5,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.set(5, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xEF: // SET 5,A
 		c.set(5, &c.A)
 	case 0xF0: // SET 6,B
@@ -1422,9 +1499,9 @@ func (c *CPU) handleCBx() {
 	case 0xF5: // SET 6,L
 		c.set(6, &c.L)
 	case 0xF6: // SET 6,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.set(6, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xF7: // SET 6,A
 		c.set(6, &c.A)
 	case 0xF8: // SET 7,B
@@ -1440,9 +1517,9 @@ func (c *CPU) handleCBx() {
 	case 0xFD: // SET 7,L
 		c.set(7, &c.L)
 	case 0xFE: // SET 7,(HL)
-		val := c.mem.Read(c.HL())
+		val := c.bus.Read(c.HL())
 		c.set(7, &val)
-		c.mem.Write(c.HL(), val)
+		c.bus.Write(c.HL(), val)
 	case 0xFF: // SET 7,A
 		c.set(7, &c.A)
 
@@ -0,0 +1,657 @@
+package cpu_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+func TestCPU_RunInstructions(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{
+		0x00,       // NOP
+		0x06, 0x05, // LD B,0x05
+		0x04,       // INC B
+		0xCB, 0x00, // RLC B
+		0x00, // NOP
+	})
+
+	cycles := c.RunInstructions(4)
+
+	if c.PC != 0x0106 {
+		t.Errorf("PC = %04X, want 0106", c.PC)
+	}
+	if cycles != 4+8+4+8 {
+		t.Errorf("cycles = %d, want %d", cycles, 4+8+4+8)
+	}
+}
+
+func BenchmarkCPU_RunInstructions(b *testing.B) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{
+		0x04,       // INC B
+		0x18, 0xFD, // JR -3
+	})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.RunInstructions(1000)
+	}
+}
+
+func TestCPU_RunawayDetection(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{0x3E, 0x01}) // LD A,0x01, then zeroed memory
+
+	fired := -1
+	c.OnRunaway(func(pc uint16) { fired = int(pc) })
+	c.SetRunawayDetection(8)
+
+	c.RunInstructions(1 + 8)
+	if fired != -1 {
+		t.Fatalf("detection fired after 8 NOPs at %04X", fired)
+	}
+	c.Step()
+	if fired != 0x010A {
+		t.Errorf("detection fired at %04X, want 010A", fired)
+	}
+}
+
+func TestCPU_RunawayDetectionSelfLoop(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{0xC3, 0x00, 0x01}) // JP 0x0100
+
+	fired := false
+	c.OnRunaway(func(pc uint16) { fired = true })
+	c.SetRunawayDetection(4)
+
+	c.RunInstructions(5)
+	if !fired {
+		t.Error("self loop with interrupts disabled not detected")
+	}
+
+	fired = false
+	c.IME = true
+	c.RunInstructions(10)
+	if fired {
+		t.Error("self loop waiting on interrupts reported as runaway")
+	}
+}
+
+func TestCPU_EIDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		// instructions executed before the interrupt is serviced, 0 = never
+		serviceAfter int
+		returnTo     uint16
+	}{
+		{"EI; NOP", []byte{0xFB, 0x00, 0x00}, 2, 0x0102},
+		{"EI; DI", []byte{0xFB, 0xF3, 0x00}, 0, 0},
+		{"EI; EI", []byte{0xFB, 0xFB, 0x00}, 2, 0x0102},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mem := mmu.New()
+			c := cpu.New(mem)
+			mem.WriteBytes(0x0100, tc.program)
+			mem.Write(cpu.ADDR_IE, cpu.INT_TIMER)
+			mem.Write(cpu.ADDR_IF, cpu.INT_TIMER)
+
+			serviced := 0
+			for i := 1; i <= len(tc.program) && serviced == 0; i++ {
+				c.Step()
+				if c.HandleInterrupts() > 0 {
+					serviced = i
+				}
+			}
+
+			if serviced != tc.serviceAfter {
+				t.Fatalf("interrupt serviced after instruction %d, want %d", serviced, tc.serviceAfter)
+			}
+			if serviced == 0 {
+				return
+			}
+			if ret := uint16(mem.Read(c.SP)) | uint16(mem.Read(c.SP+1))<<8; ret != tc.returnTo {
+				t.Errorf("pushed return address %04X, want %04X", ret, tc.returnTo)
+			}
+			if c.PC != 0x0050 {
+				t.Errorf("PC = %04X, want 0050", c.PC)
+			}
+		})
+	}
+}
+
+func TestCPU_String(t *testing.T) {
+	c := cpu.New(mmu.New())
+	if got, want := c.String(), "AF=01B0 BC=0013 DE=00D8 HL=014D SP=FFFE PC=0100 [Z-HC] IME=0"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	c.SetRegisters(cpu.Registers{A: 0xFF, F: cpu.FLAG_SUBTRACT, B: 0x12, C: 0x34, PC: 0xC000, SP: 0xDFF0, IME: true})
+	if got, want := c.String(), "AF=FF40 BC=1234 DE=0000 HL=0000 SP=DFF0 PC=C000 [-N--] IME=1"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	var _ fmt.Stringer = c
+}
+
+func TestCPU_HaltWaitsForInterrupt(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{0x76, 0x04}) // HALT; INC B
+	mem.Write(cpu.ADDR_IE, cpu.INT_TIMER)
+	c.IME = true
+
+	c.Step()
+	for range 5 {
+		if got := c.Step(); got != 4 {
+			t.Errorf("halted Step() = %d cycles, want 4", got)
+		}
+		if c.PC != 0x0101 || !c.Halted() {
+			t.Fatalf("PC = %04X halted = %v, want 0101 true", c.PC, c.Halted())
+		}
+	}
+
+	mem.Write(cpu.ADDR_IF, cpu.INT_TIMER)
+	c.Step()
+	if c.Halted() || c.PC != 0x0101 {
+		t.Errorf("after wake PC = %04X halted = %v, want 0101 false", c.PC, c.Halted())
+	}
+	if c.HandleInterrupts() == 0 || c.PC != 0x0050 {
+		t.Errorf("PC = %04X, want the timer interrupt serviced", c.PC)
+	}
+}
+
+func TestCPU_EIHalt(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{0xFB, 0x76, 0x04}) // EI; HALT; INC B
+	mem.Write(0x0050, 0xD9)                          // RETI
+	mem.Write(cpu.ADDR_IE, cpu.INT_TIMER)
+	mem.Write(cpu.ADDR_IF, cpu.INT_TIMER)
+	c.B = 0
+
+	c.Step()
+	c.Step()
+	if c.HandleInterrupts() == 0 || c.PC != 0x0050 {
+		t.Fatalf("PC = %04X, want the timer interrupt serviced after EI; HALT", c.PC)
+	}
+	// the return address is HALT itself, not the byte after it
+	if ret := uint16(mem.Read(c.SP)) | uint16(mem.Read(c.SP+1))<<8; ret != 0x0101 {
+		t.Errorf("pushed return address %04X, want 0101", ret)
+	}
+
+	c.Step() // RETI
+	c.Step() // HALT again, nothing pending now
+	if !c.Halted() || c.PC != 0x0102 {
+		t.Fatalf("PC = %04X halted = %v, want 0102 true", c.PC, c.Halted())
+	}
+
+	mem.Write(cpu.ADDR_IF, cpu.INT_TIMER)
+	c.Step()
+	c.HandleInterrupts()
+	c.Step() // RETI
+	c.Step() // INC B
+	if c.B != 1 || c.PC != 0x0103 {
+		t.Errorf("B = %d PC = %04X, want INC B run once and PC 0103", c.B, c.PC)
+	}
+}
+
+func TestCPU_HaltBug(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{0x76, 0x04, 0x00}) // HALT; INC B; NOP
+	mem.Write(cpu.ADDR_IE, cpu.INT_TIMER)
+	mem.Write(cpu.ADDR_IF, cpu.INT_TIMER)
+	c.B = 0
+
+	// IME clear with an interrupt pending: HALT exits at once and INC B is
+	// fetched twice
+	c.Step()
+	if c.Halted() {
+		t.Fatal("HALT waited with an interrupt pending")
+	}
+	c.Step()
+	c.Step()
+	if c.B != 2 || c.PC != 0x0102 {
+		t.Errorf("B = %d PC = %04X, want 2 and 0102", c.B, c.PC)
+	}
+}
+
+func TestCPU_HandleInterruptsCycles(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	c.IME = true
+
+	if got := c.HandleInterrupts(); got != 0 {
+		t.Errorf("HandleInterrupts() = %d with nothing pending, want 0", got)
+	}
+
+	mem.Write(cpu.ADDR_IE, cpu.INT_VBLANK)
+	mem.Write(cpu.ADDR_IF, cpu.INT_VBLANK)
+	if got := c.HandleInterrupts(); got != cpu.INTERRUPT_SERVICE_CYCLES {
+		t.Errorf("HandleInterrupts() = %d, want %d", got, cpu.INTERRUPT_SERVICE_CYCLES)
+	}
+	if c.PC != 0x0040 || c.IME {
+		t.Errorf("PC = %04X IME = %v, want 0040 false", c.PC, c.IME)
+	}
+}
+
+func TestCPU_IEUpperBits(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	c.IME = true
+
+	mem.Write(cpu.ADDR_IE, 0xFF)
+	if got := mem.Read(cpu.ADDR_IE); got != 0xFF {
+		t.Errorf("IE = %02X, want FF", got)
+	}
+
+	// requests above bit 4 match IE but aren't interrupts
+	mem.Write(cpu.ADDR_IF, 0xE0)
+	if got := c.HandleInterrupts(); got != 0 {
+		t.Errorf("HandleInterrupts() = %d with only bits 5-7 set, want 0", got)
+	}
+
+	mem.Write(cpu.ADDR_IF, 0xE0|cpu.INT_TIMER)
+	c.HandleInterrupts()
+	if c.PC != cpu.InterruptVector(2) {
+		t.Errorf("PC = %04X, want the timer vector %04X", c.PC, cpu.InterruptVector(2))
+	}
+	if got := mem.Read(cpu.ADDR_IE); got != 0xFF {
+		t.Errorf("IE = %02X after servicing, want FF", got)
+	}
+}
+
+// timedBus records in which M-cycle of an operation each access happens.
+type timedBus struct {
+	*mmu.Memory
+	mcycle int
+	writes []int
+}
+
+func (b *timedBus) Idle() { b.mcycle++ }
+
+func (b *timedBus) Read(address uint16) byte {
+	b.mcycle++
+	return b.Memory.Read(address)
+}
+
+func (b *timedBus) Write(address uint16, value byte) {
+	b.writes = append(b.writes, b.mcycle)
+	b.mcycle++
+	b.Memory.Write(address, value)
+}
+
+func TestCPU_InterruptLatency(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	bus := &timedBus{Memory: mem}
+	c.SetBus(bus)
+	c.IME = true
+	c.SP = 0xD000
+	mem.Write(cpu.ADDR_IE, cpu.INT_TIMER)
+	mem.Write(cpu.ADDR_IF, cpu.INT_TIMER)
+
+	if got := c.HandleInterrupts(); got != cpu.INTERRUPT_SERVICE_CYCLES {
+		t.Errorf("HandleInterrupts() = %d, want %d", got, cpu.INTERRUPT_SERVICE_CYCLES)
+	}
+	// two internal M-cycles, then PC is pushed in M-cycles 3 and 4
+	if want := []int{2, 3}; !slices.Equal(bus.writes, want) {
+		t.Errorf("pushes in M-cycles %v (0-based), want %v", bus.writes, want)
+	}
+
+	// the handler's first opcode is fetched once the 20 cycles are spent
+	if c.PC != cpu.InterruptVector(2) {
+		t.Fatalf("PC = %04X, want %04X", c.PC, cpu.InterruptVector(2))
+	}
+}
+
+func TestCPU_CBCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     byte
+		cycles int
+	}{
+		{"SET 0,(HL)", 0xC6, 16},
+		{"BIT 0,(HL)", 0x46, 12},
+		{"RLC (HL)", 0x06, 16},
+		{"SET 0,B", 0xC0, 8},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mem := mmu.New()
+			c := cpu.New(mem)
+			c.H, c.L = 0xC0, 0x00
+			mem.WriteBytes(0x0100, []byte{0xCB, tc.op})
+
+			if got := c.Step(); got != tc.cycles {
+				t.Errorf("Step() = %d cycles, want %d", got, tc.cycles)
+			}
+			if got := cpu.OpcodeCycles(0xCB, tc.op); got != tc.cycles {
+				t.Errorf("OpcodeCycles() = %d, want %d", got, tc.cycles)
+			}
+		})
+	}
+}
+
+// Every CB-prefixed instruction is the 0xCB fetch, the sub-opcode fetch
+// and the operation, each memory access in an M-cycle of its own.
+func TestCPU_CBPrefixFetches(t *testing.T) {
+	for op := range 256 {
+		mem := mmu.New()
+		c := cpu.New(mem)
+		bus := &timedBus{Memory: mem}
+		c.SetBus(bus)
+		c.H, c.L = 0xC0, 0x00
+		mem.WriteBytes(0x0100, []byte{0xCB, byte(op)})
+
+		operation := 4
+		if op&0x07 == 0x06 {
+			operation = 12
+			if op >= 0x40 && op < 0x80 {
+				operation = 8
+			}
+		}
+		got := c.Step()
+		if got != 4+operation {
+			t.Errorf("CB %02X: Step() = %d cycles, want 4 + %d", op, got, operation)
+		}
+		if got != 4*bus.mcycle {
+			t.Errorf("CB %02X: %d cycles for %d M-cycles of accesses", op, got, bus.mcycle)
+		}
+		if c.PC != 0x0102 {
+			t.Errorf("CB %02X: PC = %04X, want 0102", op, c.PC)
+		}
+	}
+}
+
+func TestCPU_ALUCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   []byte
+		cycles int
+	}{
+		{"ADD A,B", []byte{0x80}, 4},
+		{"ADD A,(HL)", []byte{0x86}, 8},
+		{"ADD A,d8", []byte{0xC6, 0x01}, 8},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mem := mmu.New()
+			c := cpu.New(mem)
+			c.H, c.L = 0xC0, 0x00
+			mem.WriteBytes(0x0100, tc.code)
+
+			if got := c.Step(); got != tc.cycles {
+				t.Errorf("Step() = %d cycles, want %d", got, tc.cycles)
+			}
+		})
+	}
+
+	// the whole ALU block: the (HL) column and the d8 forms read memory
+	for op := 0x80; op < 0xC0; op++ {
+		want := 4
+		if op&0x07 == 0x06 {
+			want = 8
+		}
+		if got := cpu.OpcodeCycles(byte(op), 0); got != want {
+			t.Errorf("OpcodeCycles(0x%02X) = %d, want %d", op, got, want)
+		}
+	}
+	for op := 0xC6; op <= 0xFE; op += 8 {
+		if got := cpu.OpcodeCycles(byte(op), 0); got != 8 {
+			t.Errorf("OpcodeCycles(0x%02X) = %d, want 8", op, got)
+		}
+	}
+}
+
+func TestCPU_BitHL(t *testing.T) {
+	tests := []struct {
+		name  string
+		value byte
+		flags byte
+	}{
+		{"bit 7 clear", 0x7F, cpu.FLAG_ZERO | cpu.FLAG_HALFCARRY | cpu.FLAG_CARRY},
+		{"bit 7 set", 0x80, cpu.FLAG_HALFCARRY | cpu.FLAG_CARRY},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mem := mmu.New()
+			c := cpu.New(mem)
+			c.H, c.L = 0xC0, 0x00
+			// N set beforehand must be cleared, carry kept
+			c.F = cpu.FLAG_SUBTRACT | cpu.FLAG_CARRY
+			mem.Write(0xC000, tc.value)
+			mem.WriteBytes(0x0100, []byte{0xCB, 0x7E}) // BIT 7,(HL)
+
+			c.Step()
+			if c.F != tc.flags {
+				t.Errorf("F = %02X, want %02X", c.F, tc.flags)
+			}
+			if got := mem.Read(0xC000); got != tc.value {
+				t.Errorf("(HL) = %02X after BIT, want %02X unchanged", got, tc.value)
+			}
+		})
+	}
+
+	// every BIT b,(HL) sets the same flags as BIT b,B on the same value
+	for b := byte(0); b < 8; b++ {
+		for _, value := range []byte{0x00, 0xFF, 1 << b, ^byte(1 << b)} {
+			mem := mmu.New()
+			c := cpu.New(mem)
+			c.B, c.H, c.L = value, 0xC0, 0x00
+			mem.Write(0xC000, value)
+			mem.WriteBytes(0x0100, []byte{0xCB, 0x40 | b<<3, 0xCB, 0x46 | b<<3})
+
+			c.Step()
+			want := c.F
+			c.Step()
+			if c.F != want {
+				t.Errorf("BIT %d,(HL) on %02X: F = %02X, BIT %d,B gave %02X", b, value, c.F, b, want)
+			}
+		}
+	}
+}
+
+func TestCPU_IncDecHL(t *testing.T) {
+	ops := []struct {
+		name  string
+		hl, a byte
+	}{
+		{"INC", 0x34, 0x3C},
+		{"DEC", 0x35, 0x3D},
+	}
+	for _, op := range ops {
+		for _, value := range []byte{0x00, 0x0F, 0xFF, 0x10, 0x01} {
+			// every combination of prior flags must be overwritten alike
+			for _, flags := range []byte{0x00, 0xF0} {
+				mem := mmu.New()
+				c := cpu.New(mem)
+				c.A, c.F, c.H, c.L = value, flags, 0xC0, 0x00
+				mem.Write(0xC000, value)
+				mem.WriteBytes(0x0100, []byte{op.a, op.hl})
+
+				c.Step()
+				wantA, wantF := c.A, c.F
+				c.F = flags
+				c.Step()
+				if got := mem.Read(0xC000); got != wantA {
+					t.Errorf("%s (HL) on %02X = %02X, %s A gave %02X", op.name, value, got, op.name, wantA)
+				}
+				if c.F != wantF {
+					t.Errorf("%s (HL) on %02X with F=%02X: F = %02X, %s A gave %02X", op.name, value, flags, c.F, op.name, wantF)
+				}
+			}
+		}
+	}
+}
+
+func TestCPU_FetchFromHRAMAndIO(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{0xC3, 0x80, 0xFF}) // JP 0xFF80
+	mem.WriteBytes(0xFF80, []byte{
+		0x3E, 0x42, // LD A,0x42
+		0xC3, 0x10, 0xFF, // JP 0xFF10
+	})
+	// a mapped register reads as INC B
+	mem.MapIO(0xFF10, func() byte { return 0x04 }, nil)
+
+	c.RunInstructions(4)
+	if c.A != 0x42 || c.B != 0x01 || c.PC != 0xFF11 {
+		t.Errorf("A = %02X B = %02X PC = %04X, want 42 01 FF11", c.A, c.B, c.PC)
+	}
+}
+
+func TestCPU_IFPersistsWhileDisabled(t *testing.T) {
+	mem := mmu.New()
+	c := cpu.New(mem)
+	mem.WriteBytes(0x0100, []byte{0x00, 0x00}) // NOP; NOP
+	cpu.RequestInterrupt(mem, cpu.INT_TIMER)
+
+	// masked by IE, then by IME: the request stays pending
+	c.IME = true
+	c.Step()
+	if c.HandleInterrupts() != 0 {
+		t.Fatal("interrupt serviced with its IE bit clear")
+	}
+	mem.Write(cpu.ADDR_IE, cpu.INT_TIMER)
+	c.IME = false
+	c.Step()
+	if c.HandleInterrupts() != 0 {
+		t.Fatal("interrupt serviced with IME clear")
+	}
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_TIMER == 0 {
+		t.Fatal("IF bit cleared without servicing")
+	}
+
+	c.IME = true
+	if c.HandleInterrupts() == 0 || c.PC != 0x0050 {
+		t.Errorf("PC = %04X, want the pending timer interrupt serviced", c.PC)
+	}
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_TIMER != 0 {
+		t.Error("IF bit still set after servicing")
+	}
+}
+
+// flatRAM is a 64KB bus without any mapping.
+type flatRAM [0x10000]byte
+
+func (r *flatRAM) Read(address uint16) byte         { return r[address] }
+func (r *flatRAM) Write(address uint16, value byte) { r[address] = value }
+
+func TestExecuteOnce(t *testing.T) {
+	ram := &flatRAM{}
+	ram[0xC000] = 0x80 // ADD A,B
+
+	regs, cycles := cpu.ExecuteOnce(cpu.Registers{A: 0x0F, B: 0x01, PC: 0xC000, SP: 0xFFFE}, ram)
+	if regs.A != 0x10 || regs.F != cpu.FLAG_HALFCARRY || regs.PC != 0xC001 {
+		t.Errorf("ExecuteOnce() = %+v, want A=0x10 F=0x20 PC=0xC001", regs)
+	}
+	if cycles != 4 {
+		t.Errorf("cycles = %d, want 4", cycles)
+	}
+}
+
+// smallRAM mirrors 256 bytes over the whole address space and records
+// the addresses written.
+type smallRAM struct {
+	data   [0x100]byte
+	writes []uint16
+}
+
+func (r *smallRAM) Read(address uint16) byte { return r.data[address&0xFF] }
+func (r *smallRAM) Write(address uint16, value byte) {
+	r.data[address&0xFF] = value
+	r.writes = append(r.writes, address)
+}
+
+func TestCPU_PushWraparound(t *testing.T) {
+	tests := []struct {
+		sp, wantSP uint16
+		// high byte first, each after decrementing SP
+		writes []uint16
+	}{
+		{0x0000, 0xFFFE, []uint16{0xFFFF, 0xFFFE}},
+		{0x0001, 0xFFFF, []uint16{0x0000, 0xFFFF}},
+		{0x0002, 0x0000, []uint16{0x0001, 0x0000}},
+	}
+	for _, tc := range tests {
+		ram := &smallRAM{}
+		ram.data[0x80] = 0xC5 // PUSH BC
+		regs, _ := cpu.ExecuteOnce(cpu.Registers{B: 0x12, C: 0x34, PC: 0x0080, SP: tc.sp}, ram)
+		if regs.SP != tc.wantSP {
+			t.Errorf("SP %04X: SP after PUSH = %04X, want %04X", tc.sp, regs.SP, tc.wantSP)
+		}
+		if !slices.Equal(ram.writes, tc.writes) {
+			t.Errorf("SP %04X: writes to %04X, want %04X", tc.sp, ram.writes, tc.writes)
+		}
+		if hi, lo := ram.data[tc.writes[0]&0xFF], ram.data[tc.writes[1]&0xFF]; hi != 0x12 || lo != 0x34 {
+			t.Errorf("SP %04X: pushed %02X%02X, want 1234", tc.sp, hi, lo)
+		}
+	}
+}
+
+func FuzzCPU(f *testing.F) {
+	regs := []byte{0x01, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D}
+	for _, op := range []byte{0xD3, 0xDB, 0xDD, 0xE3, 0xE4, 0xEB, 0xEC, 0xED, 0xF4, 0xFC, 0xFD} {
+		f.Add([]byte{op}, uint16(0x0100), uint16(0xFFFE), regs)
+	}
+	for _, op := range []byte{0x00, 0x06, 0x16, 0x37, 0x46, 0x7E, 0x86, 0xBE, 0xC6, 0xFF} {
+		f.Add([]byte{0xCB, op}, uint16(0xFFFF), uint16(0x0000), regs)
+	}
+	f.Add([]byte{0x08, 0xFF, 0xFF}, uint16(0xFFFD), uint16(0xFFFF), regs)
+	f.Add([]byte{0xE8, 0x80}, uint16(0xC000), uint16(0x0001), regs)
+
+	f.Fuzz(func(t *testing.T, code []byte, pc, sp uint16, regs []byte) {
+		if len(code) == 0 || len(regs) < 8 {
+			t.Skip()
+		}
+		ram := &smallRAM{}
+		for i, b := range code {
+			ram.data[(pc+uint16(i))&0xFF] = b
+		}
+		in := cpu.Registers{
+			A: regs[0], F: regs[1] & 0xF0, B: regs[2], C: regs[3],
+			D: regs[4], E: regs[5], H: regs[6], L: regs[7],
+			PC: pc, SP: sp,
+		}
+		out, cycles := cpu.ExecuteOnce(in, ram)
+		op := ram.Read(pc)
+
+		if cycles <= 0 {
+			t.Errorf("opcode 0x%02X took %d cycles", op, cycles)
+		}
+		// at most a 16-bit push or LD (a16),SP
+		if len(ram.writes) > 2 {
+			t.Errorf("opcode 0x%02X wrote %d bytes: %04X", op, len(ram.writes), ram.writes)
+		}
+		// jumps may land anywhere, everything else moves past its opcode
+		if !isJump(op) && out.PC == pc {
+			t.Errorf("opcode 0x%02X left PC at 0x%04X", op, pc)
+		}
+	})
+}
+
+// isJump reports whether op may set PC to any value, including its own
+// address.
+func isJump(op byte) bool {
+	switch op {
+	case 0x18, 0x20, 0x28, 0x30, 0x38, // JR
+		0xC2, 0xC3, 0xCA, 0xD2, 0xDA, 0xE9, // JP
+		0xC4, 0xCC, 0xCD, 0xD4, 0xDC, // CALL
+		0xC0, 0xC8, 0xC9, 0xD0, 0xD8, 0xD9, // RET
+		0xC7, 0xCF, 0xD7, 0xDF, 0xE7, 0xEF, 0xF7, 0xFF: // RST
+		return true
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package cpu
+
+// opcodeCycles holds the T-cycles of each opcode. Conditional branches list
+// the not-taken cost, the taken penalty is added while executing. Illegal
+// opcodes only cost their fetch. 0xCB only counts the prefix fetch, see cbCycles.
+var opcodeCycles = [256]int{
+	//  x0  x1  x2  x3  x4  x5  x6  x7  x8  x9  xA  xB  xC  xD  xE  xF
+	4, 12, 8, 8, 4, 4, 8, 4, 20, 8, 8, 8, 4, 4, 8, 4, // 0x
+	4, 12, 8, 8, 4, 4, 8, 4, 12, 8, 8, 8, 4, 4, 8, 4, // 1x
+	8, 12, 8, 8, 4, 4, 8, 4, 8, 8, 8, 8, 4, 4, 8, 4, // 2x
+	8, 12, 8, 8, 12, 12, 12, 4, 8, 8, 8, 8, 4, 4, 8, 4, // 3x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 4x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 5x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 6x
+	8, 8, 8, 8, 8, 8, 4, 8, 4, 4, 4, 4, 4, 4, 8, 4, // 7x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 8x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 9x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // Ax
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // Bx
+	8, 12, 12, 16, 12, 16, 8, 16, 8, 16, 12, 4, 12, 24, 8, 16, // Cx
+	8, 12, 12, 4, 12, 16, 8, 16, 8, 16, 12, 4, 12, 4, 8, 16, // Dx
+	12, 12, 8, 4, 4, 16, 8, 16, 16, 4, 16, 4, 4, 4, 8, 16, // Ex
+	12, 12, 8, 4, 4, 16, 8, 16, 12, 8, 16, 4, 4, 4, 8, 16, // Fx
+}
+
+const (
+	// extra T-cycles when a conditional branch is taken
+	JR_TAKEN_CYCLES   = 4
+	JP_TAKEN_CYCLES   = 4
+	CALL_TAKEN_CYCLES = 12
+	RET_TAKEN_CYCLES  = 12
+)
+
+// cbCycles returns the T-cycles of a CB-prefixed operation, excluding the
+// 4 cycles of the 0xCB prefix fetch itself.
+func cbCycles(opcode byte) int {
+	if opcode&0x07 != 0x06 {
+		return 4
+	}
+	// (HL) operand: BIT only reads, the rest read-modify-write
+	if opcode >= 0x40 && opcode < 0x80 {
+		return 8
+	}
+	return 12
+}
+
+// OpcodeCycles returns the T-cycles of opcode, the not-taken cost for
+// conditional branches. cbOpcode is the byte following a 0xCB prefix and is
+// ignored otherwise.
+func OpcodeCycles(opcode, cbOpcode byte) int {
+	if opcode == 0xCB {
+		return opcodeCycles[opcode] + cbCycles(cbOpcode)
+	}
+	return opcodeCycles[opcode]
+}
@@ -0,0 +1,93 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mnemonics of the unprefixed opcodes. Operand placeholders are replaced
+// while disassembling: d8/a8/r8 take one byte, d16/a16 two.
+var mnemonics = [256]string{
+	"NOP", "LD BC,d16", "LD (BC),A", "INC BC", "INC B", "DEC B", "LD B,d8", "RLCA",
+	"LD (a16),SP", "ADD HL,BC", "LD A,(BC)", "DEC BC", "INC C", "DEC C", "LD C,d8", "RRCA",
+	"STOP", "LD DE,d16", "LD (DE),A", "INC DE", "INC D", "DEC D", "LD D,d8", "RLA",
+	"JR r8", "ADD HL,DE", "LD A,(DE)", "DEC DE", "INC E", "DEC E", "LD E,d8", "RRA",
+	"JR NZ,r8", "LD HL,d16", "LD (HL+),A", "INC HL", "INC H", "DEC H", "LD H,d8", "DAA",
+	"JR Z,r8", "ADD HL,HL", "LD A,(HL+)", "DEC HL", "INC L", "DEC L", "LD L,d8", "CPL",
+	"JR NC,r8", "LD SP,d16", "LD (HL-),A", "INC SP", "INC (HL)", "DEC (HL)", "LD (HL),d8", "SCF",
+	"JR C,r8", "ADD HL,SP", "LD A,(HL-)", "DEC SP", "INC A", "DEC A", "LD A,d8", "CCF",
+	"LD B,B", "LD B,C", "LD B,D", "LD B,E", "LD B,H", "LD B,L", "LD B,(HL)", "LD B,A",
+	"LD C,B", "LD C,C", "LD C,D", "LD C,E", "LD C,H", "LD C,L", "LD C,(HL)", "LD C,A",
+	"LD D,B", "LD D,C", "LD D,D", "LD D,E", "LD D,H", "LD D,L", "LD D,(HL)", "LD D,A",
+	"LD E,B", "LD E,C", "LD E,D", "LD E,E", "LD E,H", "LD E,L", "LD E,(HL)", "LD E,A",
+	"LD H,B", "LD H,C", "LD H,D", "LD H,E", "LD H,H", "LD H,L", "LD H,(HL)", "LD H,A",
+	"LD L,B", "LD L,C", "LD L,D", "LD L,E", "LD L,H", "LD L,L", "LD L,(HL)", "LD L,A",
+	"LD (HL),B", "LD (HL),C", "LD (HL),D", "LD (HL),E", "LD (HL),H", "LD (HL),L", "HALT", "LD (HL),A",
+	"LD A,B", "LD A,C", "LD A,D", "LD A,E", "LD A,H", "LD A,L", "LD A,(HL)", "LD A,A",
+	"ADD A,B", "ADD A,C", "ADD A,D", "ADD A,E", "ADD A,H", "ADD A,L", "ADD A,(HL)", "ADD A,A",
+	"ADC A,B", "ADC A,C", "ADC A,D", "ADC A,E", "ADC A,H", "ADC A,L", "ADC A,(HL)", "ADC A,A",
+	"SUB B", "SUB C", "SUB D", "SUB E", "SUB H", "SUB L", "SUB (HL)", "SUB A",
+	"SBC A,B", "SBC A,C", "SBC A,D", "SBC A,E", "SBC A,H", "SBC A,L", "SBC A,(HL)", "SBC A,A",
+	"AND B", "AND C", "AND D", "AND E", "AND H", "AND L", "AND (HL)", "AND A",
+	"XOR B", "XOR C", "XOR D", "XOR E", "XOR H", "XOR L", "XOR (HL)", "XOR A",
+	"OR B", "OR C", "OR D", "OR E", "OR H", "OR L", "OR (HL)", "OR A",
+	"CP B", "CP C", "CP D", "CP E", "CP H", "CP L", "CP (HL)", "CP A",
+	"RET NZ", "POP BC", "JP NZ,a16", "JP a16", "CALL NZ,a16", "PUSH BC", "ADD A,d8", "RST 00H",
+	"RET Z", "RET", "JP Z,a16", "PREFIX CB", "CALL Z,a16", "CALL a16", "ADC A,d8", "RST 08H",
+	"RET NC", "POP DE", "JP NC,a16", "ILLEGAL_D3", "CALL NC,a16", "PUSH DE", "SUB d8", "RST 10H",
+	"RET C", "RETI", "JP C,a16", "ILLEGAL_DB", "CALL C,a16", "ILLEGAL_DD", "SBC A,d8", "RST 18H",
+	"LDH (a8),A", "POP HL", "LD (C),A", "ILLEGAL_E3", "ILLEGAL_E4", "PUSH HL", "AND d8", "RST 20H",
+	"ADD SP,r8", "JP HL", "LD (a16),A", "ILLEGAL_EB", "ILLEGAL_EC", "ILLEGAL_ED", "XOR d8", "RST 28H",
+	"LDH A,(a8)", "POP AF", "LD A,(C)", "DI", "ILLEGAL_F4", "PUSH AF", "OR d8", "RST 30H",
+	"LD HL,SP+r8", "LD SP,HL", "LD A,(a16)", "EI", "ILLEGAL_FC", "ILLEGAL_FD", "CP d8", "RST 38H",
+}
+
+var (
+	cbOperations = [...]string{"RLC", "RRC", "RL", "RR", "SLA", "SRA", "SWAP", "SRL"}
+	cbRegisters  = [...]string{"B", "C", "D", "E", "H", "L", "(HL)", "A"}
+)
+
+// Disassemble decodes the instruction at address and returns its mnemonic
+// and length in bytes. Relative jumps show their target address.
+func Disassemble(bus Bus, address uint16) (string, int) {
+	opcode := bus.Read(address)
+	if opcode == 0xCB {
+		return cbMnemonic(bus.Read(address + 1)), 2
+	}
+
+	m := mnemonics[opcode]
+	switch {
+	case opcode == 0x10:
+		// STOP skips the byte after it
+		return m, 2
+	case strings.Contains(m, "d16"), strings.Contains(m, "a16"):
+		nn := uint16(bus.Read(address+2))<<8 | uint16(bus.Read(address+1))
+		m = strings.NewReplacer("d16", fmt.Sprintf("$%04X", nn), "a16", fmt.Sprintf("$%04X", nn)).Replace(m)
+		return m, 3
+	case strings.Contains(m, "a8"):
+		return strings.Replace(m, "a8", fmt.Sprintf("$FF%02X", bus.Read(address+1)), 1), 2
+	case strings.Contains(m, "d8"):
+		return strings.Replace(m, "d8", fmt.Sprintf("$%02X", bus.Read(address+1)), 1), 2
+	case strings.HasPrefix(m, "JR"):
+		target := address + 2 + uint16(int8(bus.Read(address+1)))
+		return strings.Replace(m, "r8", fmt.Sprintf("$%04X", target), 1), 2
+	case strings.Contains(m, "r8"):
+		e := int8(bus.Read(address + 1))
+		m = strings.Replace(m, "+r8", fmt.Sprintf("%+d", e), 1)
+		return strings.Replace(m, "r8", fmt.Sprintf("%d", e), 1), 2
+	}
+	return m, 1
+}
+
+func cbMnemonic(op byte) string {
+	reg := cbRegisters[op&0x07]
+	switch op >> 6 {
+	case 0:
+		return cbOperations[op>>3] + " " + reg
+	case 1:
+		return fmt.Sprintf("BIT %d,%s", (op>>3)&0x07, reg)
+	case 2:
+		return fmt.Sprintf("RES %d,%s", (op>>3)&0x07, reg)
+	}
+	return fmt.Sprintf("SET %d,%s", (op>>3)&0x07, reg)
+}
@@ -0,0 +1,140 @@
+package cpu
+
+import "github.com/duyquang6/go-retroid/mmu"
+
+const (
+	INT_VBLANK   byte = 0x01
+	INT_LCD_STAT byte = 0x02
+	INT_TIMER    byte = 0x04
+	INT_SERIAL   byte = 0x08
+	INT_JOYPAD   byte = 0x10
+)
+
+// IE is a plain byte of memory: all 8 bits read back what was written,
+// the top 3 acting as ordinary RAM. Only the low 5 bits enable anything.
+const (
+	ADDR_IF uint16 = 0xFF0F
+	ADDR_IE uint16 = 0xFFFF
+)
+
+// IMECause is what changed IME.
+type IMECause int
+
+const (
+	// EI took effect, after the instruction following it
+	IME_EI IMECause = iota
+	IME_DI
+	IME_RETI
+	// an interrupt was dispatched
+	IME_INTERRUPT
+)
+
+// IMEEnabled reports whether IME allows interrupts to be serviced. A
+// pending EI doesn't count until it takes effect.
+func (c *CPU) IMEEnabled() bool {
+	return c.IME
+}
+
+// OnIMEChange registers a callback invoked whenever an instruction or an
+// interrupt dispatch changes IME, with its new value.
+func (c *CPU) OnIMEChange(fn func(enabled bool, cause IMECause)) {
+	c.onIMEChange = fn
+}
+
+func (c *CPU) setIME(enabled bool, cause IMECause) {
+	if c.IME == enabled {
+		return
+	}
+	c.IME = enabled
+	if c.onIMEChange != nil {
+		c.onIMEChange(enabled, cause)
+	}
+}
+
+// INTERRUPT_SERVICE_CYCLES is the cost of dispatching an interrupt: two
+// internal M-cycles, two to push PC and one to jump to the vector. The
+// handler's first opcode is fetched right after, so an interrupt pending
+// at an instruction boundary runs its handler 20 cycles later.
+const INTERRUPT_SERVICE_CYCLES = 20
+
+// InterruptVector returns the handler address of interrupt n (0 = VBlank ... 4 = Joypad).
+func InterruptVector(n uint8) uint16 {
+	return 0x0040 + uint16(n)*8
+}
+
+// RequestInterrupt raises the request bit of an interrupt in IF.
+func RequestInterrupt(mem *mmu.Memory, bit byte) {
+	mem.Write(ADDR_IF, mem.Read(ADDR_IF)|bit)
+}
+
+// HandleInterrupts services the highest priority pending interrupt if IME
+// allows it: IME is cleared, the request bit in IF is acknowledged, PC is
+// pushed and execution jumps to the interrupt vector. It returns the
+// T-cycles spent, 0 when nothing was serviced.
+//
+// It must only be called between instructions, after Step returned: the
+// CPU never services an interrupt in the middle of one, so an interrupt
+// requested while an instruction runs waits for it to complete.
+func (c *CPU) HandleInterrupts() int {
+	if !c.IME {
+		return 0
+	}
+	pending := c.pending()
+	if pending == 0 {
+		return 0
+	}
+
+	for n := uint8(0); n < 5; n++ {
+		bit := byte(1) << n
+		if pending&bit == 0 {
+			continue
+		}
+		c.setIME(false, IME_INTERRUPT)
+		c.halted, c.stopped = false, false
+		c.acknowledge(bit)
+		c.idle()
+		c.idle()
+		c.rst()
+		c.PC = InterruptVector(n)
+		break
+	}
+	return INTERRUPT_SERVICE_CYCLES
+}
+
+// interrupts returns the memory holding IE and IF. The interrupt
+// controller sits next to the CPU: checking it takes no bus cycle.
+func (c *CPU) interrupts() Bus {
+	if c.mem != nil {
+		return c.mem
+	}
+	return c.bus
+}
+
+// halt runs HALT; eiBefore is set when it follows EI. With no interrupt
+// pending the CPU waits for one. Otherwise HALT does not wait at all:
+//   - IME set: the interrupt is serviced right after HALT
+//   - EI; HALT: IME turns on as HALT completes and the interrupt is
+//     serviced, but the address pushed is HALT's own, so HALT runs again
+//     once the handler returns
+//   - IME clear: the HALT bug, the byte after HALT is fetched twice
+func (c *CPU) halt(eiBefore bool) {
+	switch {
+	case c.pending() == 0 || c.IME:
+		c.halted = true
+	case eiBefore && c.imeScheduled:
+		c.PC--
+	default:
+		c.haltBug = true
+	}
+}
+
+// pending returns the interrupts both requested and enabled.
+func (c *CPU) pending() byte {
+	regs := c.interrupts()
+	return regs.Read(ADDR_IE) & regs.Read(ADDR_IF) & 0x1F
+}
+
+func (c *CPU) acknowledge(bit byte) {
+	regs := c.interrupts()
+	regs.Write(ADDR_IF, regs.Read(ADDR_IF)&^bit)
+}
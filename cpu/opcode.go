@@ -1,7 +1,7 @@
 package cpu
 
 func (c *CPU) ldXNN(reg *byte) {
-	nn := c.mem.Read(c.PC)
+	nn := c.bus.Read(c.PC)
 	*reg = nn
 	c.PC++
 }
@@ -41,39 +41,31 @@ func (c *CPU) add(reg *byte, term byte) {
 	}
 }
 
-func (c *CPU) sub(reg *byte, sub byte) {
-	old := (*reg)
-	res := int16(*reg) - int16(sub)
-	*reg = byte(res & 0xFF)
+// subtract returns a - b - carry and the flags of the subtraction, shared
+// by SUB, SBC and CP so they can't disagree.
+func subtract(a, b, carry byte) (byte, byte) {
+	res := int16(a) - int16(b) - int16(carry)
 
-	c.F = FLAG_SUBTRACT
-	if (old & 0x0F) < (sub & 0x0F) {
-		c.F |= FLAG_HALFCARRY
+	f := FLAG_SUBTRACT
+	if int16(a&0x0F)-int16(b&0x0F)-int16(carry) < 0 {
+		f |= FLAG_HALFCARRY
 	}
-	if *reg == 0 {
-		c.F |= FLAG_ZERO
+	if byte(res) == 0 {
+		f |= FLAG_ZERO
 	}
 	if res < 0 {
-		c.F |= FLAG_CARRY
+		f |= FLAG_CARRY
 	}
+	return byte(res), f
+}
+
+func (c *CPU) sub(reg *byte, sub byte) {
+	*reg, c.F = subtract(*reg, sub, 0)
 }
 
 func (c *CPU) subCarry(reg *byte, sub byte) {
-	old := (*reg)
 	carry := (c.F & FLAG_CARRY) >> 4
-	res := int16(*reg) - int16(sub) - int16(carry)
-	*reg = byte(res & 0xFF)
-
-	c.F = FLAG_SUBTRACT
-	if (old & 0x0F) < (sub&0x0F)+carry {
-		c.F |= FLAG_HALFCARRY
-	}
-	if *reg == 0 {
-		c.F |= FLAG_ZERO
-	}
-	if res < 0 {
-		c.F |= FLAG_CARRY
-	}
+	*reg, c.F = subtract(*reg, sub, carry)
 }
 
 func (c *CPU) and(reg *byte, value byte) {
@@ -100,25 +92,28 @@ func (c *CPU) or(reg *byte, value byte) {
 	}
 }
 
+// cp is SUB without storing the result.
 func (c *CPU) cp(reg byte, value byte) {
-	c.F = FLAG_SUBTRACT
-	if reg == value {
-		c.F |= FLAG_ZERO
-	}
-	if (reg & 0x0F) < (value & 0x0F) {
-		c.F |= FLAG_HALFCARRY
-	}
-	if reg < value {
-		c.F |= FLAG_CARRY
-	}
+	_, c.F = subtract(reg, value, 0)
 }
 
 func (c *CPU) jr() {
-	offset := int8(c.mem.Read(c.PC))
+	offset := int8(c.bus.Read(c.PC))
 	c.PC++
 	c.PC = uint16(int32(c.PC) + int32(offset))
 }
 
+// jrIf takes a relative jump when cond holds. The offset byte is read
+// either way, a jump not taken continues after it.
+func (c *CPU) jrIf(cond bool) {
+	offset := int8(c.bus.Read(c.PC))
+	c.PC++
+	if cond {
+		c.PC = uint16(int32(c.PC) + int32(offset))
+		c.cycles += JR_TAKEN_CYCLES
+	}
+}
+
 func (c *CPU) inc(reg *byte) {
 	oldReg := *reg
 	(*reg)++
@@ -146,32 +141,55 @@ func (c *CPU) dec(reg *byte) {
 }
 
 func (c *CPU) jp() {
-	low := c.mem.Read(c.PC)
-	high := c.mem.Read(c.PC + 1)
+	low := c.bus.Read(c.PC)
+	high := c.bus.Read(c.PC + 1)
 
 	c.PC = (uint16(high) << 8) | uint16(low)
 }
 
 func (c *CPU) ret() {
-	low := c.mem.Read(c.SP)
-	high := c.mem.Read(c.SP + 1)
+	low := c.bus.Read(c.SP)
+	high := c.bus.Read(c.SP + 1)
 	c.PC = uint16(high)<<8 | uint16(low)
 	c.SP += 2
 }
 
+// call reads the target before pushing the address of the next
+// instruction, the push may overwrite the operand.
 func (c *CPU) call() {
-	c.SP -= 2
-	c.mem.Write(c.SP, byte(c.PC&0x00FF))
-	c.mem.Write(c.SP+1, byte((c.PC&0xFF00)>>8))
-	low := c.mem.Read(c.PC)
-	high := c.mem.Read(c.PC + 1)
+	low := c.bus.Read(c.PC)
+	high := c.bus.Read(c.PC + 1)
+	c.PC += 2
+	c.rst()
 	c.PC = uint16(high)<<8 | uint16(low)
 }
 
+// addHL adds a 16-bit value to HL. Z is kept, H is the carry out of bit 11.
+func (c *CPU) addHL(value uint16) {
+	hl := c.HL()
+	sum := uint32(hl) + uint32(value)
+	c.WriteHL(uint16(sum))
+	c.F &= FLAG_ZERO
+	if hl&0x0FFF+value&0x0FFF > 0x0FFF {
+		c.F |= FLAG_HALFCARRY
+	}
+	if sum > 0xFFFF {
+		c.F |= FLAG_CARRY
+	}
+}
+
 func (c *CPU) rst() {
-	c.SP -= 2
-	c.mem.Write(c.SP, byte(c.PC&0x00FF))
-	c.mem.Write(c.SP+1, byte((c.PC&0xFF00)>>8))
+	c.push(c.PC)
+}
+
+// push decrements SP before each write and stores the high byte first, as
+// the hardware does. SP wraps like any 16-bit register: pushing with SP at
+// 0x0000 writes 0xFFFF and 0xFFFE.
+func (c *CPU) push(value uint16) {
+	c.SP--
+	c.bus.Write(c.SP, byte(value>>8))
+	c.SP--
+	c.bus.Write(c.SP, byte(value))
 }
 
 func (c *CPU) rlc(reg *byte) {
@@ -1,5 +1,7 @@
 package cpu
 
+import "fmt"
+
 const (
 	FLAG_ZERO      byte = 0x80
 	FLAG_SUBTRACT  byte = 0x40
@@ -7,6 +9,24 @@ const (
 	FLAG_CARRY     byte = 0x10
 )
 
+// String formats the registers on one line for logs and crash reports, e.g.
+// "AF=01B0 BC=0013 DE=00D8 HL=014D SP=FFFE PC=0100 [Z-HC] IME=0", each
+// flag letter replaced by a dash when clear.
+func (c *CPU) String() string {
+	flags := []byte("ZNHC")
+	for i := range flags {
+		if c.F&(FLAG_ZERO>>i) == 0 {
+			flags[i] = '-'
+		}
+	}
+	ime := 0
+	if c.IME {
+		ime = 1
+	}
+	return fmt.Sprintf("AF=%02X%02X BC=%04X DE=%04X HL=%04X SP=%04X PC=%04X [%s] IME=%d",
+		c.A, c.F, c.BC(), c.DE(), c.HL(), c.SP, c.PC, flags, ime)
+}
+
 func (c *CPU) BC() uint16 {
 	return uint16(c.B)<<8 | uint16(c.C)
 }
@@ -0,0 +1,35 @@
+package cpu
+
+// SetRunawayDetection reports a runaway CPU once it executes more than n
+// consecutive NOPs, or spins more than n times on an instruction jumping to
+// itself while interrupts are disabled. n <= 0 turns detection off.
+func (c *CPU) SetRunawayDetection(n int) {
+	c.runawayLimit = n
+	c.nopRun, c.loopRun = 0, 0
+}
+
+// OnRunaway sets the callback invoked with the PC at which runaway
+// execution was detected.
+func (c *CPU) OnRunaway(fn func(pc uint16)) {
+	c.onRunaway = fn
+}
+
+func (c *CPU) checkRunaway(pc uint16, opcode byte) {
+	if opcode == 0x00 {
+		c.nopRun++
+	} else {
+		c.nopRun = 0
+	}
+	if c.PC == pc && !c.IME {
+		c.loopRun++
+	} else {
+		c.loopRun = 0
+	}
+
+	if c.nopRun > c.runawayLimit || c.loopRun > c.runawayLimit {
+		c.nopRun, c.loopRun = 0, 0
+		if c.onRunaway != nil {
+			c.onRunaway(pc)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package cpu
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// state is the part of the CPU a save state holds. Breakpoints and
+// callbacks belong to the host and are left alone.
+type state struct {
+	Regs                    Registers
+	Halted, Stopped, Locked bool
+	IMEScheduled, HaltBug   bool
+	NopRun, LoopRun         int
+}
+
+func (c *CPU) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state{
+		Regs:   c.Registers(),
+		Halted: c.halted, Stopped: c.stopped, Locked: c.locked,
+		IMEScheduled: c.imeScheduled, HaltBug: c.haltBug,
+		NopRun: c.nopRun, LoopRun: c.loopRun,
+	})
+	return buf.Bytes(), err
+}
+
+func (c *CPU) UnmarshalBinary(data []byte) error {
+	var s state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	c.SetRegisters(s.Regs)
+	c.halted, c.stopped, c.locked = s.Halted, s.Stopped, s.Locked
+	c.imeScheduled, c.haltBug = s.IMEScheduled, s.HaltBug
+	c.nopRun, c.loopRun = s.NopRun, s.LoopRun
+	return nil
+}
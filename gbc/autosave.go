@@ -0,0 +1,73 @@
+package gbc
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var ErrNoBattery = errors.New("cartridge has no battery-backed RAM")
+
+type autoSave struct {
+	path     string
+	interval time.Duration
+	last     time.Time
+	// cycle timestamp of the last check
+	checked uint64
+}
+
+// EnableAutoSave writes battery RAM to path whenever it changed and at
+// least interval passed since the last write, so bursts of writes are
+// saved once. It checks once per frame's worth of cycles, so saves happen
+// with the LCD off too. Stop writes any pending change.
+func (gb *GameBoy) EnableAutoSave(path string, interval time.Duration) error {
+	if gb.cart == nil {
+		return ErrNoCartridge
+	}
+	if !gb.cart.HasBattery() {
+		return ErrNoBattery
+	}
+	gb.autoSave = &autoSave{path: path, interval: interval, last: gb.now(), checked: gb.sched.clock.Load()}
+	return nil
+}
+
+// Stop flushes pending output: the trace file and the battery RAM save.
+func (gb *GameBoy) Stop() error {
+	err := gb.StopTrace()
+	if gb.autoSave != nil && gb.cart.Dirty() {
+		if serr := gb.writeSave(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+func (gb *GameBoy) checkAutoSave() {
+	s, clock := gb.autoSave, gb.sched.clock.Load()
+	if clock-s.checked < FRAME_CYCLES {
+		return
+	}
+	s.checked = clock
+	if !gb.cart.Dirty() || gb.now().Sub(s.last) < s.interval {
+		return
+	}
+	if err := gb.writeSave(); err != nil {
+		gb.logger.Error("auto-save failed", "path", s.path, "error", err)
+	}
+}
+
+// writeSave replaces the save file through a temporary file, so a crash
+// mid-write keeps the previous save.
+func (gb *GameBoy) writeSave() error {
+	s := gb.autoSave
+	s.last = gb.now()
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, gb.cart.RAM(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	gb.cart.ClearDirty()
+	return nil
+}
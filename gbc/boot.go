@@ -0,0 +1,171 @@
+package gbc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+	"github.com/duyquang6/go-retroid/ppu"
+	"github.com/duyquang6/go-retroid/timer"
+)
+
+// boot ROM sizes: DMG, and CGB dumped without or with the 0x0100-0x01FF
+// gap where the cartridge header shows through
+const (
+	DMG_BOOT_SIZE     = 0x100
+	CGB_BOOT_SIZE     = 0x800
+	CGB_BOOT_GAP_SIZE = 0x900
+)
+
+var ErrBootROMSize = errors.New("boot ROM must be 256, 2048 or 2304 bytes")
+
+// post-boot values of the I/O registers on a DMG
+var postBootIO = []struct {
+	addr  uint16
+	value byte
+}{
+	{0xFF00, 0xCF}, // P1
+	{0xFF01, 0x00}, // SB
+	{0xFF02, 0x7E}, // SC
+	{0xFF04, 0xAB}, // DIV, the upper byte of the timer counter
+	{0xFF05, 0x00}, // TIMA
+	{0xFF06, 0x00}, // TMA
+	{0xFF07, 0xF8}, // TAC
+	{0xFF0F, 0xE1}, // IF
+	{0xFF26, 0xF1}, // NR52, powers the APU before its registers are set
+	{0xFF10, 0x80}, // NR10
+	{0xFF11, 0xBF}, // NR11
+	{0xFF12, 0xF3}, // NR12
+	{0xFF13, 0xFF}, // NR13
+	{0xFF14, 0xBF}, // NR14
+	{0xFF16, 0x3F}, // NR21
+	{0xFF17, 0x00}, // NR22
+	{0xFF18, 0xFF}, // NR23
+	{0xFF19, 0xBF}, // NR24
+	{0xFF1A, 0x7F}, // NR30
+	{0xFF1B, 0xFF}, // NR31
+	{0xFF1C, 0x9F}, // NR32
+	{0xFF1D, 0xFF}, // NR33
+	{0xFF1E, 0xBF}, // NR34
+	{0xFF20, 0xFF}, // NR41
+	{0xFF21, 0x00}, // NR42
+	{0xFF22, 0x00}, // NR43
+	{0xFF23, 0xBF}, // NR44
+	{0xFF24, 0x77}, // NR50
+	{0xFF25, 0xF3}, // NR51
+	{0xFF40, 0x91}, // LCDC
+	{0xFF41, 0x85}, // STAT
+	{0xFF42, 0x00}, // SCY
+	{0xFF43, 0x00}, // SCX
+	{0xFF44, 0x00}, // LY
+	{0xFF45, 0x00}, // LYC
+	{0xFF46, 0xFF}, // DMA
+	{0xFF47, 0xFC}, // BGP
+	{0xFF48, 0xFF}, // OBP0
+	{0xFF49, 0xFF}, // OBP1
+	{0xFF4A, 0x00}, // WY
+	{0xFF4B, 0x00}, // WX
+	{0xFFFF, 0x00}, // IE
+}
+
+// SkipBoot puts the machine in the state the model's boot ROM leaves it in
+// when it hands over to the cartridge at 0x0100: CPU registers and I/O
+// registers. On CGB hardware a game without the CGB header flag is
+// switched to DMG compatibility through KEY0. Either way KEY0 is locked
+// and the boot ROM unmapped as by the boot ROM's final BANK write.
+func (gb *GameBoy) SkipBoot() {
+	gb.cpu.Reset()
+	regs, compat := postBootRegisters[gb.Model()], false
+	if compatRegs, ok := postBootCompatRegisters[gb.Model()]; ok && gb.cart != nil && !gb.cart.CGB() {
+		regs, compat = compatRegs, true
+	}
+	regs.PC, regs.SP = gb.cpu.PC, gb.cpu.SP
+	gb.cpu.SetRegisters(regs)
+	gb.ppu.SkipBoot()
+	for _, reg := range postBootIO {
+		if reg.addr == mmu.ADDR_DMA {
+			// the value is left over, no transfer runs
+			gb.mem.WriteRaw(reg.addr, reg.value)
+			continue
+		}
+		if reg.addr == timer.ADDR_DIV {
+			// writing DIV would zero the counter
+			gb.timer.SetCounter(uint16(reg.value) << 8)
+			continue
+		}
+		gb.mem.Write(reg.addr, reg.value)
+	}
+	if compat {
+		gb.writeKEY0(KEY0_DMG_COMPAT)
+	}
+	// the boot ROM hands over by writing BANK
+	gb.writeBANK(1)
+}
+
+// SetBootROM makes the machine start by running rom from 0x0000 instead
+// of skipping the boot, and restarts it. The size tells a DMG boot ROM from
+// a CGB one and selects MODEL_DMG or MODEL_CGB to match. A nil rom goes
+// back to skipping the boot and keeps the model.
+func (gb *GameBoy) SetBootROM(rom []byte) error {
+	var image []byte
+	model := gb.model
+	switch len(rom) {
+	case 0:
+	case DMG_BOOT_SIZE:
+		image, model = append([]byte(nil), rom...), MODEL_DMG
+	case CGB_BOOT_SIZE:
+		image, model = make([]byte, CGB_BOOT_GAP_SIZE), MODEL_CGB
+		copy(image, rom[:0x100])
+		copy(image[0x200:], rom[0x100:])
+	case CGB_BOOT_GAP_SIZE:
+		image, model = append([]byte(nil), rom...), MODEL_CGB
+	default:
+		return ErrBootROMSize
+	}
+	gb.bootROM = image
+	gb.SetModel(model)
+	return nil
+}
+
+// SetBootROMFile reads a boot ROM dump from path, see SetBootROM.
+func (gb *GameBoy) SetBootROMFile(path string) error {
+	rom, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read boot ROM: %w", err)
+	}
+	if len(rom) == 0 {
+		return ErrBootROMSize
+	}
+	return gb.SetBootROM(rom)
+}
+
+// BootModel returns the model the boot ROM is for, MODEL_AUTO when the
+// boot is skipped.
+func (gb *GameBoy) BootModel() Model {
+	switch {
+	case gb.bootROM == nil:
+		return MODEL_AUTO
+	case len(gb.bootROM) == DMG_BOOT_SIZE:
+		return MODEL_DMG
+	}
+	return MODEL_CGB
+}
+
+// boot restarts the machine through the boot ROM if one is set, and
+// skips it otherwise.
+func (gb *GameBoy) boot() {
+	if gb.bootROM == nil {
+		gb.SkipBoot()
+		return
+	}
+	// power on: registers cleared, LCD off, and the boot ROM mapped until
+	// it writes BANK
+	gb.cpu.Reset()
+	gb.cpu.SetRegisters(cpu.Registers{})
+	gb.mem.WriteRaw(ppu.ADDR_LCDC, 0)
+	gb.mem.WriteRaw(ADDR_BANK, 0)
+	gb.timer.SetCounter(0)
+	gb.mem.MapBootROM(gb.bootROM)
+}
@@ -0,0 +1,62 @@
+package gbc
+
+// StopKind is what paused the emulation.
+type StopKind int
+
+const (
+	// still running
+	STOP_NONE StopKind = iota
+	// the cycle budget of RunToBreakpoint was spent
+	STOP_CYCLES
+	// PC reached a CPU breakpoint
+	STOP_BREAKPOINT
+	// an instruction wrote a watched address, see AddWatchpoint
+	STOP_WATCHPOINT
+	// a watched register reached its value, see WatchRegister
+	STOP_REGISTER_WATCH
+	// an interrupt was dispatched, see BreakOnInterrupt
+	STOP_INTERRUPT
+	// Pause was called
+	STOP_PAUSE
+)
+
+// StopReason tells why the emulation stopped and where: the address
+// written for STOP_WATCHPOINT, PC otherwise.
+type StopReason struct {
+	Kind    StopKind
+	Address uint16
+}
+
+// stop pauses the emulation. The first reason since the last resume is
+// kept, e.g. a watchpoint hit by the instruction that also lands on a
+// breakpoint.
+func (gb *GameBoy) stop(kind StopKind, address uint16) {
+	if gb.stopReason.Kind == STOP_NONE {
+		gb.stopReason = StopReason{Kind: kind, Address: address}
+	}
+	gb.paused.Store(true)
+}
+
+// RunToBreakpoint resumes the emulation and runs until a breakpoint,
+// watchpoint, register watch or interrupt break stops it, or at least
+// maxCycles T-cycles have been emulated. It returns ErrNoCartridge
+// without a loaded ROM.
+func (gb *GameBoy) RunToBreakpoint(maxCycles int) (StopReason, error) {
+	if gb.cart == nil {
+		return StopReason{}, ErrNoCartridge
+	}
+	gb.paused.Store(false)
+	gb.stopReason = StopReason{}
+	start := gb.sched.clock.Load()
+	for gb.sched.clock.Load()-start < uint64(max(maxCycles, 0)) {
+		gb.Step()
+		if !gb.paused.Load() {
+			continue
+		}
+		if gb.stopReason.Kind == STOP_NONE {
+			return StopReason{Kind: STOP_PAUSE, Address: gb.cpu.PC}, nil
+		}
+		return gb.stopReason, nil
+	}
+	return StopReason{Kind: STOP_CYCLES, Address: gb.cpu.PC}, nil
+}
@@ -0,0 +1,110 @@
+package gbc
+
+const (
+	ADDR_KEY0 uint16 = 0xFF4C
+	ADDR_KEY1 uint16 = 0xFF4D
+	// writing it unmaps the boot ROM and locks KEY0
+	ADDR_BANK uint16 = 0xFF50
+)
+
+// KEY0_DMG_COMPAT is the KEY0 bit that turns the CGB features off, the
+// boot ROM sets it for cartridges without the CGB header flag.
+const KEY0_DMG_COMPAT byte = 0x04
+
+// SetCGBMode selects CGB or DMG hardware: VRAM/WRAM banking, colour
+// palettes and the double speed switch only exist in CGB mode. LoadROM
+// selects the mode from the cartridge header, call this afterwards to
+// force one.
+func (gb *GameBoy) SetCGBMode(enabled bool) {
+	gb.cgbHardware = enabled
+	gb.key0 = 0
+	gb.key0Locked = false
+	gb.setCGBFeatures(enabled)
+	if enabled {
+		gb.mem.MapIO(ADDR_KEY0, func() byte { return gb.key0 | 0xF3 }, gb.writeKEY0)
+	} else {
+		gb.mem.MapIO(ADDR_KEY0, func() byte { return 0xFF }, func(byte) {})
+	}
+}
+
+// CGBMode reports whether the CGB features are available, that is CGB
+// hardware not locked into DMG compatibility through KEY0.
+func (gb *GameBoy) CGBMode() bool {
+	return gb.cgb
+}
+
+func (gb *GameBoy) setCGBFeatures(enabled bool) {
+	gb.cgb = enabled
+	gb.doubleSpeed = false
+	gb.speedArmed = false
+	gb.mem.SetCGB(enabled)
+	gb.ppu.SetCGB(enabled)
+	if enabled {
+		gb.mem.MapIO(ADDR_KEY1, gb.readKEY1, func(v byte) { gb.speedArmed = v&0x01 != 0 })
+	} else {
+		gb.mem.MapIO(ADDR_KEY1, func() byte { return 0xFF }, func(byte) {})
+	}
+}
+
+// writeKEY0 switches between CGB and DMG compatibility mode. The boot ROM
+// does it right before writing BANK, which makes KEY0 read-only, so games
+// never get to write it.
+func (gb *GameBoy) writeKEY0(v byte) {
+	if gb.key0Locked {
+		return
+	}
+	gb.key0 = v & 0x0C
+	gb.setCGBFeatures(v&KEY0_DMG_COMPAT == 0)
+}
+
+func (gb *GameBoy) writeBANK(v byte) {
+	gb.key0Locked = true
+	if v != 0 {
+		gb.mem.MapBootROM(nil)
+	}
+	gb.mem.WriteRaw(ADDR_BANK, v)
+}
+
+// DoubleSpeed reports whether the CPU runs at 8MHz after a speed switch.
+func (gb *GameBoy) DoubleSpeed() bool {
+	return gb.doubleSpeed
+}
+
+func (gb *GameBoy) readKEY1() byte {
+	v := byte(0x7E)
+	if gb.doubleSpeed {
+		v |= 0x80
+	}
+	if gb.speedArmed {
+		v |= 0x01
+	}
+	return v
+}
+
+// switchSpeed runs on STOP and toggles the CPU speed when armed via KEY1.
+func (gb *GameBoy) switchSpeed() bool {
+	if !gb.cgb || !gb.speedArmed {
+		return false
+	}
+	gb.speedArmed = false
+	gb.doubleSpeed = !gb.doubleSpeed
+	return true
+}
+
+// busCycles converts CPU cycles to the cycles of the rest of the system,
+// which keep running at normal speed in double speed mode.
+func (gb *GameBoy) busCycles(cycles int) int {
+	if gb.doubleSpeed {
+		return cycles / 2
+	}
+	return cycles
+}
+
+// stepTimer steps the timer, which unlike the rest of the system follows
+// the CPU clock and runs twice as fast in double speed mode.
+func (gb *GameBoy) stepTimer(cycles int) {
+	if gb.doubleSpeed {
+		cycles *= 2
+	}
+	gb.timer.Step(cycles)
+}
@@ -0,0 +1,115 @@
+package gbc
+
+import (
+	"errors"
+
+	"github.com/duyquang6/go-retroid/cheat"
+)
+
+var ErrNoCartridge = errors.New("no cartridge loaded")
+
+// ApplyGameGenie decodes a Game Genie code and patches the ROM reads of the
+// loaded cartridge.
+func (gb *GameBoy) ApplyGameGenie(code string) error {
+	if gb.cart == nil {
+		return ErrNoCartridge
+	}
+	p, err := cheat.DecodeGameGenie(code)
+	if err != nil {
+		return err
+	}
+	gb.gameGenie = append(gb.gameGenie, p)
+	if !gb.cheatsDisabled {
+		gb.cart.AddPatch(p)
+	}
+	return nil
+}
+
+// ApplyGameShark decodes a GameShark code whose value is written to RAM at
+// every VBlank. The bank byte is ignored, the write goes to whatever bank
+// is currently mapped.
+func (gb *GameBoy) ApplyGameShark(code string) error {
+	c, err := cheat.DecodeGameShark(code)
+	if err != nil {
+		return err
+	}
+	gb.gameShark = append(gb.gameShark, c)
+	return nil
+}
+
+// ApplyIPS patches the loaded ROM with an IPS patch and reloads the
+// cartridge from the result.
+func (gb *GameBoy) ApplyIPS(patch []byte) error {
+	if gb.cart == nil {
+		return ErrNoCartridge
+	}
+	rom, err := cheat.ApplyIPS(gb.rom, patch)
+	if err != nil {
+		return err
+	}
+	return gb.LoadROM(rom)
+}
+
+// SetCheatsEnabled turns all applied Game Genie and GameShark codes on or
+// off without removing them: disabled, ROM reads return the original bytes
+// and RAM is no longer written at VBlank, so the game's own values come
+// back as it updates them. Cheats are enabled by default.
+func (gb *GameBoy) SetCheatsEnabled(enabled bool) {
+	if enabled == !gb.cheatsDisabled {
+		return
+	}
+	gb.cheatsDisabled = !enabled
+	if gb.cart == nil {
+		return
+	}
+	gb.cart.ClearPatches()
+	if enabled {
+		for _, p := range gb.gameGenie {
+			gb.cart.AddPatch(p)
+		}
+	}
+}
+
+func (gb *GameBoy) CheatsEnabled() bool {
+	return !gb.cheatsDisabled
+}
+
+func (gb *GameBoy) applyGameShark() {
+	if gb.cheatsDisabled {
+		return
+	}
+	for _, c := range gb.gameShark {
+		gb.mem.Write(c.Address, c.Value)
+	}
+}
+
+// CheatSearchEqual returns the WRAM and HRAM addresses currently holding
+// value, the starting point of a cheat search.
+func (gb *GameBoy) CheatSearchEqual(value byte) []uint16 {
+	var found []uint16
+	for _, r := range cheatSearchRanges {
+		for addr := r.start; addr <= r.end; addr++ {
+			if gb.mem.Read(addr) == value {
+				found = append(found, addr)
+			}
+		}
+	}
+	return found
+}
+
+// CheatSearchNarrow keeps the addresses of a previous search that now hold
+// value.
+func (gb *GameBoy) CheatSearchNarrow(prev []uint16, value byte) []uint16 {
+	var found []uint16
+	for _, addr := range prev {
+		if gb.mem.Read(addr) == value {
+			found = append(found, addr)
+		}
+	}
+	return found
+}
+
+var cheatSearchRanges = []struct{ start, end uint16 }{
+	{0xC000, 0xDFFF}, // WRAM
+	{0xFF80, 0xFFFE}, // HRAM
+}
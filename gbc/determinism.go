@@ -0,0 +1,68 @@
+package gbc
+
+import "hash/fnv"
+
+// InputEvent is a button change applied at the start of frame Frame,
+// counting from 0.
+type InputEvent struct {
+	Frame   int
+	Button  Button
+	Pressed bool
+}
+
+// VerifyDeterminism runs rom twice for the given number of frames from
+// power on, replaying inputs, and reports whether both runs produced the
+// same frame every time. The runs happen on fresh machines sharing gb's
+// model, stepping mode and clock; gb itself is left untouched.
+//
+// A mismatch means something outside the inputs leaks into emulation, like
+// the wall clock through the cartridge RTC. A ROM that fails to load
+// reports false.
+func (gb *GameBoy) VerifyDeterminism(rom []byte, inputs []InputEvent, frames int) bool {
+	first, err := gb.replayHashes(rom, inputs, frames)
+	if err != nil {
+		return false
+	}
+	second, err := gb.replayHashes(rom, inputs, frames)
+	if err != nil {
+		return false
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			gb.logger.Debug("replays diverged", "frame", i)
+			return false
+		}
+	}
+	return true
+}
+
+// replayHashes runs rom on a fresh machine configured like gb and returns
+// a hash of the screen after each frame.
+func (gb *GameBoy) replayHashes(rom []byte, inputs []InputEvent, frames int) ([]uint64, error) {
+	m := NewGameBoy()
+	m.model = gb.model
+	m.SetCycleAccurate(gb.bus != nil)
+	m.now = gb.now
+	if err := m.LoadROM(rom); err != nil {
+		return nil, err
+	}
+
+	hashes := make([]uint64, 0, frames)
+	for f := range frames {
+		for _, e := range inputs {
+			if e.Frame != f {
+				continue
+			}
+			if e.Pressed {
+				m.Press(e.Button)
+			} else {
+				m.Release(e.Button)
+			}
+		}
+		m.RunFrames(1)
+		h := fnv.New64a()
+		h.Write(m.Frame().Pix)
+		hashes = append(hashes, h.Sum64())
+	}
+	return hashes, nil
+}
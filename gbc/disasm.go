@@ -0,0 +1,80 @@
+package gbc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+// peekBus is the debugger's read-only view of memory, see mmu.Memory.Peek:
+// disassembling is unaffected by PPU locks and OAM DMA and counts nothing
+// in the access stats.
+type peekBus struct {
+	mem *mmu.Memory
+}
+
+func (b peekBus) Read(address uint16) byte {
+	return b.mem.Peek(address)
+}
+
+func (b peekBus) Write(uint16, byte) {}
+
+// DisasmLine is one disassembled instruction.
+type DisasmLine struct {
+	Address  uint16
+	Bytes    []byte
+	Mnemonic string
+}
+
+// DisassembleRange disassembles count instructions starting at start,
+// reading memory the way the CPU sees it.
+func (gb *GameBoy) DisassembleRange(start uint16, count int) []DisasmLine {
+	lines := make([]DisasmLine, 0, count)
+	address := start
+	for range count {
+		mnemonic, n := cpu.Disassemble(peekBus{gb.mem}, address)
+		line := DisasmLine{Address: address, Mnemonic: mnemonic, Bytes: make([]byte, n)}
+		for i := range line.Bytes {
+			line.Bytes[i] = gb.mem.Peek(address + uint16(i))
+		}
+		lines = append(lines, line)
+		address += uint16(n)
+	}
+	return lines
+}
+
+// OpcodeAt decodes the instruction at address without executing it. cycles
+// is the not-taken cost for conditional branches.
+func (gb *GameBoy) OpcodeAt(address uint16) (mnemonic string, length int, cycles int) {
+	mnemonic, length = cpu.Disassemble(peekBus{gb.mem}, address)
+	cycles = cpu.OpcodeCycles(gb.mem.Peek(address), gb.mem.Peek(address+1))
+	return mnemonic, length, cycles
+}
+
+// DumpDisassembly writes a linear disassembly of start-end (inclusive) to
+// w, one instruction per line: address, bytes and mnemonic. Illegal
+// opcodes and instructions running past end are listed as ".db $XX", one
+// byte at a time, so that disassembly resyncs on the next byte. Nothing
+// tells code from data: a tracing disassembler would follow jumps instead.
+func (gb *GameBoy) DumpDisassembly(w io.Writer, start, end uint16) error {
+	bw := bufio.NewWriter(w)
+	for address := int(start); address <= int(end); {
+		mnemonic, n := cpu.Disassemble(peekBus{gb.mem}, uint16(address))
+		if strings.HasPrefix(mnemonic, "ILLEGAL") || address+n-1 > int(end) {
+			mnemonic, n = fmt.Sprintf(".db $%02X", gb.mem.Peek(uint16(address))), 1
+		}
+		hex := make([]string, n)
+		for i := range hex {
+			hex[i] = fmt.Sprintf("%02X", gb.mem.Peek(uint16(address+i)))
+		}
+		if _, err := fmt.Fprintf(bw, "%04X  %-8s  %s\n", address, strings.Join(hex, " "), mnemonic); err != nil {
+			return err
+		}
+		address += n
+	}
+	return bw.Flush()
+}
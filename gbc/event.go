@@ -0,0 +1,64 @@
+package gbc
+
+import "github.com/duyquang6/go-retroid/cpu"
+
+type EventType int
+
+const (
+	// a frame completed, Frame is the frame count
+	EVENT_VBLANK EventType = iota
+	// a serial transfer completed, Value is the byte sent
+	EVENT_SERIAL_OUT
+	// battery-backed RAM changed since the save was last written
+	EVENT_BATTERY_SAVE_DIRTY
+	// IME changed, Value is its new value (0 or 1) and Cause what changed
+	// it
+	EVENT_IME_CHANGE
+)
+
+// IMECause is what changed IME, one of cpu.IME_EI, IME_DI, IME_RETI or
+// IME_INTERRUPT.
+type IMECause = cpu.IMECause
+
+type Event struct {
+	Type  EventType
+	Frame uint64
+	Value byte
+	Cause IMECause
+}
+
+// Subscribe registers fn to be called on every event of type t, in
+// subscription order.
+func (gb *GameBoy) Subscribe(t EventType, fn func(Event)) {
+	if gb.subscribers == nil {
+		gb.subscribers = make(map[EventType][]func(Event))
+	}
+	gb.subscribers[t] = append(gb.subscribers[t], fn)
+}
+
+func (gb *GameBoy) emit(e Event) {
+	subscribers := gb.subscribers[e.Type]
+	if len(subscribers) == 0 {
+		return
+	}
+	e.Frame = gb.stats.Frames
+	gb.inCallback.Add(1)
+	defer gb.inCallback.Add(-1)
+	for _, fn := range subscribers {
+		fn(e)
+	}
+}
+
+func (gb *GameBoy) imeChanged(enabled bool, cause IMECause) {
+	e := Event{Type: EVENT_IME_CHANGE, Cause: cause}
+	if enabled {
+		e.Value = 1
+	}
+	gb.emit(e)
+}
+
+func (gb *GameBoy) batteryDirty() {
+	if gb.cart.HasBattery() {
+		gb.emit(Event{Type: EVENT_BATTERY_SAVE_DIRTY})
+	}
+}
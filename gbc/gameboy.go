@@ -1,30 +1,430 @@
 package gbc
 
 import (
+	"context"
+	"fmt"
+	"image/color"
+	"io/fs"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/duyquang6/go-retroid/apu"
+	"github.com/duyquang6/go-retroid/cartridge"
+	"github.com/duyquang6/go-retroid/cheat"
 	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/joypad"
 	"github.com/duyquang6/go-retroid/mmu"
+	"github.com/duyquang6/go-retroid/ppu"
+	"github.com/duyquang6/go-retroid/serial"
+	"github.com/duyquang6/go-retroid/timer"
 )
 
+// AudioSink receives the interleaved stereo samples produced each frame.
+type AudioSink interface {
+	Write(samples []float32)
+	SampleRate() int
+}
+
+// SerialPeer is the other end of the link cable, see serial.Peer.
+type SerialPeer = serial.Peer
+
 type GameBoy struct {
-	cpu *cpu.CPU
-	mem *mmu.Memory
+	cpu  *cpu.CPU
+	mem  *mmu.Memory
+	ppu  *ppu.PPU
+	apu  *apu.APU
+	cart *cartridge.Cartridge
+	rom  []byte
+	// run from 0x0000 on reset, laid out as mapped; nil skips the boot
+	bootROM []byte
+	serial  *serial.Serial
+	timer   *timer.Timer
+	joypad  *joypad.Joypad
+	logger  *slog.Logger
+	audio   AudioSink
+	// set by SetSampleRate in place of the sink's rate, 0 when unset
+	sampleRate int
+
+	sched scheduler
+	// nil when components are stepped once per instruction
+	bus *syncBus
+
+	now        func() time.Time
+	stats      EmulationStats
+	statsStart time.Time
+
+	profiler *profiler
+
+	presentMode PresentMode
+	sleep       func(time.Duration)
+	// when the next frame is due in PRESENT_VSYNC mode
+	nextPresent time.Time
+
+	// set by Pause and breakpoints, read by the run loop goroutine
+	paused atomic.Bool
+	// why the emulation stopped since the last resume, see stop
+	stopReason StopReason
+	// held by the run loop while it steps, wake signals Resume
+	runMu sync.Mutex
+	wake  chan struct{}
+	// depth of event, profile and audio callbacks the emulation is
+	// running, see Pause
+	inCallback atomic.Int32
+	// bit n set breaks after dispatching interrupt n
+	breakInterrupts byte
+
+	watchpoints     map[uint16]struct{}
+	registerWatches []*registerWatch
+	// snapshots from before the last historyDepth steps, oldest first
+	history      [][]byte
+	historyDepth int
+	trace        *tracer
+	subscribers  map[EventType][]func(Event)
+	autoSave     *autoSave
+
+	// colours of the BGP, OBP0 and OBP1 shades
+	dmgPalette [3][4]color.RGBA
+
+	inputMode    InputPollMode
+	pendingInput []inputEvent
+
+	gameShark []cheat.GameShark
+	// Game Genie patches of the loaded cartridge, kept to restore them
+	// when cheats are enabled again
+	gameGenie      []cartridge.Patch
+	cheatsDisabled bool
+
+	model Model
+	// CGB hardware, and whether its features are on (off in DMG
+	// compatibility mode)
+	cgbHardware, cgb bool
+	key0             byte
+	key0Locked       bool
+	doubleSpeed      bool
+	// KEY1 bit 0: the next STOP switches speed
+	speedArmed bool
 }
 
 func NewGameBoy() *GameBoy {
 	mem := mmu.New()
 	cpu := cpu.New(mem)
-	return &GameBoy{cpu: cpu, mem: mem}
+	gb := &GameBoy{
+		cpu:    cpu,
+		mem:    mem,
+		ppu:    ppu.New(mem),
+		apu:    apu.New(mem),
+		serial: serial.New(mem),
+		timer:  timer.New(mem),
+		joypad: joypad.New(mem),
+		logger: slog.New(slog.DiscardHandler),
+		now:    time.Now,
+		sleep:  time.Sleep,
+		wake:   make(chan struct{}, 1),
+	}
+	gb.dmgPalette = [3][4]color.RGBA{defaultDMGPalette, defaultDMGPalette, defaultDMGPalette}
+	gb.sched.add(ComponentFunc(gb.mem.StepDMA))
+	gb.sched.add(gb.ppu)
+	gb.sched.add(gb.apu)
+	gb.sched.add(gb.serial)
+	gb.sched.add(ComponentFunc(gb.stepTimer))
+	gb.ppu.OnVBlank(gb.vblank)
+	gb.cpu.OnStop(gb.switchSpeed)
+	gb.cpu.OnIMEChange(gb.imeChanged)
+	gb.SetCycleAccurate(true)
+	gb.mem.MapIO(ADDR_BANK, nil, gb.writeBANK)
+	gb.serial.OnTransfer(func(out byte) { gb.emit(Event{Type: EVENT_SERIAL_OUT, Value: out}) })
+	gb.SetCGBMode(false)
+	gb.SkipBoot()
+	return gb
+}
+
+func (gb *GameBoy) LoadROM(rom []uint8) error {
+	cart, err := cartridge.New(rom)
+	if err != nil {
+		return err
+	}
+	gb.cart = cart
+	gb.rom = rom
+	cart.SetClock(gb.now)
+	cart.OnDirty(gb.batteryDirty)
+	// the save path, step history and ROM patches belong to the previous
+	// game
+	gb.autoSave = nil
+	gb.history = nil
+	gb.gameGenie = nil
+	gb.mem.InsertCartridge(cart)
+	gb.SetModel(gb.model)
+	return nil
+}
+
+// LoadROMFromFS loads the ROM file name from fsys, such as an embed.FS.
+func (gb *GameBoy) LoadROMFromFS(fsys fs.FS, name string) error {
+	rom, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("read ROM: %w", err)
+	}
+	return gb.LoadROM(rom)
+}
+
+// Title returns the game title from the cartridge header, empty without
+// a cartridge.
+func (gb *GameBoy) Title() string {
+	if gb.cart == nil {
+		return ""
+	}
+	return gb.cart.Title()
+}
+
+// SetLogger routes the logs of every component to logger instead of
+// discarding them.
+func (gb *GameBoy) SetLogger(logger *slog.Logger) {
+	gb.logger = logger
+	gb.cpu.SetLogger(logger)
+	gb.ppu.SetLogger(logger)
+	gb.mem.SetLogger(logger)
+}
+
+func (gb *GameBoy) CPU() *cpu.CPU {
+	return gb.cpu
+}
+
+// DirtyTiles returns the VRAM tiles changed since the last call, see
+// ppu.PPU.DirtyTiles.
+func (gb *GameBoy) DirtyTiles() []int {
+	return gb.ppu.DirtyTiles()
+}
+
+// SetMaxSpritesPerLine sets the number of sprites drawn per line, see
+// ppu.PPU.SetMaxSpritesPerLine.
+func (gb *GameBoy) SetMaxSpritesPerLine(n int) {
+	gb.ppu.SetMaxSpritesPerLine(n)
+}
+
+// Peek reads memory the way the CPU sees it without executing anything.
+// VRAM and OAM read their contents even while the PPU or an OAM DMA
+// keeps the CPU out, see mmu.Memory.Peek.
+func (gb *GameBoy) Peek(address uint16) byte {
+	return gb.mem.Peek(address)
+}
+
+// TriggerDMA starts an OAM DMA copying 160 bytes from page<<8, as the
+// CPU does by writing page to 0xFF46. The copy runs over the next 640
+// cycles, during which the CPU can only reach HRAM and I/O.
+func (gb *GameBoy) TriggerDMA(page byte) {
+	gb.mem.Write(mmu.ADDR_DMA, page)
+}
+
+// Poke writes memory the way the CPU does.
+func (gb *GameBoy) Poke(address uint16, value byte) {
+	gb.mem.Write(address, value)
+}
+
+// VRAM returns a copy of the 8KB of video RAM in the given bank. Bank 1
+// only exists in CGB mode, other banks return nil.
+func (gb *GameBoy) VRAM(bank int) []byte {
+	vram := gb.mem.VRAMBank(bank)
+	if vram == nil {
+		return nil
+	}
+	return append([]byte(nil), vram...)
+}
+
+// OAM returns a copy of the 160 bytes of sprite attribute memory.
+func (gb *GameBoy) OAM() []byte {
+	return append([]byte(nil), gb.mem.RangeInclusive(0xFE00, 0xFE9F)...)
+}
+
+// HasCartridge reports whether a ROM was loaded.
+func (gb *GameBoy) HasCartridge() bool {
+	return gb.cart != nil
 }
 
-func (gb *GameBoy) LoadROM(rom []uint8) {
-	gb.mem.WriteBytes(0, rom)
+// Run returns ErrNoCartridge without a loaded ROM rather than executing
+// zeroed memory.
+func (gb *GameBoy) Run() error {
+	if gb.cart == nil {
+		return ErrNoCartridge
+	}
+	gb.logger.Info("Starting emulation...")
+	for i := 0; i < 3 && !gb.paused.Load(); i++ { // Run 3 steps for now
+		gb.Step()
+	}
+	return nil
+}
+
+// Step executes a single instruction, lets the other components catch up
+// with it and then services any pending interrupt, including one raised
+// during the catch-up. Interrupts are therefore only dispatched on
+// instruction boundaries. It returns the T-cycles spent.
+func (gb *GameBoy) Step() int {
+	if gb.historyDepth > 0 {
+		gb.recordStep()
+	}
+	if gb.trace != nil {
+		gb.traceInstruction()
+	}
+	if gb.profiler != nil {
+		gb.profiler.instructions++
+	}
+	cycles := gb.runCPU(gb.cpu.Step)
+	serviced := gb.runCPU(gb.cpu.HandleInterrupts)
+	cycles += serviced
+	gb.countCycles(gb.busCycles(cycles))
+
+	if gb.cpu.AtBreakpoint() {
+		gb.stop(STOP_BREAKPOINT, gb.cpu.PC)
+	}
+	if gb.registerWatches != nil {
+		gb.checkRegisterWatches()
+	}
+	if gb.autoSave != nil {
+		gb.checkAutoSave()
+	}
+
+	if serviced > 0 {
+		for n := uint8(0); n < 5; n++ {
+			if gb.breakInterrupts&(1<<n) != 0 && gb.cpu.PC == cpu.InterruptVector(n) {
+				gb.stop(STOP_INTERRUPT, gb.cpu.PC)
+			}
+		}
+	}
+	return cycles
+}
+
+func (gb *GameBoy) vblank() {
+	gb.countFrame()
+	if gb.profiler != nil {
+		gb.reportProfile()
+	}
+	gb.applyInput()
+	gb.flushTrace()
+	gb.applyGameShark()
+	gb.handOver(func() {
+		if gb.audio != nil {
+			gb.inCallback.Add(1)
+			gb.audio.Write(gb.apu.Samples())
+			gb.inCallback.Add(-1)
+		}
+		gb.present()
+		gb.emit(Event{Type: EVENT_VBLANK})
+	})
+}
+
+// AttachAudio makes the APU produce samples at the sink's rate and hands
+// them over once per frame at VBlank. A nil sink stops audio output.
+func (gb *GameBoy) AttachAudio(sink AudioSink) {
+	gb.audio = sink
+	gb.applySampleRate()
+}
+
+// SetSampleRate sets the rate, in Hz, of the samples handed to the audio
+// sink, overriding its SampleRate; 0 goes back to the sink's rate. The APU
+// output is resampled to it with linear interpolation, e.g. to 44100 or
+// 48000 for common devices. It takes effect while a sink is attached.
+func (gb *GameBoy) SetSampleRate(hz int) {
+	gb.sampleRate = max(hz, 0)
+	gb.applySampleRate()
+}
+
+// applySampleRate samples only with a sink to drain the samples.
+func (gb *GameBoy) applySampleRate() {
+	switch {
+	case gb.audio == nil:
+		gb.apu.SetSampleRate(0)
+		gb.apu.Samples()
+	case gb.sampleRate > 0:
+		gb.apu.SetSampleRate(gb.sampleRate)
+	default:
+		gb.apu.SetSampleRate(gb.audio.SampleRate())
+	}
+}
+
+// BreakOnInterrupt pauses the run loop right after the CPU jumps to the
+// vector of interrupt n, before the handler executes.
+func (gb *GameBoy) BreakOnInterrupt(n uint8) {
+	gb.breakInterrupts |= 1 << n
+}
+
+func (gb *GameBoy) Paused() bool {
+	return gb.paused.Load()
+}
+
+// Pause stops the run loop after the instruction in progress and returns
+// once it is idle, so the machine can be inspected from another goroutine.
+// Samples not yet written to the audio sink are dropped. While an event
+// subscriber, the profile callback or the audio sink runs, it only flags
+// the pause and returns, so those can call it: the loop stops once the
+// callback returns.
+func (gb *GameBoy) Pause() {
+	gb.paused.Store(true)
+	if gb.inCallback.Load() > 0 {
+		return
+	}
+	gb.runMu.Lock()
+	gb.apu.Samples()
+	gb.runMu.Unlock()
+}
+
+// Resume continues after Pause or a breakpoint from the same state.
+func (gb *GameBoy) Resume() {
+	gb.stopReason = StopReason{}
+	gb.paused.Store(false)
+	select {
+	case gb.wake <- struct{}{}:
+	default:
+	}
 }
 
-func (gb *GameBoy) Run() {
-	slog.Info("Starting emulation...")
-	for i := 0; i < 3; i++ { // Run 3 steps for now
-		gb.cpu.Step()
+// RunContext runs the emulation until ctx is done, a frame's worth of
+// cycles at a time. While paused it waits for Resume. Without a loaded
+// ROM it returns ErrNoCartridge.
+func (gb *GameBoy) RunContext(ctx context.Context) error {
+	if gb.cart == nil {
+		return ErrNoCartridge
 	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if gb.paused.Load() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-gb.wake:
+			}
+			continue
+		}
+		gb.runMu.Lock()
+		gb.RunCycles(FRAME_CYCLES)
+		gb.runMu.Unlock()
+	}
+}
+
+// SetRTC sets the MBC3 real-time clock, see cartridge.Cartridge.SetRTC.
+func (gb *GameBoy) SetRTC(t time.Time) error {
+	if gb.cart == nil {
+		return cartridge.ErrNoRTC
+	}
+	return gb.cart.SetRTC(t)
+}
+
+// RTC reads the MBC3 real-time clock; ok is false when the cartridge has none.
+func (gb *GameBoy) RTC() (days, h, m, s int, ok bool) {
+	if gb.cart == nil {
+		return 0, 0, 0, 0, false
+	}
+	return gb.cart.RTC()
+}
+
+// ConnectSerial plugs a link cable peer, e.g. another GameBoy, into the
+// serial port.
+func (gb *GameBoy) ConnectSerial(peer SerialPeer) {
+	gb.serial.Connect(peer)
+}
+
+// Exchange lets a GameBoy act as the SerialPeer of another instance.
+func (gb *GameBoy) Exchange(out byte) (byte, bool) {
+	return gb.serial.Exchange(out)
 }
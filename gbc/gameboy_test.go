@@ -1,17 +1,47 @@
 package gbc_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/fs"
 	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/duyquang6/go-retroid/cpu"
 	"github.com/duyquang6/go-retroid/gbc"
+	"github.com/duyquang6/go-retroid/joypad"
+	"github.com/duyquang6/go-retroid/serial"
 )
 
 func init() {
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 }
 
+// newTestGB returns a machine running a blank 32 KiB ROM with program at
+// the 0x0100 entry point.
+func newTestGB(t *testing.T, program ...byte) *gbc.GameBoy {
+	t.Helper()
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], program)
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	return gb
+}
+
 func Test_LoadSimpleROM(t *testing.T) {
 	testROMs := [][]byte{
 		// Test ROM: [NOP, NOP, NOP]
@@ -28,3 +58,1371 @@ func Test_LoadSimpleROM(t *testing.T) {
 		gb.Run()
 	}
 }
+
+func Test_ConnectSerial(t *testing.T) {
+	transfer := func(data, control byte) []byte {
+		rom := make([]byte, 0x8000)
+		copy(rom[0x0100:], []byte{
+			0x3E, data, // LD A,data
+			0xE0, 0x01, // LDH (SB),A
+			0x3E, control, // LD A,control
+			0xE0, 0x02, // LDH (SC),A
+			0x18, 0xFE, // JR -2
+		})
+		return rom
+	}
+
+	master, slave := gbc.NewGameBoy(), gbc.NewGameBoy()
+	if err := master.LoadROM(transfer(0x11, 0x81)); err != nil {
+		t.Fatal(err)
+	}
+	if err := slave.LoadROM(transfer(0x22, 0x80)); err != nil {
+		t.Fatal(err)
+	}
+	master.ConnectSerial(slave)
+	slave.ConnectSerial(master)
+
+	// the slave arms its external-clock transfer first
+	slave.RunCycles(100)
+	master.RunCycles(8*serial.BIT_CYCLES + 100)
+	slave.RunCycles(100)
+
+	if got := master.Peek(serial.ADDR_SB); got != 0x22 {
+		t.Errorf("master received %02X, want 22", got)
+	}
+	if got := slave.Peek(serial.ADDR_SB); got != 0x11 {
+		t.Errorf("slave received %02X, want 11", got)
+	}
+}
+
+func Test_SkipBoot(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	gb.CPU().PC = 0x1234
+	gb.CPU().A = 0xFF
+
+	gb.SkipBoot()
+
+	if gb.CPU().PC != 0x0100 || gb.CPU().A != 0x01 || gb.CPU().SP != 0xFFFE {
+		t.Errorf("CPU not in post-boot state: PC=%04X A=%02X SP=%04X", gb.CPU().PC, gb.CPU().A, gb.CPU().SP)
+	}
+
+	want := map[uint16]byte{
+		0xFF02: 0x7E, // SC
+		0xFF04: 0xAB, // DIV
+		0xFF07: 0xF8, // TAC
+		0xFF0F: 0xE1, // IF
+		0xFF26: 0xF1, // NR52
+		0xFF40: 0x91, // LCDC
+		0xFF41: 0x85, // STAT
+		0xFF47: 0xFC, // BGP
+		0xFFFF: 0x00, // IE
+	}
+	for addr, value := range want {
+		if got := gb.Peek(addr); got != value {
+			t.Errorf("[%04X] = %02X, want %02X", addr, got, value)
+		}
+	}
+}
+
+func Test_VRAMAndOAMSnapshot(t *testing.T) {
+	gb := newTestGB(t,
+		0xAF,       // XOR A
+		0xE0, 0x40, // LDH (LCDC),A ; LCD off, VRAM and OAM unlocked
+		0x3E, 0xAA, // LD A,0xAA
+		0xEA, 0x10, 0x80, // LD (0x8010),A
+		0x3E, 0x55, // LD A,0x55
+		0xEA, 0xFF, 0x9F, // LD (0x9FFF),A
+		0xEA, 0x03, 0xFE, // LD (0xFE03),A
+	)
+	for i := 0; i < 7; i++ {
+		gb.Step()
+	}
+
+	vram := gb.VRAM(0)
+	if len(vram) != 0x2000 || vram[0x0010] != 0xAA || vram[0x1FFF] != 0x55 {
+		t.Errorf("VRAM snapshot: len=%d [0x10]=%02X [0x1FFF]=%02X", len(vram), vram[0x0010], vram[0x1FFF])
+	}
+	oam := gb.OAM()
+	if len(oam) != 160 || oam[3] != 0x55 {
+		t.Errorf("OAM snapshot: len=%d [3]=%02X", len(oam), oam[3])
+	}
+
+	// snapshots are copies
+	vram[0x0010] = 0
+	if gb.Peek(0x8010) != 0xAA {
+		t.Error("modifying the VRAM snapshot changed emulator memory")
+	}
+	if gb.VRAM(1) != nil {
+		t.Error("VRAM bank 1 available on DMG")
+	}
+}
+
+type recordingHandler struct {
+	records *[]slog.Record
+}
+
+func (h recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	*h.records = append(*h.records, r)
+	return nil
+}
+func (h recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+func Test_SetLogger(t *testing.T) {
+	var global, injected []slog.Record
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(recordingHandler{&global}))
+	defer slog.SetDefault(defaultLogger)
+
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM([]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	gb.SetLogger(slog.New(recordingHandler{&injected}))
+	gb.Run()
+
+	if len(injected) == 0 {
+		t.Error("no records reached the injected logger")
+	}
+	if len(global) != 0 {
+		t.Errorf("%d records went to the global logger", len(global))
+	}
+}
+
+type recordingSink struct {
+	rate   int
+	writes [][]float32
+}
+
+func (s *recordingSink) Write(samples []float32) { s.writes = append(s.writes, samples) }
+func (s *recordingSink) SampleRate() int         { return s.rate }
+
+func Test_AttachAudio(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	sink := &recordingSink{rate: 44100}
+	gb.AttachAudio(sink)
+
+	for len(sink.writes) < 4 {
+		gb.Step()
+	}
+
+	// 70224 cycles per frame at 4194304Hz, two channels
+	want := 2 * 44100 * 70224 / 4194304
+	for i, samples := range sink.writes[1:] {
+		if d := len(samples) - want; d < -2 || d > 2 {
+			t.Errorf("frame %d: %d samples, want %d", i+1, len(samples), want)
+		}
+	}
+}
+
+func Test_SetSampleRate(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	// without a sink nothing is sampled, the rate applies once attached
+	gb.SetSampleRate(48000)
+	gb.RunFrames(2)
+	sink := &recordingSink{rate: 44100}
+	gb.AttachAudio(sink)
+
+	for len(sink.writes) < 3 {
+		gb.Step()
+	}
+	if len(sink.writes[0]) > 2*804+4 {
+		t.Errorf("first write has %d samples, samples were kept without a sink", len(sink.writes[0]))
+	}
+
+	// a frame lasts 1/59.7s
+	want := 2 * 804 // 48000/59.7
+	for i, samples := range sink.writes[1:] {
+		if d := len(samples) - want; d < -4 || d > 4 {
+			t.Errorf("frame %d: %d samples, want %d", i+1, len(samples), want)
+		}
+	}
+}
+
+func Test_Stats(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	// the clock is read once at start and once per frame: pace at 50 FPS
+	now := time.Unix(0, 0)
+	gb.SetClock(func() time.Time {
+		now = now.Add(time.Second / 50)
+		return now
+	})
+
+	for gb.Stats().Frames < 10 {
+		gb.Step()
+	}
+
+	stats := gb.Stats()
+	if stats.Elapsed != 10*time.Second/50 {
+		t.Errorf("Elapsed = %v, want %v", stats.Elapsed, 10*time.Second/50)
+	}
+	if math.Abs(stats.FPS-50) > 0.001 {
+		t.Errorf("FPS = %f, want 50", stats.FPS)
+	}
+	// nine full frames after the first VBlank
+	if stats.Cycles < 9*70224 || stats.Cycles > 10*70224 {
+		t.Errorf("Cycles = %d, want within [%d, %d]", stats.Cycles, 9*70224, 10*70224)
+	}
+}
+
+func Test_Cheats(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x4A17] = 0xC8
+	copy(rom[0x0100:], []byte{0x18, 0xFE}) // JR -2
+
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+
+	// 00A-17B-C49: write 0x00 at 0x4A17 when the original byte is 0xC8
+	if err := gb.ApplyGameGenie("00A-17B-C49"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gb.Peek(0x4A17); got != 0x00 {
+		t.Errorf("Game Genie patched byte = 0x%02X, want 0x00", got)
+	}
+
+	if err := gb.ApplyGameShark("0142C0C0"); err != nil {
+		t.Fatal(err)
+	}
+	for gb.Stats().Frames < 1 {
+		gb.Step()
+	}
+	if got := gb.Peek(0xC0C0); got != 0x42 {
+		t.Errorf("GameShark RAM byte = 0x%02X, want 0x42", got)
+	}
+
+	patch := append([]byte("PATCH"), 0x00, 0x01, 0x50, 0x00, 0x01, 0x99)
+	patch = append(patch, "EOF"...)
+	if err := gb.ApplyIPS(patch); err != nil {
+		t.Fatal(err)
+	}
+	if got := gb.Peek(0x0150); got != 0x99 {
+		t.Errorf("IPS patched byte = 0x%02X, want 0x99", got)
+	}
+}
+
+func Test_PeekBypassesPPULocks(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	// the boot leaves the PPU in VBlank, VRAM and OAM are open
+	gb.Poke(0x8000, 0x5A)
+	gb.Poke(0xFE00, 0xA5)
+
+	for gb.Peek(0xFF41)&0x03 != 0x03 {
+		gb.Step()
+	}
+	// mode 3 locks both for the CPU
+	gb.Poke(0x8000, 0x11)
+	gb.Poke(0xFE00, 0x11)
+
+	if got := gb.Peek(0x8000); got != 0x5A {
+		t.Errorf("Peek(0x8000) in mode 3 = 0x%02X, want 0x5A", got)
+	}
+	if got := gb.Peek(0xFE00); got != 0xA5 {
+		t.Errorf("Peek(0xFE00) in mode 3 = 0x%02X, want 0xA5", got)
+	}
+	if got := gb.VRAM(0)[0]; got != 0x5A {
+		t.Errorf("VRAM(0)[0] in mode 3 = 0x%02X, want 0x5A", got)
+	}
+	if got := gb.OAM()[0]; got != 0xA5 {
+		t.Errorf("OAM()[0] in mode 3 = 0x%02X, want 0xA5", got)
+	}
+	// the disassembler reads VRAM the same way
+	if line := gb.DisassembleRange(0x8000, 1)[0]; line.Mnemonic != "LD E,D" || !slices.Equal(line.Bytes, []byte{0x5A}) {
+		t.Errorf("DisassembleRange(0x8000) in mode 3 = %+v, want LD E,D", line)
+	}
+	if m, _, _ := gb.OpcodeAt(0x8000); m != "LD E,D" {
+		t.Errorf("OpcodeAt(0x8000) in mode 3 = %q, want LD E,D", m)
+	}
+}
+
+func Test_SetCheatsEnabled(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x4A17] = 0xC8
+	// the game keeps writing 0x05 to 0xC0C0
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x05, // LD A,0x05
+		0xEA, 0xC0, 0xC0, // LD (0xC0C0),A
+		0x18, 0xFB, // JR -5
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	if err := gb.ApplyGameGenie("00A-17B-C49"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gb.ApplyGameShark("0142C0C0"); err != nil {
+		t.Fatal(err)
+	}
+	// the cheat is written at VBlank, right before subscribers run
+	var atVBlank byte
+	gb.Subscribe(gbc.EVENT_VBLANK, func(gbc.Event) { atVBlank = gb.Peek(0xC0C0) })
+
+	gb.RunFrames(1)
+	if atVBlank != 0x42 || gb.Peek(0x4A17) != 0x00 {
+		t.Errorf("enabled: RAM 0x%02X, ROM 0x%02X, want 0x42 and 0x00", atVBlank, gb.Peek(0x4A17))
+	}
+
+	gb.SetCheatsEnabled(false)
+	gb.RunFrames(1)
+	if atVBlank != 0x05 {
+		t.Errorf("disabled: RAM 0x%02X at VBlank, want the game's 0x05", atVBlank)
+	}
+	if got := gb.Peek(0x4A17); got != 0xC8 {
+		t.Errorf("disabled: ROM 0x%02X, want the original 0xC8", got)
+	}
+
+	gb.SetCheatsEnabled(true)
+	gb.RunFrames(1)
+	if atVBlank != 0x42 || gb.Peek(0x4A17) != 0x00 || !gb.CheatsEnabled() {
+		t.Errorf("re-enabled: RAM 0x%02X, ROM 0x%02X, want 0x42 and 0x00", atVBlank, gb.Peek(0x4A17))
+	}
+}
+
+func cgbTestROM() []byte {
+	rom := make([]byte, 0x8000)
+	rom[0x0143] = 0x80 // CGB enhanced
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x01, // LD A,0x01
+		0xE0, 0x4D, // LDH (0x4D),A ; arm the speed switch
+		0x10, 0x00, // STOP
+		0x18, 0xFE, // JR -2
+	})
+	return rom
+}
+
+func Test_CGBMode(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(cgbTestROM()); err != nil {
+		t.Fatal(err)
+	}
+	if !gb.CGBMode() {
+		t.Fatal("CGB mode not detected from the header flag")
+	}
+
+	// VRAM bank 1 through VBK
+	gb.CPU().Memory().Write(0xFF4F, 0x01)
+	gb.CPU().Memory().Write(0x8000, 0xAB)
+	gb.CPU().Memory().Write(0xFF4F, 0x00)
+	if got := gb.Peek(0x8000); got != 0x00 {
+		t.Errorf("VRAM bank 0 = 0x%02X, want 0x00", got)
+	}
+	if vram := gb.VRAM(1); vram == nil || vram[0] != 0xAB {
+		t.Errorf("VRAM(1)[0] = %v, want 0xAB", vram)
+	}
+
+	// WRAM bank 3 through SVBK
+	gb.CPU().Memory().Write(0xFF70, 0x03)
+	gb.CPU().Memory().Write(0xD000, 0xCD)
+	gb.CPU().Memory().Write(0xFF70, 0x01)
+	if got := gb.Peek(0xD000); got != 0x00 {
+		t.Errorf("WRAM bank 1 = 0x%02X, want 0x00", got)
+	}
+
+	// background palette 0 colour 1 with auto-increment
+	gb.CPU().Memory().Write(0xFF68, 0x82)
+	gb.CPU().Memory().Write(0xFF69, 0x1F)
+	gb.CPU().Memory().Write(0xFF69, 0x7C)
+	if got := gb.Peek(0xFF68); got != 0xC4 {
+		t.Errorf("BCPS = 0x%02X, want 0xC4", got)
+	}
+
+	for range 3 {
+		gb.Step()
+	}
+	if !gb.DoubleSpeed() {
+		t.Error("STOP with KEY1 armed did not switch to double speed")
+	}
+	if got := gb.Peek(0xFF4D); got != 0xFE {
+		t.Errorf("KEY1 = 0x%02X, want 0xFE", got)
+	}
+}
+
+func Test_ForcedDMGMode(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(cgbTestROM()); err != nil {
+		t.Fatal(err)
+	}
+	gb.SetCGBMode(false)
+	if gb.CGBMode() {
+		t.Fatal("CGBMode() = true after forcing DMG")
+	}
+
+	for _, addr := range []uint16{0xFF4D, 0xFF4F, 0xFF68, 0xFF70} {
+		if got := gb.Peek(addr); got != 0xFF {
+			t.Errorf("Peek(0x%04X) = 0x%02X, want 0xFF", addr, got)
+		}
+	}
+	if gb.VRAM(1) != nil {
+		t.Error("VRAM(1) != nil in DMG mode")
+	}
+
+	for range 3 {
+		gb.Step()
+	}
+	if gb.DoubleSpeed() {
+		t.Error("speed switch happened in DMG mode")
+	}
+}
+
+func Test_KEY0DMGCompat(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(cgbTestROM()); err != nil {
+		t.Fatal(err)
+	}
+	// the skipped boot wrote BANK, locking KEY0
+	gb.Poke(gbc.ADDR_KEY0, gbc.KEY0_DMG_COMPAT)
+	if !gb.CGBMode() {
+		t.Fatal("KEY0 still writable after the boot")
+	}
+	if got := gb.Peek(gbc.ADDR_BANK); got != 0x01 {
+		t.Errorf("BANK = 0x%02X, want 0x01", got)
+	}
+
+	if err := gb.LoadROM(make([]byte, 0x8000)); err != nil {
+		t.Fatal(err)
+	}
+	gb.SetModel(gbc.MODEL_CGB)
+	gb.Poke(0xFF68, 0x80)
+	gb.Poke(0xFF69, 0x1F)
+	for _, addr := range []uint16{0xFF68, 0xFF69} {
+		if got := gb.Peek(addr); got != 0xFF {
+			t.Errorf("Peek(0x%04X) = 0x%02X, want 0xFF", addr, got)
+		}
+	}
+}
+
+func Test_InputState(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	gb.Press(joypad.BUTTON_A)
+	gb.Press(joypad.BUTTON_DOWN)
+
+	tests := []struct {
+		selectBits byte
+		want       byte
+	}{
+		{0x30, 0xFF}, // nothing selected
+		{0x20, 0xE7}, // directions: DOWN
+		{0x10, 0xDE}, // actions: A
+		{0x00, 0xC6}, // both
+	}
+	for _, tc := range tests {
+		gb.Poke(joypad.ADDR_P1, tc.selectBits)
+		if got := gb.JoypadRegister(); got != tc.want {
+			t.Errorf("select 0x%02X: JoypadRegister() = 0x%02X, want 0x%02X", tc.selectBits, got, tc.want)
+		}
+		if got, want := gb.PhysicalInputs(), uint8(joypad.BUTTON_A|joypad.BUTTON_DOWN); got != want {
+			t.Errorf("select 0x%02X: PhysicalInputs() = 0x%02X, want 0x%02X", tc.selectBits, got, want)
+		}
+	}
+
+	// queued changes are physical before the game sees them
+	gb.SetInputPollMode(gbc.INPUT_POLL_PER_FRAME)
+	gb.Release(joypad.BUTTON_A)
+	if got, want := gb.PhysicalInputs(), uint8(joypad.BUTTON_DOWN); got != want {
+		t.Errorf("PhysicalInputs() = 0x%02X, want 0x%02X", got, want)
+	}
+	if got := gb.JoypadRegister(); got != 0xC6 {
+		t.Errorf("JoypadRegister() = 0x%02X before VBlank, want 0xC6", got)
+	}
+}
+
+func Test_InputPollPerFrame(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	// joypad handler counts interrupts in HRAM
+	copy(rom[0x0060:], []byte{
+		0xF0, 0x80, // LDH A,(0x80)
+		0x3C,       // INC A
+		0xE0, 0x80, // LDH (0x80),A
+		0xD9, // RETI
+	})
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x10, // LD A,0x10
+		0xE0, 0xFF, // LDH (0xFF),A ; IE = joypad
+		0xE0, 0x00, // LDH (0x00),A ; select action buttons
+		0xFB,       // EI
+		0x18, 0xFE, // JR -2
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	gb.SetInputPollMode(gbc.INPUT_POLL_PER_FRAME)
+	for range 4 {
+		gb.Step()
+	}
+
+	gb.Press(joypad.BUTTON_A)
+	gb.Release(joypad.BUTTON_A)
+	gb.RunCycles(100)
+	if got := gb.Peek(0xFF80); got != 0 {
+		t.Fatalf("%d joypad interrupts before VBlank, want 0", got)
+	}
+
+	gb.RunCycles(gbc.FRAME_CYCLES)
+	if got := gb.Peek(0xFF80); got != 1 {
+		t.Errorf("%d joypad interrupts after VBlank, want 1", got)
+	}
+}
+
+func Test_DisassembleRange(t *testing.T) {
+	gb := newTestGB(t,
+		0x00,             // NOP
+		0xC3, 0x50, 0x01, // JP $0150
+		0x3E, 0x42, // LD A,$42
+		0xCB, 0x7E, // BIT 7,(HL)
+		0xE0, 0x40, // LDH ($FF40),A
+		0xF8, 0xFE, // LD HL,SP-2
+		0x20, 0xF2, // JR NZ,$0100
+		0x10, 0x00, // STOP
+		0xD3, // illegal
+	)
+
+	want := []gbc.DisasmLine{
+		{Address: 0x0100, Bytes: []byte{0x00}, Mnemonic: "NOP"},
+		{Address: 0x0101, Bytes: []byte{0xC3, 0x50, 0x01}, Mnemonic: "JP $0150"},
+		{Address: 0x0104, Bytes: []byte{0x3E, 0x42}, Mnemonic: "LD A,$42"},
+		{Address: 0x0106, Bytes: []byte{0xCB, 0x7E}, Mnemonic: "BIT 7,(HL)"},
+		{Address: 0x0108, Bytes: []byte{0xE0, 0x40}, Mnemonic: "LDH ($FF40),A"},
+		{Address: 0x010A, Bytes: []byte{0xF8, 0xFE}, Mnemonic: "LD HL,SP-2"},
+		{Address: 0x010C, Bytes: []byte{0x20, 0xF2}, Mnemonic: "JR NZ,$0100"},
+		{Address: 0x010E, Bytes: []byte{0x10, 0x00}, Mnemonic: "STOP"},
+		{Address: 0x0110, Bytes: []byte{0xD3}, Mnemonic: "ILLEGAL_D3"},
+	}
+	got := gb.DisassembleRange(0x0100, len(want))
+	if len(got) != len(want) {
+		t.Fatalf("DisassembleRange() returned %d lines, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Address != want[i].Address || got[i].Mnemonic != want[i].Mnemonic || !slices.Equal(got[i].Bytes, want[i].Bytes) {
+			t.Errorf("line %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func Test_OpcodeAt(t *testing.T) {
+	gb := newTestGB(t,
+		0x00,             // NOP
+		0xC3, 0x50, 0x01, // JP $0150
+		0xCB, 0x7E, // BIT 7,(HL)
+		0xCB, 0x11, // RL C
+		0x20, 0xF6, // JR NZ,$0100
+	)
+
+	tests := []struct {
+		address  uint16
+		mnemonic string
+		length   int
+		cycles   int
+	}{
+		{0x0100, "NOP", 1, 4},
+		{0x0101, "JP $0150", 3, 16},
+		{0x0104, "BIT 7,(HL)", 2, 12},
+		{0x0106, "RL C", 2, 8},
+		{0x0108, "JR NZ,$0100", 2, 8},
+	}
+	for _, tc := range tests {
+		mnemonic, length, cycles := gb.OpcodeAt(tc.address)
+		if mnemonic != tc.mnemonic || length != tc.length || cycles != tc.cycles {
+			t.Errorf("OpcodeAt(0x%04X) = %q, %d, %d, want %q, %d, %d",
+				tc.address, mnemonic, length, cycles, tc.mnemonic, tc.length, tc.cycles)
+		}
+	}
+	if pc := gb.CPU().PC; pc != 0x0100 {
+		t.Errorf("PC = 0x%04X after OpcodeAt, want 0x0100", pc)
+	}
+}
+
+func Test_CheatSearch(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(make([]byte, 0x8000)); err != nil {
+		t.Fatal(err)
+	}
+	// lives at 0xC123, the same value elsewhere in WRAM and HRAM
+	gb.Poke(0xC010, 3)
+	gb.Poke(0xC123, 3)
+	gb.Poke(0xDFFF, 3)
+	gb.Poke(0xFF90, 3)
+
+	found := gb.CheatSearchEqual(3)
+	if want := []uint16{0xC010, 0xC123, 0xDFFF, 0xFF90}; !slices.Equal(found, want) {
+		t.Fatalf("CheatSearchEqual(3) = %04X, want %04X", found, want)
+	}
+
+	gb.Poke(0xC123, 2) // lost a life
+	found = gb.CheatSearchNarrow(found, 2)
+	if want := []uint16{0xC123}; !slices.Equal(found, want) {
+		t.Errorf("CheatSearchNarrow(2) = %04X, want %04X", found, want)
+	}
+}
+
+func Test_TraceToFile(t *testing.T) {
+	gb := newTestGB(t,
+		0x00,       // NOP
+		0x3C,       // INC A
+		0x18, 0xFC, // JR -4
+	)
+
+	path := filepath.Join(t.TempDir(), "trace.log")
+	if err := gb.TraceToFile(path, gbc.TRACE_DOCTOR); err != nil {
+		t.Fatal(err)
+	}
+	const steps = 1000
+	for range steps {
+		gb.Step()
+	}
+	if err := gb.StopTrace(); err != nil {
+		t.Fatal(err)
+	}
+	gb.Step() // not traced
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != steps {
+		t.Fatalf("trace has %d lines, want %d", len(lines), steps)
+	}
+	if want := "A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,3C,18,FC"; lines[0] != want {
+		t.Errorf("first line = %q, want %q", lines[0], want)
+	}
+
+	binPath := filepath.Join(t.TempDir(), "trace.bin")
+	if err := gb.TraceToFile(binPath, gbc.TRACE_BINARY); err != nil {
+		t.Fatal(err)
+	}
+	for range steps {
+		gb.Step()
+	}
+	if err := gb.StopTrace(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(binPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != steps*16 {
+		t.Errorf("binary trace size = %d, want %d", info.Size(), steps*16)
+	}
+}
+
+func Test_Subscribe(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = 0x09 // ROM+RAM+BATTERY
+	rom[0x0149] = 0x02 // 8KB
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x5A, // LD A,0x5A
+		0xE0, 0x01, // LDH (SB),A
+		0x3E, 0x81, // LD A,0x81
+		0xE0, 0x02, // LDH (SC),A
+		0x18, 0xFE, // JR -2
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []gbc.Event
+	record := func(e gbc.Event) { events = append(events, e) }
+	gb.Subscribe(gbc.EVENT_BATTERY_SAVE_DIRTY, record)
+	gb.Subscribe(gbc.EVENT_SERIAL_OUT, record)
+	gb.Subscribe(gbc.EVENT_VBLANK, record)
+
+	gb.Poke(0xA000, 0x12)
+	gb.Poke(0xA001, 0x34) // already dirty
+	if len(events) != 1 || events[0].Type != gbc.EVENT_BATTERY_SAVE_DIRTY {
+		t.Fatalf("events after RAM writes = %+v, want one BatterySaveDirty", events)
+	}
+
+	events = nil
+	gb.RunCycles(gbc.FRAME_CYCLES)
+	want := []gbc.Event{
+		{Type: gbc.EVENT_SERIAL_OUT, Value: 0x5A},
+		{Type: gbc.EVENT_VBLANK, Frame: 1},
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %+v, want %+v", events, want)
+	}
+}
+
+func Test_AutoSave(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = 0x09                     // ROM+RAM+BATTERY
+	rom[0x0149] = 0x02                     // 8KB
+	copy(rom[0x0100:], []byte{0x18, 0xFE}) // JR -2
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	gb.SetClock(func() time.Time { return now })
+
+	path := filepath.Join(t.TempDir(), "game.sav")
+	if err := gb.EnableAutoSave(path, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	gb.Poke(0xA000, 0x12)
+	gb.RunCycles(gbc.FRAME_CYCLES)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("save written before the interval elapsed (err = %v)", err)
+	}
+
+	now = now.Add(2 * time.Second)
+	gb.RunCycles(gbc.FRAME_CYCLES)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0x2000 {
+		t.Fatalf("save is %d bytes, want 8192", len(data))
+	}
+	if data[0] != 0x12 {
+		t.Fatalf("save starts with %02X, want 12", data[0])
+	}
+
+	// a change within the interval is only written by Stop
+	gb.Poke(0xA001, 0x34)
+	gb.RunCycles(gbc.FRAME_CYCLES)
+	if data, _ := os.ReadFile(path); len(data) < 2 || data[1] != 0x00 {
+		t.Fatal("save rewritten before the interval elapsed")
+	}
+	if err := gb.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); len(data) < 2 || data[1] != 0x34 {
+		t.Errorf("Stop did not flush the save: % X..., want byte 1 = 34", data[:min(len(data), 2)])
+	}
+
+	// no VBlank with the LCD off, the save is still written
+	gb.Poke(0xFF40, 0x00)
+	gb.Poke(0xA002, 0x56)
+	now = now.Add(2 * time.Second)
+	gb.RunCycles(2 * gbc.FRAME_CYCLES)
+	if data, _ := os.ReadFile(path); len(data) < 3 || data[2] != 0x56 {
+		t.Error("save not written with the LCD off")
+	}
+}
+
+func Test_AutoSaveNoBattery(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = 0x08 // ROM+RAM
+	rom[0x0149] = 0x02 // 8KB
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "game.sav")
+	if err := gb.EnableAutoSave(path, time.Second); !errors.Is(err, gbc.ErrNoBattery) {
+		t.Errorf("EnableAutoSave() = %v, want ErrNoBattery", err)
+	}
+}
+
+func Test_SetDMGPalette(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	for i := uint16(0); i < 16; i++ {
+		gb.Poke(0x8010+i, 0xFF) // tile 1: colour 3
+	}
+	for i := uint16(0); i < 16; i += 2 {
+		gb.Poke(0x8020+i, 0xFF) // tile 2: colour 1
+	}
+	gb.Poke(0x9800, 0x01) // top-left background tile
+	gb.Poke(0xFE00, 16)   // sprite Y
+	gb.Poke(0xFE01, 24)   // sprite X: screen 16-23
+	gb.Poke(0xFE02, 0x02) // tile
+	gb.Poke(0xFE03, 0x10) // OBP1
+	gb.Poke(0xFF40, 0x93) // LCD, sprites and background on
+	gb.Poke(0xFF47, 0xE4) // BGP
+	gb.Poke(0xFF49, 0xE4) // OBP1
+
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+	green := color.RGBA{0, 0xFF, 0, 0xFF}
+	blue := color.RGBA{0, 0, 0xFF, 0xFF}
+	white := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	gb.SetDMGPalette(
+		[4]color.RGBA{white, green, green, red},
+		[4]color.RGBA{white, white, white, white},
+		[4]color.RGBA{white, blue, blue, blue},
+	)
+	gb.RunCycles(2 * gbc.FRAME_CYCLES)
+
+	frame := gb.Frame()
+	checks := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, red},    // background colour 3
+		{8, 0, white},  // background colour 0
+		{16, 0, blue},  // sprite through OBP1
+		{23, 7, blue},  // sprite corner
+		{24, 0, white}, // past the sprite
+	}
+	for _, c := range checks {
+		if got := frame.RGBAAt(c.x, c.y); got != c.want {
+			t.Errorf("pixel (%d,%d) = %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+}
+
+func Test_WarmAndColdReset(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = 0x09 // ROM+RAM+BATTERY
+	rom[0x0149] = 0x02 // 8KB
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x42, // LD A,0x42
+		0x18, 0xFE, // JR -2
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	gb.Poke(0xC000, 0x11) // WRAM
+	gb.Poke(0xFF80, 0x22) // HRAM
+	gb.Poke(0xA000, 0x33) // battery RAM
+	gb.RunCycles(100)
+
+	gb.WarmReset()
+	if pc, a := gb.CPU().PC, gb.CPU().A; pc != 0x0100 || a != 0x01 {
+		t.Errorf("after WarmReset PC=0x%04X A=0x%02X, want 0x0100 and 0x01", pc, a)
+	}
+	for addr, want := range map[uint16]byte{0xC000: 0x11, 0xFF80: 0x22, 0xA000: 0x33} {
+		if got := gb.Peek(addr); got != want {
+			t.Errorf("after WarmReset [0x%04X] = 0x%02X, want 0x%02X", addr, got, want)
+		}
+	}
+
+	gb.RunCycles(100)
+	gb.ColdReset()
+	if pc := gb.CPU().PC; pc != 0x0100 {
+		t.Errorf("after ColdReset PC=0x%04X, want 0x0100", pc)
+	}
+	for addr, want := range map[uint16]byte{0xC000: 0x00, 0xFF80: 0x00, 0xA000: 0x33} {
+		if got := gb.Peek(addr); got != want {
+			t.Errorf("after ColdReset [0x%04X] = 0x%02X, want 0x%02X", addr, got, want)
+		}
+	}
+	if got := gb.Peek(0xFF40); got != 0x91 {
+		t.Errorf("after ColdReset LCDC = 0x%02X, want 0x91", got)
+	}
+}
+
+func Test_SetModel(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(cgbTestROM()); err != nil {
+		t.Fatal(err)
+	}
+	if gb.Model() != gbc.MODEL_CGB || gb.CPU().A != 0x11 {
+		t.Errorf("auto model = %v A = 0x%02X, want CGB 0x11", gb.Model(), gb.CPU().A)
+	}
+
+	tests := []struct {
+		model gbc.Model
+		a     byte
+		cgb   bool
+	}{
+		{gbc.MODEL_DMG, 0x01, false},
+		{gbc.MODEL_MGB, 0xFF, false},
+		{gbc.MODEL_CGB, 0x11, true},
+		{gbc.MODEL_AGB, 0x11, true},
+	}
+	for _, tc := range tests {
+		gb.SetModel(tc.model)
+		if got := gb.CPU().A; got != tc.a {
+			t.Errorf("model %v: A = 0x%02X, want 0x%02X", tc.model, got, tc.a)
+		}
+		if gb.CGBMode() != tc.cgb {
+			t.Errorf("model %v: CGBMode() = %v, want %v", tc.model, gb.CGBMode(), tc.cgb)
+		}
+		if gb.CPU().PC != 0x0100 {
+			t.Errorf("model %v: PC = 0x%04X, want 0x0100", tc.model, gb.CPU().PC)
+		}
+	}
+
+	// dropping the boot ROM keeps the model
+	gb.SetModel(gbc.MODEL_MGB)
+	if err := gb.SetBootROM(nil); err != nil {
+		t.Fatal(err)
+	}
+	if gb.Model() != gbc.MODEL_MGB || gb.CPU().A != 0xFF {
+		t.Errorf("after SetBootROM(nil): model %v A = 0x%02X, want MGB 0xFF", gb.Model(), gb.CPU().A)
+	}
+}
+
+func Test_SetModelDMGCart(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(make([]byte, 0x8000)); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []gbc.Model{gbc.MODEL_CGB, gbc.MODEL_AGB} {
+		gb.SetModel(m)
+		if gb.CGBMode() {
+			t.Errorf("model %v: CGBMode() = true for a DMG cartridge", m)
+		}
+		if gb.CPU().A != 0x11 || gb.CPU().E != 0x08 || gb.CPU().L != 0x7C {
+			t.Errorf("model %v: A = 0x%02X E = 0x%02X L = 0x%02X, want 0x11 0x08 0x7C", m, gb.CPU().A, gb.CPU().E, gb.CPU().L)
+		}
+		if got := gb.Peek(gbc.ADDR_KEY0); got != 0xF7 {
+			t.Errorf("model %v: KEY0 = 0x%02X, want 0xF7", m, got)
+		}
+		gb.Poke(gbc.ADDR_KEY0, 0x00)
+		if gb.CGBMode() {
+			t.Errorf("model %v: KEY0 still writable after the boot", m)
+		}
+	}
+}
+
+func Test_ReadWriteRegister(t *testing.T) {
+	gb := gbc.NewGameBoy()
+
+	if v, err := gb.ReadRegister("a"); err != nil || v != 0x01 {
+		t.Errorf("ReadRegister(a) = 0x%X, %v, want 0x01", v, err)
+	}
+	if v, err := gb.ReadRegister("HL"); err != nil || v != 0x014D {
+		t.Errorf("ReadRegister(HL) = 0x%X, %v, want 0x014D", v, err)
+	}
+
+	writes := []struct {
+		name string
+		v    uint16
+	}{{"BC", 0x1234}, {"A", 0x42}, {"SP", 0xDFF0}, {"PC", 0x0150}, {"IME", 1}, {"AF", 0x99FF}}
+	for _, w := range writes {
+		if err := gb.WriteRegister(w.name, w.v); err != nil {
+			t.Fatalf("WriteRegister(%s) = %v", w.name, err)
+		}
+	}
+	c := gb.CPU()
+	if c.B != 0x12 || c.C != 0x34 || c.SP != 0xDFF0 || c.PC != 0x0150 || !c.IME {
+		t.Errorf("CPU = %+v after writes", c.Registers())
+	}
+	if c.A != 0x99 || c.F != 0xF0 {
+		t.Errorf("AF = %02X%02X, want 99F0", c.A, c.F)
+	}
+
+	if _, err := gb.ReadRegister("IX"); !errors.Is(err, gbc.ErrUnknownRegister) {
+		t.Errorf("ReadRegister(IX) = %v, want ErrUnknownRegister", err)
+	}
+	if err := gb.WriteRegister("B", 0x100); err == nil {
+		t.Error("WriteRegister(B, 0x100) succeeded")
+	}
+}
+
+func Test_RenderToImage(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	for i := uint16(0); i < 16; i++ {
+		gb.Poke(0x8010+i, 0xFF) // tile 1: colour 3
+	}
+	gb.Poke(0x9800, 0x01) // top-left background tile
+	gb.Poke(0xFF47, 0xE4) // BGP
+	gb.Poke(0xFF40, 0x91)
+	gb.RunCycles(2 * gbc.FRAME_CYCLES)
+
+	img := gb.RenderToImage()
+	if got, want := img.Bounds(), image.Rect(0, 0, 160, 144); got != want {
+		t.Errorf("Bounds() = %v, want %v", got, want)
+	}
+	if got, want := img.At(0, 0), (color.RGBA{0x00, 0x00, 0x00, 0xFF}); got != want {
+		t.Errorf("pixel (0,0) = %v, want %v", got, want)
+	}
+	if got, want := img.At(8, 0), (color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}); got != want {
+		t.Errorf("pixel (8,0) = %v, want %v", got, want)
+	}
+}
+
+func Test_RunWithoutCartridge(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	if gb.HasCartridge() {
+		t.Error("HasCartridge() = true before LoadROM")
+	}
+	if err := gb.Run(); !errors.Is(err, gbc.ErrNoCartridge) {
+		t.Errorf("Run() = %v, want ErrNoCartridge", err)
+	}
+	if err := gb.RunContext(context.Background()); !errors.Is(err, gbc.ErrNoCartridge) {
+		t.Errorf("RunContext() = %v, want ErrNoCartridge", err)
+	}
+	if gb.CPU().PC != 0x0100 {
+		t.Errorf("PC = %04X, want nothing executed", gb.CPU().PC)
+	}
+
+	if err := gb.LoadROM(make([]byte, 0x8000)); err != nil {
+		t.Fatal(err)
+	}
+	if !gb.HasCartridge() {
+		t.Error("HasCartridge() = false after LoadROM")
+	}
+	if err := gb.Run(); err != nil {
+		t.Errorf("Run() = %v after LoadROM", err)
+	}
+}
+
+// installDMARoutine copies to 0xFF80 the routine games run OAM DMA from:
+// write the page in A to DMA, then wait out the 160 M-cycles in HRAM,
+// since the rest of the bus is unreachable meanwhile.
+func installDMARoutine(gb *gbc.GameBoy) {
+	routine := []byte{
+		0xE0, 0x46, // LDH (DMA),A
+		0x3E, 0x28, // LD A,40
+		0x3D,       // DEC A
+		0x20, 0xFD, // JR NZ,-3
+		0xC9, // RET
+	}
+	for i, b := range routine {
+		gb.Poke(0xFF80+uint16(i), b)
+	}
+}
+
+func Test_DMAFromHRAM(t *testing.T) {
+	gb := newTestGB(t,
+		0x3E, 0xC1, // LD A,0xC1
+		0xCD, 0x80, 0xFF, // CALL 0xFF80
+		0x18, 0xFE, // JR -2
+	)
+	installDMARoutine(gb)
+	source := make([]byte, 160)
+	for i := range source {
+		source[i] = byte(i*7 + 1)
+		gb.Poke(0xC100+uint16(i), source[i])
+	}
+
+	for gb.CPU().PC != 0x0105 {
+		gb.Step()
+	}
+	if got := gb.OAM(); !bytes.Equal(got, source) {
+		t.Errorf("OAM after the HRAM routine = %X, want %X", got, source)
+	}
+
+	// TriggerDMA copies the same way
+	for i := range source {
+		source[i] = ^source[i]
+		gb.Poke(0xC200+uint16(i), source[i])
+	}
+	gb.TriggerDMA(0xC2)
+	gb.RunCycles(640)
+	if got := gb.OAM(); !bytes.Equal(got, source) {
+		t.Errorf("OAM after TriggerDMA = %X, want %X", got, source)
+	}
+}
+
+func Test_ExportTilemapPNG(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	gb.Poke(0xFF40, 0x00)          // LCD off to reach VRAM freely
+	for i := uint16(0); i < 16; i++ {
+		gb.Poke(0x8010+i, 0xFF) // tile 1: colour 3
+	}
+	gb.Poke(0x9800+32+1, 0x01) // map tile (1,1)
+	gb.Poke(0xFF47, 0xE4)      // BGP
+	gb.Poke(0xFF40, 0x91)      // map 0, tiles at 0x8000
+	gb.Poke(0xFF42, 100)       // SCY
+	gb.Poke(0xFF43, 200)       // SCX
+
+	var buf bytes.Buffer
+	if err := gb.ExportTilemapPNG(&buf, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 256, 256); got != want {
+		t.Errorf("Bounds() = %v, want %v", got, want)
+	}
+	black := color.RGBA{0x00, 0x00, 0x00, 0xFF}
+	white := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	red := color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+	checks := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{8, 8, black},
+		{15, 15, black},
+		{16, 8, white},
+		{200, 100, red}, // viewport top-left corner
+		{50, 100, red},  // top edge, wrapped past x=255
+		{200, 150, red}, // left edge
+		{103, 243, red}, // bottom-right corner
+		{150, 150, white},
+	}
+	for _, c := range checks {
+		if got := color.RGBAModel.Convert(img.At(c.x, c.y)); got != c.want {
+			t.Errorf("pixel (%d,%d) = %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+
+	if err := gb.ExportTilemapPNG(io.Discard, 2, false); err == nil {
+		t.Error("ExportTilemapPNG(map 2) succeeded, want an error")
+	}
+}
+
+func Test_VerifyDeterminism(t *testing.T) {
+	// shows the button lines through BGP
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x10, // LD A,0x10: select buttons
+		0xE0, 0x00, // LDH (P1),A
+		0xF0, 0x00, // LDH A,(P1)
+		0xE0, 0x47, // LDH (BGP),A
+		0x18, 0xFA, // JR -6
+	})
+	inputs := []gbc.InputEvent{
+		{Frame: 2, Button: joypad.BUTTON_A, Pressed: true},
+		{Frame: 5, Button: joypad.BUTTON_A},
+	}
+	gb := gbc.NewGameBoy()
+	if !gb.VerifyDeterminism(rom, inputs, 10) {
+		t.Error("VerifyDeterminism() = false for a ROM depending only on inputs")
+	}
+}
+
+func Test_VerifyDeterminismRTC(t *testing.T) {
+	// shows the RTC seconds through BGP
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = 0x0F // MBC3+TIMER+BATTERY
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x0A, 0xEA, 0x00, 0x00, // enable RAM and RTC
+		0x3E, 0x08, 0xEA, 0x00, 0x40, // map the seconds register
+		0xAF, 0xEA, 0x00, 0x60, // latch: write 0...
+		0x3E, 0x01, 0xEA, 0x00, 0x60, // ...then 1
+		0xFA, 0x00, 0xA0, // LD A,(0xA000)
+		0xE0, 0x47, // LDH (BGP),A
+		0xC3, 0x0A, 0x01, // JP 0x010A
+	})
+
+	// a host clock speeding up over time, so that the second run sees
+	// time pass at another rate than the first
+	calls := 0
+	gb := gbc.NewGameBoy()
+	gb.SetClock(func() time.Time {
+		calls++
+		return time.Unix(0, 0).Add(time.Duration(calls*calls) * time.Millisecond)
+	})
+	if gb.VerifyDeterminism(rom, nil, 10) {
+		t.Error("VerifyDeterminism() = true for a ROM showing the RTC")
+	}
+}
+
+func Test_LoadROMFromFS(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0134:], "FIXTURE")
+	copy(rom[0x0100:], []byte{0x18, 0xFE}) // JR -2
+	fsys := fstest.MapFS{"roms/fixture.gb": {Data: rom}}
+
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROMFromFS(fsys, "roms/fixture.gb"); err != nil {
+		t.Fatal(err)
+	}
+	if !gb.HasCartridge() || gb.Title() != "FIXTURE" {
+		t.Errorf("HasCartridge() = %v, Title() = %q, want the fixture loaded", gb.HasCartridge(), gb.Title())
+	}
+
+	if err := gb.LoadROMFromFS(fsys, "roms/missing.gb"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadROMFromFS(missing) = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func Test_SetBootROMFile(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0134:], "FIXTURE")
+	dir := t.TempDir()
+	// LD A,0x01; LDH (BANK),A hands over to the cartridge
+	handOver := []byte{0x3E, 0x01, 0xE0, 0x50}
+
+	dmg := make([]byte, gbc.DMG_BOOT_SIZE)
+	copy(dmg, handOver)
+	// a CGB dump without the gap: 0x0100 onwards is mapped at 0x0200
+	cgb := make([]byte, gbc.CGB_BOOT_SIZE)
+	copy(cgb, handOver)
+	cgb[0x100] = 0xAA
+	for name, data := range map[string][]byte{"dmg.bin": dmg, "cgb.bin": cgb, "bad.bin": make([]byte, 300)} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		file string
+		want gbc.Model
+		cgb  bool
+	}{
+		{"dmg.bin", gbc.MODEL_DMG, false},
+		{"cgb.bin", gbc.MODEL_CGB, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.file, func(t *testing.T) {
+			gb := gbc.NewGameBoy()
+			if err := gb.LoadROM(rom); err != nil {
+				t.Fatal(err)
+			}
+			if err := gb.SetBootROMFile(filepath.Join(dir, tc.file)); err != nil {
+				t.Fatal(err)
+			}
+			if gb.BootModel() != tc.want || gb.Model() != tc.want || gb.CGBMode() != tc.cgb {
+				t.Errorf("BootModel() = %v, Model() = %v, CGBMode() = %v, want %v, %v, %v",
+					gb.BootModel(), gb.Model(), gb.CGBMode(), tc.want, tc.want, tc.cgb)
+			}
+			if pc := gb.CPU().PC; pc != 0x0000 {
+				t.Errorf("PC = %04X, want 0000", pc)
+			}
+			if got := gb.Peek(0x0000); got != 0x3E {
+				t.Errorf("0x0000 = %02X, want the boot ROM's 3E", got)
+			}
+			// the header shows through the CGB boot ROM's gap
+			if got := gb.Peek(0x0134); got != 'F' {
+				t.Errorf("0x0134 = %02X, want the cartridge title", got)
+			}
+			if tc.cgb {
+				if got := gb.Peek(0x0200); got != 0xAA {
+					t.Errorf("0x0200 = %02X, want the boot ROM's AA", got)
+				}
+			}
+
+			gb.Step()
+			gb.Step()
+			if got := gb.Peek(0x0000); got != 0x00 {
+				t.Errorf("0x0000 after writing BANK = %02X, want the cartridge's 00", got)
+			}
+		})
+	}
+
+	gb := gbc.NewGameBoy()
+	if err := gb.SetBootROMFile(filepath.Join(dir, "bad.bin")); !errors.Is(err, gbc.ErrBootROMSize) {
+		t.Errorf("SetBootROMFile(300 bytes) = %v, want ErrBootROMSize", err)
+	}
+	if err := gb.SetBootROMFile(filepath.Join(dir, "missing.bin")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("SetBootROMFile(missing) = %v, want fs.ErrNotExist", err)
+	}
+	if gb.BootModel() != gbc.MODEL_AUTO {
+		t.Errorf("BootModel() = %v after failures, want MODEL_AUTO", gb.BootModel())
+	}
+}
+
+func Test_ProfileCallback(t *testing.T) {
+	gb := newTestGB(t, 0x00, 0x18, 0xFD) // NOP; JR -3
+	now, reads := time.Unix(0, 0), 0
+	gb.SetClock(func() time.Time {
+		reads++
+		return now
+	})
+	// an added component taking a nanosecond per cycle, and a slow
+	// subscriber that is front-end time rather than PPU time
+	gb.AddComponent(gbc.ComponentFunc(func(cycles int) { now = now.Add(time.Duration(cycles)) }))
+	gb.Subscribe(gbc.EVENT_VBLANK, func(gbc.Event) { now = now.Add(10 * time.Millisecond) })
+
+	var profiles []gbc.FrameProfile
+	gb.SetProfileCallback(func(p gbc.FrameProfile) { profiles = append(profiles, p) })
+	gb.RunFrames(5)
+
+	if len(profiles) != 5 {
+		t.Fatalf("%d profiles after 5 frames, want 5", len(profiles))
+	}
+	if reads > 5*gbc.FRAME_CYCLES/4 {
+		t.Errorf("clock read %d times in 5 frames, want at most %d", reads, 5*gbc.FRAME_CYCLES/4)
+	}
+	for i, p := range profiles[1:] {
+		if p.Frame != profiles[i].Frame+1 {
+			t.Errorf("profile %d is for frame %d, want %d", i+1, p.Frame, profiles[i].Frame+1)
+		}
+		// NOP and JR alternate, 4 and 12 cycles
+		if p.Instructions != gbc.FRAME_CYCLES/8 {
+			t.Errorf("frame %d: %d instructions, want %d", p.Frame, p.Instructions, gbc.FRAME_CYCLES/8)
+		}
+		if p.Total != 10*time.Millisecond+gbc.FRAME_CYCLES {
+			t.Errorf("frame %d: Total %v, want %v", p.Frame, p.Total, 10*time.Millisecond+gbc.FRAME_CYCLES)
+		}
+		if p.Present != 10*time.Millisecond || p.PPU != 0 || p.APU != 0 {
+			t.Errorf("frame %d: Present %v PPU %v APU %v, want the subscriber in Present only", p.Frame, p.Present, p.PPU, p.APU)
+		}
+		// sampled, so only close to the time the component took
+		if p.Other < gbc.FRAME_CYCLES*9/10 || p.Other > gbc.FRAME_CYCLES*11/10 {
+			t.Errorf("frame %d: Other %v, want about %v", p.Frame, p.Other, time.Duration(gbc.FRAME_CYCLES))
+		}
+	}
+
+	gb.SetProfileCallback(nil)
+	gb.RunFrames(1)
+	if len(profiles) != 5 {
+		t.Errorf("profile reported after SetProfileCallback(nil)")
+	}
+}
+
+func Test_DumpDisassembly(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0150:], []byte{
+		0x3E, 0x42, // LD A,$42
+		0xEA, 0x00, 0xC0, // LD ($C000),A
+		0xD3,       // illegal
+		0xCB, 0x37, // SWAP A
+		0x18, 0xF6, // JR $0150
+		0xC3, 0x50, // JP cut by the end of the range
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := gb.DumpDisassembly(&out, 0x0150, 0x015B); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"0150  3E 42     LD A,$42",
+		"0152  EA 00 C0  LD ($C000),A",
+		"0155  D3        .db $D3",
+		"0156  CB 37     SWAP A",
+		"0158  18 F6     JR $0150",
+		"015A  C3        .db $C3",
+		"015B  50        LD D,B",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); !slices.Equal(got, want) {
+		t.Errorf("DumpDisassembly() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func Test_IMEChangeEvents(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0058:], []byte{0xD9}) // serial handler: RETI
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x08, // LD A,0x08
+		0xE0, 0xFF, // LDH (IE),A
+		0xFB,       // EI
+		0x00,       // NOP
+		0xF3,       // DI
+		0xF3,       // DI: already off, no event
+		0xFB,       // EI
+		0x00,       // NOP
+		0xE0, 0x0F, // LDH (IF),A: request serial
+		0x18, 0xFE, // JR -2
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	type change struct {
+		value byte
+		cause gbc.IMECause
+		pc    uint16
+	}
+	var got []change
+	gb.Subscribe(gbc.EVENT_IME_CHANGE, func(e gbc.Event) {
+		got = append(got, change{e.Value, e.Cause, gb.CPU().PC})
+	})
+
+	for range 12 {
+		gb.Step()
+	}
+	want := []change{
+		{1, cpu.IME_EI, 0x0106}, // after the NOP following EI
+		{0, cpu.IME_DI, 0x0107},
+		{1, cpu.IME_EI, 0x010A},
+		{0, cpu.IME_INTERRUPT, 0x010C}, // serviced after LDH (IF),A
+		{1, cpu.IME_RETI, 0x010C},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("IME changes = %+v, want %+v", got, want)
+	}
+	if !gb.CPU().IMEEnabled() {
+		t.Error("IMEEnabled() = false after RETI")
+	}
+}
@@ -0,0 +1,78 @@
+package gbc
+
+import "github.com/duyquang6/go-retroid/joypad"
+
+type Button = joypad.Button
+
+type InputPollMode int
+
+const (
+	// button changes reach the joypad as soon as they are reported
+	INPUT_POLL_IMMEDIATE InputPollMode = iota
+	// button changes are queued and applied in order at VBlank, so every
+	// game sees them at the same point of the frame
+	INPUT_POLL_PER_FRAME
+)
+
+type inputEvent struct {
+	button  Button
+	pressed bool
+}
+
+// SetInputPollMode selects when Press and Release take effect. Switching
+// to immediate mode applies any queued change right away.
+func (gb *GameBoy) SetInputPollMode(mode InputPollMode) {
+	gb.inputMode = mode
+	if mode == INPUT_POLL_IMMEDIATE {
+		gb.applyInput()
+	}
+}
+
+func (gb *GameBoy) Press(b Button) {
+	gb.input(inputEvent{button: b, pressed: true})
+}
+
+func (gb *GameBoy) Release(b Button) {
+	gb.input(inputEvent{button: b})
+}
+
+func (gb *GameBoy) input(e inputEvent) {
+	gb.pendingInput = append(gb.pendingInput, e)
+	if gb.inputMode == INPUT_POLL_IMMEDIATE {
+		gb.applyInput()
+	}
+}
+
+// applyInput replays the queued changes one by one, so a press released
+// within the same frame still raises the joypad interrupt.
+func (gb *GameBoy) applyInput() {
+	for _, e := range gb.pendingInput {
+		if e.pressed {
+			gb.joypad.Press(e.button)
+		} else {
+			gb.joypad.Release(e.button)
+		}
+	}
+	gb.pendingInput = gb.pendingInput[:0]
+}
+
+// PhysicalInputs returns the bitmask of the buttons the user holds, in
+// Button bits. In per-frame mode it includes changes not yet seen by the
+// game.
+func (gb *GameBoy) PhysicalInputs() uint8 {
+	pressed := gb.joypad.Pressed()
+	for _, e := range gb.pendingInput {
+		if e.pressed {
+			pressed |= e.button
+		} else {
+			pressed &^= e.button
+		}
+	}
+	return uint8(pressed)
+}
+
+// JoypadRegister returns P1 as the game reads it: the select bits it wrote
+// and the active-low lines of the selected groups.
+func (gb *GameBoy) JoypadRegister() byte {
+	return gb.mem.Read(joypad.ADDR_P1)
+}
@@ -0,0 +1,60 @@
+package gbc
+
+import "github.com/duyquang6/go-retroid/cpu"
+
+// Model is the console hardware being emulated.
+type Model int
+
+const (
+	// MODEL_AUTO picks CGB for cartridges flagged CGB in their header,
+	// DMG otherwise
+	MODEL_AUTO Model = iota
+	MODEL_DMG
+	// Game Boy Pocket
+	MODEL_MGB
+	MODEL_SGB
+	MODEL_CGB
+	// Game Boy Advance running a CGB game
+	MODEL_AGB
+)
+
+// CPU registers each model's boot ROM hands over with at 0x0100. Games
+// test A to detect the hardware: 0x11 means CGB, and B bit 0 then tells
+// an AGB apart.
+var postBootRegisters = map[Model]cpu.Registers{
+	MODEL_DMG: {A: 0x01, F: 0xB0, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D},
+	MODEL_MGB: {A: 0xFF, F: 0xB0, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D},
+	MODEL_SGB: {A: 0x01, F: 0x00, B: 0x00, C: 0x14, D: 0x00, E: 0x00, H: 0xC0, L: 0x60},
+	MODEL_CGB: {A: 0x11, F: 0x80, B: 0x00, C: 0x00, D: 0xFF, E: 0x56, H: 0x00, L: 0x0D},
+	MODEL_AGB: {A: 0x11, F: 0x00, B: 0x01, C: 0x00, D: 0xFF, E: 0x56, H: 0x00, L: 0x0D},
+}
+
+// CPU registers the CGB boot ROM hands over with after switching a game
+// without the CGB header flag to DMG compatibility.
+var postBootCompatRegisters = map[Model]cpu.Registers{
+	MODEL_CGB: {A: 0x11, F: 0x80, B: 0x00, C: 0x00, D: 0x00, E: 0x08, H: 0x00, L: 0x7C},
+	MODEL_AGB: {A: 0x11, F: 0x00, B: 0x01, C: 0x00, D: 0x00, E: 0x08, H: 0x00, L: 0x7C},
+}
+
+// SetModel selects the emulated hardware and restarts the game in the
+// state that model's boot ROM leaves it in, or from the boot ROM set with
+// SetBootROM. CGB and AGB turn CGB mode on, the other models turn it off.
+// A game without the CGB header flag runs in DMG compatibility on CGB and
+// AGB, as their boot ROM sets it up.
+func (gb *GameBoy) SetModel(m Model) {
+	gb.model = m
+	gb.SetCGBMode(gb.Model() == MODEL_CGB || gb.Model() == MODEL_AGB)
+	gb.boot()
+}
+
+// Model returns the emulated hardware, MODEL_AUTO resolved against the
+// loaded cartridge.
+func (gb *GameBoy) Model() Model {
+	if gb.model != MODEL_AUTO {
+		return gb.model
+	}
+	if gb.cart != nil && gb.cart.CGB() {
+		return MODEL_CGB
+	}
+	return MODEL_DMG
+}
@@ -0,0 +1,50 @@
+package gbc
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/duyquang6/go-retroid/ppu"
+)
+
+// shades of grey from white to black, the default DMG colours
+var defaultDMGPalette = [4]color.RGBA{
+	{0xFF, 0xFF, 0xFF, 0xFF},
+	{0xAA, 0xAA, 0xAA, 0xFF},
+	{0x55, 0x55, 0x55, 0xFF},
+	{0x00, 0x00, 0x00, 0xFF},
+}
+
+// SetDMGPalette sets the colours DMG shades are displayed with, from
+// lightest to darkest, for the background/window and each sprite palette.
+func (gb *GameBoy) SetDMGPalette(bg, obj0, obj1 [4]color.RGBA) {
+	gb.dmgPalette = [3][4]color.RGBA{bg, obj0, obj1}
+}
+
+// Frame returns the last rendered frame, colourised with the DMG palette
+// or, in CGB mode, with the CGB palette colours.
+func (gb *GameBoy) Frame() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ppu.SCREEN_WIDTH, ppu.SCREEN_HEIGHT))
+	for y := range ppu.SCREEN_HEIGHT {
+		for x := range ppu.SCREEN_WIDTH {
+			if gb.cgb {
+				img.SetRGBA(x, y, rgb555(gb.ppu.PixelColor(x, y)))
+				continue
+			}
+			img.SetRGBA(x, y, gb.dmgPalette[gb.ppu.PixelPalette(x, y)][gb.ppu.Pixel(x, y)])
+		}
+	}
+	return img
+}
+
+// RenderToImage returns the last rendered frame as a 160x144 image, ready
+// for image/draw, png.Encode or a GUI toolkit. It is the same as Frame.
+func (gb *GameBoy) RenderToImage() *image.RGBA {
+	return gb.Frame()
+}
+
+// rgb555 scales a CGB colour to 8 bits per channel.
+func rgb555(c uint16) color.RGBA {
+	scale := func(v uint16) uint8 { return uint8(v&0x1F)<<3 | uint8(v&0x1F)>>2 }
+	return color.RGBA{scale(c), scale(c >> 5), scale(c >> 10), 0xFF}
+}
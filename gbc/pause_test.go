@@ -0,0 +1,85 @@
+package gbc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duyquang6/go-retroid/gbc"
+)
+
+func Test_PauseResume(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- gb.RunContext(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(func() bool { return gb.Cycles() > 0 })
+
+	gb.Pause()
+	paused := gb.Cycles()
+	time.Sleep(20 * time.Millisecond)
+	if got := gb.Cycles(); got != paused {
+		t.Fatalf("Cycles() went from %d to %d while paused", paused, got)
+	}
+
+	gb.Resume()
+	waitFor(func() bool { return gb.Cycles() > paused })
+}
+
+func Test_PauseFromCallback(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	// callbacks run on the RunContext goroutine while it holds the frame
+	called := make(chan uint64, 1)
+	gb.Subscribe(gbc.EVENT_VBLANK, func(gbc.Event) {
+		cycles := gb.Cycles()
+		gb.Pause()
+		select {
+		case called <- cycles:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- gb.RunContext(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Cycles or Pause from a VBlank subscriber deadlocked")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !gb.Paused() {
+		if time.Now().After(deadline) {
+			t.Fatal("not paused")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// from another goroutine Pause waits for the loop to stop
+	gb.Pause()
+	paused := gb.Cycles()
+	time.Sleep(20 * time.Millisecond)
+	if got := gb.Cycles(); got != paused {
+		t.Errorf("Cycles() went from %d to %d while paused", paused, got)
+	}
+}
@@ -0,0 +1,47 @@
+package gbc
+
+import "time"
+
+// PresentMode selects when frames are handed to EVENT_VBLANK subscribers.
+type PresentMode int
+
+const (
+	// deliver the frame the instant VBlank occurs, however fast the
+	// emulation runs
+	PRESENT_IMMEDIATE PresentMode = iota
+	// wait at VBlank until a frame's worth of wall-clock time passed since
+	// the previous one, pacing emulation to the real hardware
+	PRESENT_VSYNC
+)
+
+// FRAME_DURATION is the wall-clock time of a frame on hardware, ~59.7 Hz.
+const FRAME_DURATION = FRAME_CYCLES * time.Second / CLOCK_HZ
+
+// SetPresentMode selects when the frame callbacks fire, PRESENT_IMMEDIATE
+// by default.
+func (gb *GameBoy) SetPresentMode(mode PresentMode) {
+	gb.presentMode = mode
+	gb.nextPresent = time.Time{}
+}
+
+// SetSleep replaces the function PRESENT_VSYNC waits with, mostly for
+// tests along with SetClock.
+func (gb *GameBoy) SetSleep(sleep func(time.Duration)) {
+	gb.sleep = sleep
+}
+
+// present waits for the frame's presentation time in PRESENT_VSYNC mode.
+// When emulation falls behind, pacing restarts from now instead of
+// rushing through the missed frames.
+func (gb *GameBoy) present() {
+	if gb.presentMode != PRESENT_VSYNC {
+		return
+	}
+	now := gb.now()
+	if gb.nextPresent.IsZero() || now.After(gb.nextPresent) {
+		gb.nextPresent = now
+	} else {
+		gb.sleep(gb.nextPresent.Sub(now))
+	}
+	gb.nextPresent = gb.nextPresent.Add(FRAME_DURATION)
+}
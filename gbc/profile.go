@@ -0,0 +1,81 @@
+package gbc
+
+import "time"
+
+// FrameProfile is the wall-clock time one emulated frame took to run.
+type FrameProfile struct {
+	Frame uint64
+	Total time.Duration
+	// time spent stepping the PPU, the APU and everything else clocked
+	// by the scheduler (OAM DMA, serial, added components), sampled; the
+	// CPU gets the rest of Total
+	CPU, PPU, APU, Other time.Duration
+	// time spent at VBlank handing the frame over: presentation pacing,
+	// the audio sink and event subscribers, not counted as PPU time
+	Present      time.Duration
+	Instructions uint64
+}
+
+type profiler struct {
+	fn           func(FrameProfile)
+	start        time.Time
+	instructions uint64
+	present      time.Duration
+}
+
+// SetProfileCallback calls fn at every VBlank with the time the frame
+// took. Component times are sampled from a fraction of the catch-ups,
+// which slows emulation down only slightly; nil stops profiling.
+func (gb *GameBoy) SetProfileCallback(fn func(FrameProfile)) {
+	if fn == nil {
+		gb.profiler = nil
+		gb.sched.now = nil
+		return
+	}
+	gb.profiler = &profiler{fn: fn, start: gb.now()}
+	gb.sched.now = gb.now
+	gb.sched.untimed = 0
+	for _, c := range gb.sched.components {
+		c.elapsed = 0
+	}
+}
+
+func (gb *GameBoy) reportProfile() {
+	now := gb.now()
+	p := FrameProfile{
+		Frame:        gb.stats.Frames,
+		Total:        now.Sub(gb.profiler.start),
+		Present:      gb.profiler.present,
+		Instructions: gb.profiler.instructions,
+	}
+	for _, c := range gb.sched.components {
+		switch c.Component {
+		case gb.ppu:
+			p.PPU += c.elapsed
+		case gb.apu:
+			p.APU += c.elapsed
+		default:
+			p.Other += c.elapsed
+		}
+		c.elapsed = 0
+	}
+	p.CPU = max(p.Total-p.PPU-p.APU-p.Other-p.Present, 0)
+	gb.profiler.start, gb.profiler.instructions, gb.profiler.present = now, 0, 0
+	gb.inCallback.Add(1)
+	defer gb.inCallback.Add(-1)
+	gb.profiler.fn(p)
+}
+
+// handOver runs fn, the VBlank work passing the frame to the front-end,
+// timing it as Present rather than as part of the PPU step it runs in.
+func (gb *GameBoy) handOver(fn func()) {
+	if gb.profiler == nil {
+		fn()
+		return
+	}
+	start := gb.now()
+	fn()
+	d := gb.now().Sub(start)
+	gb.profiler.present += d
+	gb.sched.excluded += d
+}
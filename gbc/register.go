@@ -0,0 +1,112 @@
+package gbc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownRegister = errors.New("unknown register")
+
+// ReadRegister returns a CPU register by case-insensitive name: A, F, B,
+// C, D, E, H, L, the pairs AF, BC, DE and HL, PC, SP, or IME as 0 or 1.
+func (gb *GameBoy) ReadRegister(name string) (uint16, error) {
+	r := gb.cpu.Registers()
+	pair := func(hi, lo byte) uint16 { return uint16(hi)<<8 | uint16(lo) }
+	switch strings.ToUpper(name) {
+	case "A":
+		return uint16(r.A), nil
+	case "F":
+		return uint16(r.F), nil
+	case "B":
+		return uint16(r.B), nil
+	case "C":
+		return uint16(r.C), nil
+	case "D":
+		return uint16(r.D), nil
+	case "E":
+		return uint16(r.E), nil
+	case "H":
+		return uint16(r.H), nil
+	case "L":
+		return uint16(r.L), nil
+	case "AF":
+		return pair(r.A, r.F), nil
+	case "BC":
+		return pair(r.B, r.C), nil
+	case "DE":
+		return pair(r.D, r.E), nil
+	case "HL":
+		return pair(r.H, r.L), nil
+	case "PC":
+		return r.PC, nil
+	case "SP":
+		return r.SP, nil
+	case "IME":
+		if r.IME {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownRegister, name)
+}
+
+// WriteRegister sets a CPU register by name, see ReadRegister. Values too
+// wide for the register are an error; the low nibble of F always reads 0.
+func (gb *GameBoy) WriteRegister(name string, v uint16) error {
+	r := gb.cpu.Registers()
+	name = strings.ToUpper(name)
+
+	var reg8 *byte
+	var hi, lo *byte
+	switch name {
+	case "A":
+		reg8 = &r.A
+	case "F":
+		reg8 = &r.F
+	case "B":
+		reg8 = &r.B
+	case "C":
+		reg8 = &r.C
+	case "D":
+		reg8 = &r.D
+	case "E":
+		reg8 = &r.E
+	case "H":
+		reg8 = &r.H
+	case "L":
+		reg8 = &r.L
+	case "AF":
+		hi, lo = &r.A, &r.F
+	case "BC":
+		hi, lo = &r.B, &r.C
+	case "DE":
+		hi, lo = &r.D, &r.E
+	case "HL":
+		hi, lo = &r.H, &r.L
+	case "PC":
+		r.PC = v
+	case "SP":
+		r.SP = v
+	case "IME":
+		if v > 1 {
+			return fmt.Errorf("IME value %d, want 0 or 1", v)
+		}
+		r.IME = v == 1
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownRegister, name)
+	}
+
+	switch {
+	case reg8 != nil:
+		if v > 0xFF {
+			return fmt.Errorf("value 0x%X does not fit 8-bit register %s", v, name)
+		}
+		*reg8 = byte(v)
+	case hi != nil:
+		*hi, *lo = byte(v>>8), byte(v)
+	}
+	r.F &= 0xF0
+	gb.cpu.SetRegisters(r)
+	return nil
+}
@@ -0,0 +1,27 @@
+package gbc
+
+// WarmReset restarts the game as the reset button of a flash cart would:
+// CPU, I/O registers and the cartridge bank controller go back to their
+// post-boot state while WRAM, VRAM and HRAM are preserved.
+func (gb *GameBoy) WarmReset() {
+	if gb.cart != nil {
+		gb.cart.Reset()
+	}
+	gb.SetCGBMode(gb.cgbHardware)
+	gb.SkipBoot()
+}
+
+// ColdReset power cycles the console: all memory is cleared and the
+// cartridge starts over. Battery-backed cartridge RAM persists, other
+// cartridge RAM is lost. A boot ROM set with SetBootROM runs again.
+func (gb *GameBoy) ColdReset() {
+	gb.mem.Clear()
+	if gb.cart != nil {
+		gb.cart.Reset()
+		if !gb.cart.HasBattery() {
+			gb.cart.LoadRAM(make([]byte, len(gb.cart.RAM())))
+		}
+	}
+	gb.SetCGBMode(gb.cgbHardware)
+	gb.boot()
+}
@@ -0,0 +1,236 @@
+package gbc
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/ppu"
+)
+
+const (
+	CLOCK_HZ     = 4194304
+	FRAME_CYCLES = 70224
+)
+
+// while profiling only one catch-up in profileInterval is timed, and
+// counted for all of them, to keep clock reads off the hot path
+const profileInterval = 16
+
+// Component is a subsystem clocked by the CPU: it is stepped by the
+// T-cycles each instruction took.
+type Component interface {
+	Step(cycles int)
+}
+
+// ComponentFunc adapts a plain function to Component.
+type ComponentFunc func(cycles int)
+
+func (f ComponentFunc) Step(cycles int) {
+	f(cycles)
+}
+
+// component is a Component and the cycle timestamp it has been advanced to.
+type component struct {
+	Component
+	synced uint64
+	// wall-clock time spent stepping it, while profiling
+	elapsed time.Duration
+}
+
+// scheduler keeps every component on a single cycle timestamp. The CPU
+// moves the clock forward and the components catch up to it in a fixed
+// order, so nothing drifts however long the emulation runs.
+type scheduler struct {
+	// atomic so that Cycles can read it while RunContext steps
+	clock      atomic.Uint64
+	components []*component
+	// set while profiling to time each component
+	now func() time.Time
+	// catch-ups since the last timed one, and the running total of time
+	// spent in work not to be charged to the component doing it
+	untimed  int
+	excluded time.Duration
+}
+
+func (s *scheduler) add(c Component) {
+	s.components = append(s.components, &component{Component: c, synced: s.clock.Load()})
+}
+
+// reset moves the clock to a restored timestamp with every component
+// already synced to it.
+func (s *scheduler) reset(clock uint64) {
+	s.clock.Store(clock)
+	for _, c := range s.components {
+		c.synced = clock
+	}
+}
+
+func (s *scheduler) advance(cycles int) {
+	s.clock.Add(uint64(cycles))
+}
+
+// catchUp steps every component up to the current timestamp.
+func (s *scheduler) catchUp() {
+	clock := s.clock.Load()
+	if s.now != nil {
+		if s.untimed++; s.untimed == profileInterval {
+			s.untimed = 0
+			s.timedCatchUp(clock)
+			return
+		}
+	}
+	for _, c := range s.components {
+		if c.synced >= clock {
+			continue
+		}
+		c.Step(int(clock - c.synced))
+		c.synced = clock
+	}
+}
+
+// timedCatchUp is catchUp charging each component profileInterval times
+// the time it took, less the excluded work it ran.
+func (s *scheduler) timedCatchUp(clock uint64) {
+	start := s.now()
+	for _, c := range s.components {
+		if c.synced >= clock {
+			continue
+		}
+		excluded := s.excluded
+		c.Step(int(clock - c.synced))
+		c.synced = clock
+		end := s.now()
+		c.elapsed += max(end.Sub(start)-(s.excluded-excluded), 0) * profileInterval
+		start = end
+	}
+}
+
+// AddComponent registers a component stepped after every instruction,
+// after the built-in ones and in registration order.
+func (gb *GameBoy) AddComponent(c Component) {
+	gb.sched.add(c)
+}
+
+// Cycles returns the number of T-cycles emulated since power on. It is
+// safe to call while RunContext runs in another goroutine, and from its
+// callbacks.
+func (gb *GameBoy) Cycles() uint64 {
+	return gb.sched.clock.Load()
+}
+
+// RunCycles steps until at least n more T-cycles have been emulated, or
+// the emulation is paused.
+func (gb *GameBoy) RunCycles(n uint64) {
+	target := gb.sched.clock.Load() + n
+	for gb.sched.clock.Load() < target && !gb.paused.Load() {
+		gb.Step()
+	}
+}
+
+// Tick drives the machine by a cycle budget, e.g. from the scheduler of a
+// multi-system front-end. It steps until at least cycles T-cycles have
+// elapsed, finishing the instruction in flight, and returns the cycles
+// actually consumed: the budget plus the overshoot of the last
+// instruction, or less when the emulation is paused.
+func (gb *GameBoy) Tick(cycles int) int {
+	start := gb.sched.clock.Load()
+	gb.RunCycles(uint64(max(cycles, 0)))
+	return int(gb.sched.clock.Load() - start)
+}
+
+// RunFrames runs n frames' worth of cycles, FRAME_CYCLES each, so that
+// with the LCD on exactly n VBlanks occur. It stops early when paused.
+func (gb *GameBoy) RunFrames(n int) {
+	gb.RunCycles(uint64(max(n, 0)) * FRAME_CYCLES)
+}
+
+// StepScanline steps until the PPU moves to the next scanline and returns
+// the T-cycles spent. With the LCD off it stops after a scanline's worth of
+// cycles.
+func (gb *GameBoy) StepScanline() int {
+	line, start := gb.ppu.Line(), gb.sched.clock.Load()
+	cycles := 0
+	for gb.ppu.Line() == line && gb.sched.clock.Load()-start < ppu.SCANLINE_DOTS && !gb.paused.Load() {
+		cycles += gb.Step()
+	}
+	return cycles
+}
+
+// syncBus is the CPU bus in cycle-accurate mode: before each memory access
+// the other components catch up to the M-cycle the access happens in, so
+// they observe it mid-instruction instead of before the instruction ran.
+type syncBus struct {
+	gb *GameBoy
+	// accesses so far in the current instruction, and the bus cycles the
+	// scheduler was advanced by for them
+	accesses int
+	advanced int
+}
+
+func (b *syncBus) sync() {
+	if b.accesses > 0 {
+		n := b.gb.busCycles(4)
+		b.gb.sched.advance(n)
+		b.gb.sched.catchUp()
+		b.advanced += n
+	}
+	b.accesses++
+}
+
+// Idle is an M-cycle without memory access, see cpu.Idler.
+func (b *syncBus) Idle() {
+	b.sync()
+}
+
+func (b *syncBus) Read(address uint16) byte {
+	b.sync()
+	return b.gb.mem.Read(address)
+}
+
+func (b *syncBus) Write(address uint16, value byte) {
+	b.sync()
+	b.gb.mem.Write(address, value)
+}
+
+// SetCycleAccurate selects how other components follow the CPU. In
+// cycle-accurate mode, the default, they are stepped before every CPU
+// memory access, so reads and writes of PPU registers happen at the right
+// dot. Otherwise they are stepped once per instruction, which is faster
+// but makes every access of an instruction happen at its start.
+func (gb *GameBoy) SetCycleAccurate(enabled bool) {
+	gb.bus = nil
+	if enabled {
+		gb.bus = &syncBus{gb: gb}
+	}
+	gb.setCPUBus()
+}
+
+// setCPUBus routes the CPU through the sync bus in cycle-accurate mode,
+// and through watchBus while watchpoints are set.
+func (gb *GameBoy) setCPUBus() {
+	var bus cpu.Bus = gb.mem
+	if gb.bus != nil {
+		bus = gb.bus
+	}
+	if gb.watchpoints != nil {
+		bus = watchBus{Bus: bus, gb: gb}
+	}
+	gb.cpu.SetBus(bus)
+}
+
+// runCPU runs fn, a CPU operation returning the cycles it took, and
+// catches the other components up with it.
+func (gb *GameBoy) runCPU(fn func() int) int {
+	advanced := 0
+	if gb.bus != nil {
+		gb.bus.accesses, gb.bus.advanced = 0, 0
+	}
+	cycles := fn()
+	if gb.bus != nil {
+		advanced = gb.bus.advanced
+	}
+	gb.sched.advance(gb.busCycles(cycles) - advanced)
+	gb.sched.catchUp()
+	return cycles
+}
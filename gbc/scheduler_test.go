@@ -0,0 +1,266 @@
+package gbc_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/duyquang6/go-retroid/gbc"
+)
+
+func Test_Tick(t *testing.T) {
+	// NOP; LD HL,0xC000; LD (HL),A; PUSH BC; POP BC; CALL 0x010B;
+	// POP BC; JP 0x0100: 4 to 24 cycles each
+	gb := newTestGB(t,
+		0x00, 0x21, 0x00, 0xC0, 0x77, 0xC5, 0xC1, 0xCD, 0x0B, 0x01,
+		0x00, 0xC1, 0xC3, 0x00, 0x01,
+	)
+
+	for _, budget := range []int{0, 1, 4, 5, 17, 100, 1000, gbc.FRAME_CYCLES} {
+		before := gb.Cycles()
+		got := gb.Tick(budget)
+		if uint64(got) != gb.Cycles()-before {
+			t.Errorf("Tick(%d) = %d, but %d cycles elapsed", budget, got, gb.Cycles()-before)
+		}
+		// the longest instruction here is CALL's 24 cycles
+		if got < budget || got >= budget+24 {
+			t.Errorf("Tick(%d) = %d, want %d to %d", budget, got, budget, budget+23)
+		}
+	}
+}
+
+func Test_RunCyclesNoDrift(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+
+	const seconds = 10
+	gb.RunCycles(seconds * gbc.CLOCK_HZ)
+
+	cycles := gb.Cycles()
+	if cycles < seconds*gbc.CLOCK_HZ || cycles >= seconds*gbc.CLOCK_HZ+12 {
+		t.Fatalf("Cycles = %d, want %d plus less than one instruction", cycles, seconds*gbc.CLOCK_HZ)
+	}
+	if gb.Stats().Cycles != cycles {
+		t.Errorf("Stats().Cycles = %d, want %d", gb.Stats().Cycles, cycles)
+	}
+
+	// after SkipBoot the first VBlank is 4+144*456 cycles away, then one
+	// every frame
+	first := uint64(4 + 144*456)
+	want := 1 + (cycles-first)/gbc.FRAME_CYCLES
+	if got := gb.Stats().Frames; got != want {
+		t.Errorf("Frames = %d, want %d", got, want)
+	}
+}
+
+func Test_StepScanline(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+
+	// the boot hands over at the end of line 153
+	gb.StepScanline()
+	line := 0
+	for i := range 2 * 154 {
+		if got := gb.Peek(0xFF44); int(got) != line && !(line == 153 && got == 0) {
+			t.Fatalf("step %d: LY = %d, want %d", i, got, line)
+		}
+		cycles := gb.StepScanline()
+		if cycles < 456-12 || cycles > 456+12 {
+			t.Errorf("step %d: StepScanline() = %d cycles, want about 456", i, cycles)
+		}
+		line = (line + 1) % 154
+	}
+}
+
+type countingComponent struct{ steps []int }
+
+func (c *countingComponent) Step(cycles int) { c.steps = append(c.steps, cycles) }
+
+func Test_AddComponent(t *testing.T) {
+	gb := newTestGB(t,
+		0x00,       // NOP
+		0x3E, 0x01, // LD A,0x01
+		0xC3, 0x00, 0x01, // JP 0x0100
+	)
+	// per instruction stepping, cycle-accurate mode steps per M-cycle
+	gb.SetCycleAccurate(false)
+	c := &countingComponent{}
+	gb.AddComponent(c)
+
+	var want []int
+	for range 3 {
+		want = append(want, gb.Step())
+	}
+	if !slices.Equal(c.steps, want) {
+		t.Errorf("component stepped with %v, want %v", c.steps, want)
+	}
+	if want := []int{4, 8, 16}; !slices.Equal(c.steps, want) {
+		t.Errorf("component stepped with %v, want %v", c.steps, want)
+	}
+}
+
+func Test_InterruptsOnInstructionBoundary(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0040:], []byte{0xD9}) // VBlank: RETI
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x01, // LD A,0x01
+		0xE0, 0xFF, // LDH (IE),A
+		0xFB,             // EI
+		0xEA, 0x0F, 0xFF, // LD (IF),A ; requests VBlank mid-instruction
+		0xC3, 0x08, 0x01, // JP 0x0108
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	for range 4 {
+		gb.Step()
+	}
+	// the LD completes, then the interrupt returns to the following JP
+	if pc := gb.CPU().PC; pc != 0x0040 {
+		t.Fatalf("PC = 0x%04X after LD (IF),A, want the VBlank vector", pc)
+	}
+	sp := gb.CPU().SP
+	if ret := uint16(gb.Peek(sp)) | uint16(gb.Peek(sp+1))<<8; ret != 0x0108 {
+		t.Fatalf("return address = 0x%04X, want 0x0108", ret)
+	}
+
+	// the PPU raises VBlank while the 16-cycle JP runs: it's always
+	// serviced with the JP complete
+	serviced := 0
+	for gb.Stats().Frames < 5 {
+		gb.Step()
+		if gb.CPU().PC != 0x0040 {
+			continue
+		}
+		serviced++
+		sp := gb.CPU().SP
+		if ret := uint16(gb.Peek(sp)) | uint16(gb.Peek(sp+1))<<8; ret != 0x0108 {
+			t.Fatalf("VBlank serviced mid-instruction: return address 0x%04X", ret)
+		}
+	}
+	if serviced < 4 {
+		t.Errorf("VBlank serviced %d times, want at least 4", serviced)
+	}
+}
+
+func Test_CycleAccurateSTATRead(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	program := []byte{
+		0xAF,       // XOR A
+		0xE0, 0x40, // LDH (LCDC),A: LCD off
+		0x3E, 0x91, // LD A,0x91
+		0xE0, 0x40, // LDH (LCDC),A: LCD on, written 8 cycles in
+	}
+	// with the LCD on since 4 dots, line 0 is HBlank and line 1 enters
+	// mode 3 at dot 456+80; 130 NOPs later the STAT read below, 12 cycles
+	// into its instruction, lands exactly there
+	for range 130 {
+		program = append(program, 0x00)
+	}
+	program = append(program,
+		0xFA, 0x41, 0xFF, // LD A,(STAT)
+		0xEA, 0x00, 0xC0, // LD (0xC000),A
+		0x18, 0xFE, // JR -2
+	)
+	copy(rom[0x0100:], program)
+
+	for _, tc := range []struct {
+		accurate bool
+		mode     byte
+	}{{true, 3}, {false, 2}} {
+		gb := gbc.NewGameBoy()
+		if err := gb.LoadROM(rom); err != nil {
+			t.Fatal(err)
+		}
+		gb.SetCycleAccurate(tc.accurate)
+		for gb.CPU().PC < 0x0100+uint16(len(program))-2 {
+			gb.Step()
+		}
+		if got := gb.Peek(0xC000) & 0x03; got != tc.mode {
+			t.Errorf("cycle-accurate %v: STAT mode = %d, want %d", tc.accurate, got, tc.mode)
+		}
+	}
+}
+
+func Test_PresentVSync(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], []byte{0x18, 0xFE}) // JR -2
+	for _, tc := range []struct {
+		mode     gbc.PresentMode
+		interval time.Duration
+	}{
+		{gbc.PRESENT_IMMEDIATE, 0},
+		{gbc.PRESENT_VSYNC, gbc.FRAME_DURATION},
+	} {
+		gb := gbc.NewGameBoy()
+		if err := gb.LoadROM(rom); err != nil {
+			t.Fatal(err)
+		}
+		// emulation takes no time at all, only sleeping advances the clock
+		now := time.Unix(0, 0)
+		gb.SetClock(func() time.Time { return now })
+		gb.SetSleep(func(d time.Duration) { now = now.Add(d) })
+		gb.SetPresentMode(tc.mode)
+
+		var presented []time.Time
+		gb.Subscribe(gbc.EVENT_VBLANK, func(gbc.Event) { presented = append(presented, now) })
+		gb.RunCycles(4 * gbc.FRAME_CYCLES)
+
+		if len(presented) < 3 {
+			t.Fatalf("mode %d: %d frames presented, want at least 3", tc.mode, len(presented))
+		}
+		for i := 1; i < len(presented); i++ {
+			if got := presented[i].Sub(presented[i-1]); got != tc.interval {
+				t.Errorf("mode %d: frame %d presented %v after the previous, want %v", tc.mode, i, got, tc.interval)
+			}
+		}
+	}
+}
+
+func Test_RunFrames(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	vblanks := 0
+	gb.Subscribe(gbc.EVENT_VBLANK, func(gbc.Event) { vblanks++ })
+
+	start := gb.Cycles()
+	gb.RunFrames(60)
+	if vblanks != 60 {
+		t.Errorf("RunFrames(60) fired %d VBlanks, want 60", vblanks)
+	}
+	if got, want := gb.Cycles()-start, uint64(60*gbc.FRAME_CYCLES); got < want || got >= want+24 {
+		t.Errorf("RunFrames(60) ran %d cycles, want %d", got, want)
+	}
+}
+
+func Test_InterruptLatency(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0058:], []byte{0x18, 0xFE}) // serial handler: JR -2
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x08, // LD A,0x08
+		0xE0, 0xFF, // LDH (IE),A
+		0xFB,       // EI
+		0x00,       // NOP
+		0x00,       // NOP
+		0x18, 0xFE, // JR -2
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	for range 4 {
+		gb.Step()
+	}
+
+	// requested while the last NOP runs: it completes, then the dispatch
+	// takes 20 cycles before the handler's first fetch
+	gb.Poke(0xFF0F, 0x08)
+	start := gb.Cycles()
+	if got := gb.Step(); got != 4+20 {
+		t.Errorf("Step() = %d cycles, want 4 for NOP + 20 for the dispatch", got)
+	}
+	if pc := gb.CPU().PC; pc != 0x0058 {
+		t.Fatalf("PC = %04X, want the serial vector", pc)
+	}
+	if got := gb.Cycles() - start; got != 24 {
+		t.Errorf("handler reached %d cycles after the request, want 24", got)
+	}
+}
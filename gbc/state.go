@@ -0,0 +1,168 @@
+package gbc
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// STATE_VERSION is bumped whenever the save state layout changes.
+const STATE_VERSION = 2
+
+var ErrStateMismatch = errors.New("save state belongs to another game")
+
+// saveState is the stream written by SaveState: the machine level fields
+// and each component's own encoding.
+type saveState struct {
+	Version     int
+	ROMChecksum uint32
+
+	CPU, Memory, PPU, APU []byte
+	Serial, Joypad, Timer []byte
+	Cartridge             []byte
+
+	Clock, Frames                       uint64
+	CGBHardware, CGB                    bool
+	KEY0                                byte
+	KEY0Locked, DoubleSpeed, SpeedArmed bool
+	BootROMMapped                       bool
+}
+
+// SaveState writes a snapshot of the whole machine to w. Host settings
+// (breakpoints, callbacks, palettes, audio) are not part of it.
+func (gb *GameBoy) SaveState(w io.Writer) error {
+	s := saveState{
+		Version:     STATE_VERSION,
+		ROMChecksum: crc32.ChecksumIEEE(gb.rom),
+		Clock:       gb.sched.clock.Load(), Frames: gb.stats.Frames,
+		CGBHardware: gb.cgbHardware, CGB: gb.cgb,
+		KEY0: gb.key0, KEY0Locked: gb.key0Locked,
+		DoubleSpeed: gb.doubleSpeed, SpeedArmed: gb.speedArmed,
+		BootROMMapped: gb.mem.BootROMMapped(),
+	}
+	var err error
+	if s.CPU, err = gb.cpu.MarshalBinary(); err != nil {
+		return err
+	}
+	if s.Memory, err = gb.mem.MarshalBinary(); err != nil {
+		return err
+	}
+	if s.PPU, err = gb.ppu.MarshalBinary(); err != nil {
+		return err
+	}
+	if s.APU, err = gb.apu.MarshalBinary(); err != nil {
+		return err
+	}
+	if s.Serial, err = gb.serial.MarshalBinary(); err != nil {
+		return err
+	}
+	if s.Joypad, err = gb.joypad.MarshalBinary(); err != nil {
+		return err
+	}
+	if s.Timer, err = gb.timer.MarshalBinary(); err != nil {
+		return err
+	}
+	if gb.cart != nil {
+		if s.Cartridge, err = gb.cart.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+	return gob.NewEncoder(w).Encode(s)
+}
+
+// LoadState restores a snapshot written by SaveState for the loaded ROM.
+// The whole state is decoded on a scratch machine first, so a state that
+// fails to load leaves the running game untouched.
+func (gb *GameBoy) LoadState(r io.Reader) error {
+	var s saveState
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("decode save state: %w", err)
+	}
+	if s.Version != STATE_VERSION {
+		return fmt.Errorf("save state version %d, want %d", s.Version, STATE_VERSION)
+	}
+	if s.ROMChecksum != crc32.ChecksumIEEE(gb.rom) {
+		return ErrStateMismatch
+	}
+
+	scratch := NewGameBoy()
+	if gb.cart != nil {
+		if err := scratch.LoadROM(gb.rom); err != nil {
+			return err
+		}
+	}
+	if err := scratch.restore(&s); err != nil {
+		return fmt.Errorf("decode save state: %w", err)
+	}
+	return gb.restore(&s)
+}
+
+// restore applies s to the machine, see LoadState.
+func (gb *GameBoy) restore(s *saveState) error {
+	gb.SetCGBMode(s.CGBHardware)
+	if s.CGB != s.CGBHardware {
+		gb.setCGBFeatures(s.CGB)
+	}
+	gb.key0, gb.key0Locked = s.KEY0, s.KEY0Locked
+	gb.doubleSpeed, gb.speedArmed = s.DoubleSpeed, s.SpeedArmed
+	gb.sched.reset(s.Clock)
+	gb.stats.Frames = s.Frames
+	if s.BootROMMapped && gb.bootROM != nil {
+		gb.mem.MapBootROM(gb.bootROM)
+	} else {
+		gb.mem.MapBootROM(nil)
+	}
+	gb.pendingInput = gb.pendingInput[:0]
+
+	if err := gb.cpu.UnmarshalBinary(s.CPU); err != nil {
+		return err
+	}
+	if err := gb.mem.UnmarshalBinary(s.Memory); err != nil {
+		return err
+	}
+	if err := gb.ppu.UnmarshalBinary(s.PPU); err != nil {
+		return err
+	}
+	if err := gb.apu.UnmarshalBinary(s.APU); err != nil {
+		return err
+	}
+	if err := gb.serial.UnmarshalBinary(s.Serial); err != nil {
+		return err
+	}
+	if err := gb.joypad.UnmarshalBinary(s.Joypad); err != nil {
+		return err
+	}
+	if err := gb.timer.UnmarshalBinary(s.Timer); err != nil {
+		return err
+	}
+	if gb.cart != nil {
+		return gb.cart.UnmarshalBinary(s.Cartridge)
+	}
+	return nil
+}
+
+// SaveStateFile writes a save state to path.
+func (gb *GameBoy) SaveStateFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gb.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadStateFile restores a save state written by SaveStateFile.
+func (gb *GameBoy) LoadStateFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gb.LoadState(f)
+}
@@ -0,0 +1,215 @@
+package gbc_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/gbc"
+)
+
+func Test_SaveStateRoundTrip(t *testing.T) {
+	gb := newTestGB(t,
+		0x21, 0x00, 0xC0, // LD HL,0xC000
+		0x04,             // INC B
+		0x78,             // LD A,B
+		0x22,             // LD (HL+),A
+		0xC3, 0x03, 0x01, // JP 0x0103
+	)
+	gb.RunCycles(10000)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gb.SaveState(zw); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	saved := gb.Cycles()
+
+	gb.RunCycles(10000)
+	wantRegs, wantCycles := gb.CPU().Registers(), gb.Cycles()
+	wantWRAM := make([]byte, 0x1000)
+	for i := range wantWRAM {
+		wantWRAM[i] = gb.Peek(0xC000 + uint16(i))
+	}
+
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gb.LoadState(zr); err != nil {
+		t.Fatal(err)
+	}
+	if gb.Cycles() != saved {
+		t.Fatalf("Cycles() = %d after LoadState, want %d", gb.Cycles(), saved)
+	}
+	gb.RunCycles(10000)
+
+	if got := gb.CPU().Registers(); got != wantRegs {
+		t.Errorf("registers = %+v, want %+v", got, wantRegs)
+	}
+	if gb.Cycles() != wantCycles {
+		t.Errorf("Cycles() = %d, want %d", gb.Cycles(), wantCycles)
+	}
+	for i, want := range wantWRAM {
+		if got := gb.Peek(0xC000 + uint16(i)); got != want {
+			t.Fatalf("WRAM[0x%04X] = 0x%02X, want 0x%02X", 0xC000+i, got, want)
+		}
+	}
+}
+
+func Test_LoadOlderState(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	gb.RunFrames(2)
+	var buf bytes.Buffer
+	if err := gb.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := gb.Stats().Frames
+
+	gb.RunFrames(10)
+	if err := gb.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := gb.Stats().Frames; got != saved {
+		t.Errorf("Frames = %d after LoadState, want the saved %d", got, saved)
+	}
+
+	// every component carries on from the restored timestamp
+	lines := map[byte]bool{}
+	for gb.Stats().Frames < saved+2 {
+		gb.Step()
+		lines[gb.Peek(0xFF44)] = true
+		if gb.Cycles() > 1000000 {
+			t.Fatalf("Frames = %d after 1M cycles, want %d", gb.Stats().Frames, saved+2)
+		}
+	}
+	// line 153 reads LY 0 after its first dots
+	if len(lines) < 153 {
+		t.Errorf("LY took %d values over 2 frames, want 0-152 at least", len(lines))
+	}
+}
+
+func Test_LoadStateOtherGame(t *testing.T) {
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(make([]byte, 0x8000)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gb.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	other := gbc.NewGameBoy()
+	if err := other.LoadROM(cgbTestROM()); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.LoadState(&buf); !errors.Is(err, gbc.ErrStateMismatch) {
+		t.Errorf("LoadState() = %v, want ErrStateMismatch", err)
+	}
+}
+
+func Test_LoadStateCorruptLeavesMachine(t *testing.T) {
+	gb := newTestGB(t, 0x18, 0xFE) // JR -2
+	gb.RunFrames(1)
+	var buf bytes.Buffer
+	if err := gb.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// corrupt the memory blob, decoded after the CPU and machine fields
+	var s struct {
+		Version     int
+		ROMChecksum uint32
+
+		CPU, Memory, PPU, APU []byte
+		Serial, Joypad, Timer []byte
+		Cartridge             []byte
+
+		Clock, Frames uint64
+	}
+	if err := gob.NewDecoder(&buf).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	s.Memory = []byte("corrupt")
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		t.Fatal(err)
+	}
+
+	gb.RunFrames(2)
+	wantRegs, wantCycles, wantFrames := gb.CPU().Registers(), gb.Cycles(), gb.Stats().Frames
+	if err := gb.LoadState(&buf); err == nil {
+		t.Fatal("LoadState() = nil for a corrupt memory blob, want an error")
+	}
+	if got := gb.CPU().Registers(); got != wantRegs {
+		t.Errorf("registers = %+v after a failed load, want %+v", got, wantRegs)
+	}
+	if gb.Cycles() != wantCycles {
+		t.Errorf("Cycles() = %d after a failed load, want %d", gb.Cycles(), wantCycles)
+	}
+	if got := gb.Stats().Frames; got != wantFrames {
+		t.Errorf("Frames = %d after a failed load, want %d", got, wantFrames)
+	}
+}
+
+func Test_StepBack(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x05, // LD A,0x05
+		0x3C,             // INC A
+		0x47,             // LD B,A
+		0xEA, 0x00, 0xC0, // LD (0xC000),A
+		0x18, 0xFE, // JR -2
+	})
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	gb.SetStepHistory(3)
+
+	var regs []cpu.Registers
+	for range 4 {
+		regs = append(regs, gb.CPU().Registers())
+		gb.Step()
+	}
+	if gb.Peek(0xC000) != 0x06 {
+		t.Fatalf("(0xC000) = %02X, want 06", gb.Peek(0xC000))
+	}
+
+	// only the last 3 steps are kept
+	for i := 3; i >= 1; i-- {
+		if err := gb.StepBack(); err != nil {
+			t.Fatalf("StepBack() = %v", err)
+		}
+		if got := gb.CPU().Registers(); got != regs[i] {
+			t.Errorf("after stepping back to step %d: registers = %+v, want %+v", i, got, regs[i])
+		}
+	}
+	if gb.Peek(0xC000) != 0x00 {
+		t.Errorf("(0xC000) = %02X after undoing the store, want 00", gb.Peek(0xC000))
+	}
+	if err := gb.StepBack(); !errors.Is(err, gbc.ErrNoStepHistory) {
+		t.Errorf("StepBack() past the history = %v, want ErrNoStepHistory", err)
+	}
+
+	// stepping forward again runs like a machine that never stepped back
+	gb.SetStepHistory(0)
+	ref := gbc.NewGameBoy()
+	if err := ref.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	ref.Step()
+	for i := range 20000 {
+		gb.Step()
+		ref.Step()
+		if gb.Peek(0xFF44) != ref.Peek(0xFF44) || gb.Peek(0xFF41) != ref.Peek(0xFF41) {
+			t.Fatalf("step %d: LY %d STAT %02X, want LY %d STAT %02X as without StepBack",
+				i, gb.Peek(0xFF44), gb.Peek(0xFF41), ref.Peek(0xFF44), ref.Peek(0xFF41))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package gbc
+
+import "time"
+
+type EmulationStats struct {
+	Frames uint64
+	// emulated T-cycles
+	Cycles uint64
+	// wall-clock time since emulation started
+	Elapsed time.Duration
+	// frames per wall-clock second, as of the last frame
+	FPS float64
+}
+
+// Stats returns the emulation statistics, updated at every frame.
+func (gb *GameBoy) Stats() EmulationStats {
+	return gb.stats
+}
+
+// SetClock replaces the wall clock used for timing, the cartridge RTC
+// included, mostly for tests.
+func (gb *GameBoy) SetClock(now func() time.Time) {
+	gb.now = now
+	gb.statsStart = time.Time{}
+	if gb.profiler != nil {
+		gb.sched.now = now
+	}
+	if gb.cart != nil {
+		gb.cart.SetClock(now)
+	}
+}
+
+func (gb *GameBoy) countCycles(cycles int) {
+	if gb.statsStart.IsZero() {
+		gb.statsStart = gb.now()
+	}
+	gb.stats.Cycles += uint64(cycles)
+}
+
+func (gb *GameBoy) countFrame() {
+	gb.stats.Frames++
+	gb.stats.Elapsed = gb.now().Sub(gb.statsStart)
+	if gb.stats.Elapsed > 0 {
+		gb.stats.FPS = float64(gb.stats.Frames) / gb.stats.Elapsed.Seconds()
+	}
+}
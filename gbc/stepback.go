@@ -0,0 +1,43 @@
+package gbc
+
+import (
+	"bytes"
+	"errors"
+)
+
+var ErrNoStepHistory = errors.New("no step history to go back to")
+
+// SetStepHistory keeps a save state from before each of the last n
+// instructions so that StepBack can undo them. Snapshots are taken on
+// every Step, which slows emulation down a lot: enable it while debugging
+// only. 0, the default, disables it and drops the history.
+func (gb *GameBoy) SetStepHistory(n int) {
+	gb.historyDepth = max(n, 0)
+	if len(gb.history) > gb.historyDepth {
+		gb.history = gb.history[len(gb.history)-gb.historyDepth:]
+	}
+}
+
+// StepBack restores the machine as it was before the last instruction
+// run by Step. It returns ErrNoStepHistory once the history kept by
+// SetStepHistory is exhausted.
+func (gb *GameBoy) StepBack() error {
+	if len(gb.history) == 0 {
+		return ErrNoStepHistory
+	}
+	last := gb.history[len(gb.history)-1]
+	gb.history = gb.history[:len(gb.history)-1]
+	return gb.LoadState(bytes.NewReader(last))
+}
+
+func (gb *GameBoy) recordStep() {
+	var buf bytes.Buffer
+	if err := gb.SaveState(&buf); err != nil {
+		gb.logger.Warn("step history snapshot failed", "err", err)
+		return
+	}
+	if len(gb.history) == gb.historyDepth {
+		gb.history = append(gb.history[:0], gb.history[1:]...)
+	}
+	gb.history = append(gb.history, buf.Bytes())
+}
@@ -0,0 +1,55 @@
+package gbc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/duyquang6/go-retroid/ppu"
+)
+
+// colour of the viewport outline drawn by ExportTilemapPNG
+var viewportColor = color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+
+// ExportTilemapPNG encodes background map mapSelect (0 at 0x9800, 1 at
+// 0x9C00) as a 256x256 PNG, drawn with the current tile data and
+// background palette. With viewport set, the 160x144 area shown at the
+// current SCX/SCY is outlined, wrapping around the map edges like the
+// screen does.
+func (gb *GameBoy) ExportTilemapPNG(w io.Writer, mapSelect int, viewport bool) error {
+	if mapSelect != 0 && mapSelect != 1 {
+		return fmt.Errorf("tilemap %d, want 0 or 1", mapSelect)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, ppu.TILEMAP_SIZE, ppu.TILEMAP_SIZE))
+	bgp := gb.ppu.BGP()
+	for y := range ppu.TILEMAP_SIZE {
+		for x := range ppu.TILEMAP_SIZE {
+			c, attr := gb.ppu.TilemapPixel(mapSelect, x, y)
+			if gb.cgb {
+				img.SetRGBA(x, y, rgb555(gb.ppu.BGColor(int(attr&0x07), int(c))))
+				continue
+			}
+			img.SetRGBA(x, y, gb.dmgPalette[ppu.PALETTE_BG][(bgp>>(c*2))&0x03])
+		}
+	}
+	if viewport {
+		drawViewport(img, int(gb.ppu.SCX()), int(gb.ppu.SCY()))
+	}
+	return png.Encode(w, img)
+}
+
+func drawViewport(img *image.RGBA, scx, scy int) {
+	set := func(x, y int) {
+		img.SetRGBA(x%ppu.TILEMAP_SIZE, y%ppu.TILEMAP_SIZE, viewportColor)
+	}
+	for x := range ppu.SCREEN_WIDTH {
+		set(scx+x, scy)
+		set(scx+x, scy+ppu.SCREEN_HEIGHT-1)
+	}
+	for y := range ppu.SCREEN_HEIGHT {
+		set(scx, scy+y)
+		set(scx+ppu.SCREEN_WIDTH-1, scy+y)
+	}
+}
@@ -0,0 +1,87 @@
+package gbc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+type TraceFormat int
+
+const (
+	// 16-byte records: A F B C D E H L, SP and PC little-endian, then the
+	// 4 bytes at PC
+	TRACE_BINARY TraceFormat = iota
+	// one Gameboy Doctor line per instruction:
+	// A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+	TRACE_DOCTOR
+)
+
+type tracer struct {
+	file   *os.File
+	w      *bufio.Writer
+	format TraceFormat
+	err    error
+}
+
+// TraceToFile records the CPU state before every instruction to path,
+// replacing any running trace. Writes are buffered and flushed once per
+// frame and by StopTrace.
+func (gb *GameBoy) TraceToFile(path string, format TraceFormat) error {
+	if err := gb.StopTrace(); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	gb.trace = &tracer{file: f, w: bufio.NewWriterSize(f, 64*1024), format: format}
+	return nil
+}
+
+// StopTrace flushes and closes the trace file, returning the first error
+// met while tracing.
+func (gb *GameBoy) StopTrace() error {
+	t := gb.trace
+	if t == nil {
+		return nil
+	}
+	gb.trace = nil
+	if err := t.w.Flush(); t.err == nil {
+		t.err = err
+	}
+	if err := t.file.Close(); t.err == nil {
+		t.err = err
+	}
+	return t.err
+}
+
+func (gb *GameBoy) traceInstruction() {
+	t := gb.trace
+	if t.err != nil {
+		return
+	}
+	c := gb.cpu
+	var mem [4]byte
+	for i := range mem {
+		mem[i] = gb.mem.Peek(c.PC + uint16(i))
+	}
+
+	switch t.format {
+	case TRACE_BINARY:
+		_, t.err = t.w.Write([]byte{
+			c.A, c.F, c.B, c.C, c.D, c.E, c.H, c.L,
+			byte(c.SP), byte(c.SP >> 8), byte(c.PC), byte(c.PC >> 8),
+			mem[0], mem[1], mem[2], mem[3],
+		})
+	default:
+		_, t.err = fmt.Fprintf(t.w, "A:%02X F:%02X B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X PCMEM:%02X,%02X,%02X,%02X\n",
+			c.A, c.F, c.B, c.C, c.D, c.E, c.H, c.L, c.SP, c.PC, mem[0], mem[1], mem[2], mem[3])
+	}
+}
+
+func (gb *GameBoy) flushTrace() {
+	if t := gb.trace; t != nil && t.err == nil {
+		t.err = t.w.Flush()
+	}
+}
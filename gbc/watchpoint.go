@@ -0,0 +1,93 @@
+package gbc
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/duyquang6/go-retroid/cpu"
+)
+
+// watchBus wraps the CPU bus to check the addresses the CPU writes, so
+// that writes from anything else (Poke, cheats, SkipBoot, OAM DMA) never
+// trigger a watchpoint.
+type watchBus struct {
+	cpu.Bus
+	gb *GameBoy
+}
+
+func (b watchBus) Write(address uint16, value byte) {
+	b.Bus.Write(address, value)
+	b.gb.checkWatchpoint(address)
+}
+
+// Idle forwards the idle M-cycles a cycle-accurate bus counts.
+func (b watchBus) Idle() {
+	if i, ok := b.Bus.(cpu.Idler); ok {
+		i.Idle()
+	}
+}
+
+// AddWatchpoint pauses emulation after an instruction writes to address.
+func (gb *GameBoy) AddWatchpoint(address uint16) {
+	if gb.watchpoints == nil {
+		gb.watchpoints = make(map[uint16]struct{})
+		gb.setCPUBus()
+	}
+	gb.watchpoints[address] = struct{}{}
+}
+
+func (gb *GameBoy) RemoveWatchpoint(address uint16) {
+	delete(gb.watchpoints, address)
+}
+
+// Watchpoints returns the watched addresses in ascending order.
+func (gb *GameBoy) Watchpoints() []uint16 {
+	return slices.Sorted(maps.Keys(gb.watchpoints))
+}
+
+func (gb *GameBoy) ClearWatchpoints() {
+	gb.watchpoints = nil
+	gb.setCPUBus()
+}
+
+func (gb *GameBoy) checkWatchpoint(address uint16) {
+	if _, ok := gb.watchpoints[address]; ok {
+		gb.stop(STOP_WATCHPOINT, address)
+	}
+}
+
+// registerWatch pauses when register name becomes value.
+type registerWatch struct {
+	name    string
+	value   uint16
+	matched bool
+}
+
+// WatchRegister pauses emulation after an instruction leaves the named
+// register, see ReadRegister, equal to value when it wasn't before.
+// Instructions keeping it at value don't pause again.
+func (gb *GameBoy) WatchRegister(name string, value uint16) error {
+	current, err := gb.ReadRegister(name)
+	if err != nil {
+		return err
+	}
+	gb.registerWatches = append(gb.registerWatches, &registerWatch{
+		name: name, value: value, matched: current == value,
+	})
+	return nil
+}
+
+func (gb *GameBoy) ClearRegisterWatches() {
+	gb.registerWatches = nil
+}
+
+func (gb *GameBoy) checkRegisterWatches() {
+	for _, w := range gb.registerWatches {
+		v, _ := gb.ReadRegister(w.name)
+		matched := v == w.value
+		if matched && !w.matched {
+			gb.stop(STOP_REGISTER_WATCH, gb.cpu.PC)
+		}
+		w.matched = matched
+	}
+}
@@ -0,0 +1,187 @@
+package gbc_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/duyquang6/go-retroid/gbc"
+)
+
+func Test_BreakOnInterrupt(t *testing.T) {
+	gb := newTestGB(t,
+		0x3E, 0x01, // LD A,0x01
+		0xE0, 0xFF, // LDH (0xFF),A ; IE = VBlank
+		0xE0, 0x0F, // LDH (0x0F),A ; IF = VBlank
+		0xFB, // EI
+		0x00, // NOP
+	)
+	gb.BreakOnInterrupt(0)
+
+	for i := 0; i < 10 && !gb.Paused(); i++ {
+		gb.Step()
+	}
+
+	if !gb.Paused() {
+		t.Fatal("run loop did not pause on VBlank")
+	}
+	if pc := gb.CPU().PC; pc != 0x0040 {
+		t.Errorf("PC = %04X, want 0040", pc)
+	}
+}
+
+func Test_RunToBreakpoint(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], []byte{
+		0x00,       // NOP
+		0x3E, 0x42, // LD A,0x42
+		0xEA, 0x00, 0xC0, // LD (0xC000),A
+		0x00,       // NOP
+		0x00,       // NOP
+		0x18, 0xFE, // JR -2
+	})
+	gb := gbc.NewGameBoy()
+	if _, err := gb.RunToBreakpoint(100); !errors.Is(err, gbc.ErrNoCartridge) {
+		t.Errorf("RunToBreakpoint() without ROM = %v, want ErrNoCartridge", err)
+	}
+	if err := gb.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	gb.CPU().AddBreakpoint(0x0108)
+	gb.AddWatchpoint(0xC000)
+
+	steps := []struct {
+		want gbc.StopReason
+		pc   uint16
+	}{
+		// the write comes first, the address is the one written
+		{gbc.StopReason{Kind: gbc.STOP_WATCHPOINT, Address: 0xC000}, 0x0106},
+		{gbc.StopReason{Kind: gbc.STOP_BREAKPOINT, Address: 0x0108}, 0x0108},
+	}
+	for _, step := range steps {
+		got, err := gb.RunToBreakpoint(gbc.FRAME_CYCLES)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != step.want || gb.CPU().PC != step.pc {
+			t.Errorf("RunToBreakpoint() = %+v at PC %04X, want %+v at %04X", got, gb.CPU().PC, step.want, step.pc)
+		}
+	}
+
+	gb.CPU().ClearBreakpoints()
+	before := gb.Cycles()
+	got, err := gb.RunToBreakpoint(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != gbc.STOP_CYCLES || gb.Cycles()-before < 1000 {
+		t.Errorf("RunToBreakpoint(1000) = %+v after %d cycles, want STOP_CYCLES after 1000", got, gb.Cycles()-before)
+	}
+}
+
+func Test_WatchpointCPUWritesOnly(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x07, // LD A,0x07
+		0xEA, 0x00, 0xC0, // LD (0xC000),A
+		0x18, 0xFE, // JR -2
+	})
+	for _, accurate := range []bool{true, false} {
+		gb := gbc.NewGameBoy()
+		if err := gb.LoadROM(rom); err != nil {
+			t.Fatal(err)
+		}
+		gb.SetCycleAccurate(accurate)
+		gb.AddWatchpoint(0xC000)
+
+		gb.Poke(0xC000, 0x01)
+		if gb.Paused() {
+			t.Fatalf("cycle-accurate %v: Poke hit the watchpoint", accurate)
+		}
+		got, err := gb.RunToBreakpoint(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (gbc.StopReason{Kind: gbc.STOP_WATCHPOINT, Address: 0xC000}); got != want {
+			t.Errorf("cycle-accurate %v: RunToBreakpoint() = %+v, want %+v", accurate, got, want)
+		}
+	}
+}
+
+func Test_ListAndClearBreakpoints(t *testing.T) {
+	gb := newTestGB(t,
+		0x00,       // NOP
+		0x00,       // NOP
+		0x3E, 0x07, // LD A,0x07
+		0xEA, 0x00, 0xC0, // LD (0xC000),A
+		0x18, 0xF7, // JR -9
+	)
+
+	for _, addr := range []uint16{0x0104, 0x0101, 0x0102} {
+		gb.CPU().AddBreakpoint(addr)
+	}
+	gb.AddWatchpoint(0xC000)
+	gb.AddWatchpoint(0xC001)
+
+	if got, want := gb.CPU().Breakpoints(), []uint16{0x0101, 0x0102, 0x0104}; !slices.Equal(got, want) {
+		t.Errorf("Breakpoints() = %04X, want %04X", got, want)
+	}
+	if got, want := gb.Watchpoints(), []uint16{0xC000, 0xC001}; !slices.Equal(got, want) {
+		t.Errorf("Watchpoints() = %04X, want %04X", got, want)
+	}
+
+	gb.RunCycles(1000)
+	if !gb.Paused() || gb.CPU().PC != 0x0101 {
+		t.Fatalf("Paused() = %v at PC 0x%04X, want paused at 0x0101", gb.Paused(), gb.CPU().PC)
+	}
+
+	gb.CPU().ClearBreakpoints()
+	gb.ClearWatchpoints()
+	if len(gb.CPU().Breakpoints()) != 0 || len(gb.Watchpoints()) != 0 {
+		t.Fatal("breakpoints or watchpoints left after clearing")
+	}
+
+	gb.Resume()
+	gb.RunCycles(1000)
+	if gb.Paused() {
+		t.Errorf("paused at PC 0x%04X after clearing", gb.CPU().PC)
+	}
+}
+
+func Test_WatchRegister(t *testing.T) {
+	gb := newTestGB(t,
+		0x3E, 0x10, // LD A,0x10
+		0x06, 0x42, // LD B,0x42
+		0x78,       // LD A,B
+		0x00,       // NOP
+		0x3E, 0x00, // LD A,0x00
+		0x78,       // LD A,B
+		0x18, 0xFE, // JR -2
+	)
+	if err := gb.WatchRegister("a", 0x42); err != nil {
+		t.Fatal(err)
+	}
+	if err := gb.WatchRegister("Q", 0); !errors.Is(err, gbc.ErrUnknownRegister) {
+		t.Errorf("WatchRegister(Q) = %v, want ErrUnknownRegister", err)
+	}
+
+	gb.RunCycles(1000)
+	if !gb.Paused() || gb.CPU().PC != 0x0105 || gb.CPU().A != 0x42 {
+		t.Fatalf("Paused() = %v at PC 0x%04X with A = %02X, want paused at 0x0105 with A = 42",
+			gb.Paused(), gb.CPU().PC, gb.CPU().A)
+	}
+
+	// the NOP keeps A at 0x42, the next pause is when it gets back there
+	gb.Resume()
+	gb.RunCycles(1000)
+	if !gb.Paused() || gb.CPU().PC != 0x0109 {
+		t.Fatalf("Paused() = %v at PC 0x%04X, want paused at 0x0109", gb.Paused(), gb.CPU().PC)
+	}
+
+	gb.ClearRegisterWatches()
+	gb.Resume()
+	gb.RunCycles(1000)
+	if gb.Paused() {
+		t.Error("paused after clearing the register watches")
+	}
+}
@@ -0,0 +1,202 @@
+package gbs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/duyquang6/go-retroid/apu"
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+const (
+	HEADER_SIZE  = 0x70
+	CLOCK_HZ     = 4194304
+	FRAME_CYCLES = 70224
+
+	// return address pushed when calling init/play, a HALT stub at the
+	// cartridge entry point: below the load address (>= 0x0400) and past
+	// the RST and interrupt vectors, so no GBS code jumps there
+	returnAddress uint16 = 0x0100
+	// a routine taking longer than this is considered stuck
+	maxCallCycles = 60 * FRAME_CYCLES
+)
+
+var ErrBadMagic = errors.New("gbs: missing GBS magic")
+
+type Header struct {
+	Version   byte
+	Songs     byte
+	FirstSong byte
+	LoadAddr  uint16
+	InitAddr  uint16
+	PlayAddr  uint16
+	SP        uint16
+	TMA       byte
+	TAC       byte
+	Title     string
+	Author    string
+	Copyright string
+}
+
+// Player runs the init/play routines of a GBS file on a bare CPU. The
+// routines drive the sound registers (0xFF10-0xFF3F) which the APU renders.
+type Player struct {
+	Header Header
+
+	mem *mmu.Memory
+	cpu *cpu.CPU
+	apu *apu.APU
+
+	track  int
+	cycles int
+	// cycles the APU ran inside routines ahead of the playback clock
+	ahead int
+}
+
+func Load(data []byte) (*Player, error) {
+	if len(data) < HEADER_SIZE {
+		return nil, fmt.Errorf("gbs: file too short (%d bytes)", len(data))
+	}
+	if string(data[0:3]) != "GBS" {
+		return nil, ErrBadMagic
+	}
+
+	h := Header{
+		Version:   data[0x03],
+		Songs:     data[0x04],
+		FirstSong: data[0x05],
+		LoadAddr:  le16(data[0x06:]),
+		InitAddr:  le16(data[0x08:]),
+		PlayAddr:  le16(data[0x0A:]),
+		SP:        le16(data[0x0C:]),
+		TMA:       data[0x0E],
+		TAC:       data[0x0F],
+		Title:     cstring(data[0x10:0x30]),
+		Author:    cstring(data[0x30:0x50]),
+		Copyright: cstring(data[0x50:0x70]),
+	}
+	code := data[HEADER_SIZE:]
+	if h.LoadAddr < 0x0400 || int(h.LoadAddr)+len(code) > 0x8000 {
+		return nil, fmt.Errorf("gbs: code at 0x%04X (%d bytes) does not fit the unbanked ROM window", h.LoadAddr, len(code))
+	}
+
+	mem := mmu.New()
+	mem.WriteBytes(h.LoadAddr, code)
+	mem.WriteBytes(returnAddress, []byte{0x76}) // HALT
+	// RST n jumps to LoadAddr+n
+	for n := uint16(0); n < 0x40; n += 8 {
+		target := h.LoadAddr + n
+		mem.WriteBytes(n, []byte{0xC3, byte(target), byte(target >> 8)}) // JP target
+	}
+	p := &Player{Header: h, mem: mem, cpu: cpu.New(mem), apu: apu.New(mem)}
+	return p, nil
+}
+
+func (p *Player) Memory() *mmu.Memory {
+	return p.mem
+}
+
+// SetSampleRate sets the rate of the samples produced, 0 stops sampling.
+func (p *Player) SetSampleRate(hz int) {
+	p.apu.SetSampleRate(hz)
+}
+
+// Samples drains the interleaved stereo samples produced so far.
+func (p *Player) Samples() []float32 {
+	return p.apu.Samples()
+}
+
+// SelectTrack runs the init routine for the 1-based track n.
+func (p *Player) SelectTrack(n int) error {
+	if n < 1 || n > int(p.Header.Songs) {
+		return fmt.Errorf("gbs: track %d out of range 1-%d", n, p.Header.Songs)
+	}
+	p.track = n
+	p.cycles = 0
+	p.ahead = 0
+	p.cpu.Reset()
+	p.cpu.SP = p.Header.SP
+	p.cpu.A = byte(n - 1)
+	// power cycle the APU to clear the previous track's registers, sound
+	// on as the init routines expect
+	p.mem.Write(0xFF26, 0x00)
+	p.mem.Write(0xFF26, 0x80)
+	return p.call(p.Header.InitAddr)
+}
+
+// Rate returns how many times per second the play routine is called.
+func (p *Player) Rate() float64 {
+	if p.Header.TAC&0x04 == 0 {
+		return float64(CLOCK_HZ) / FRAME_CYCLES
+	}
+	clocks := [4]float64{4096, 262144, 65536, 16384}
+	rate := clocks[p.Header.TAC&0x03] / float64(256-int(p.Header.TMA))
+	if p.Header.TAC&0x80 != 0 {
+		// CGB double speed
+		rate *= 2
+	}
+	return rate
+}
+
+// Tick advances the playback clock by the given T-cycles, calling the play
+// routine each time a period at Rate elapses.
+func (p *Player) Tick(cycles int) error {
+	if p.track == 0 {
+		return errors.New("gbs: no track selected")
+	}
+	period := int(float64(CLOCK_HZ) / p.Rate())
+	for p.cycles+cycles >= period {
+		// the CPU idles until the next call
+		idle := period - p.cycles
+		p.idle(idle)
+		cycles -= idle
+		p.cycles = 0
+		if err := p.call(p.Header.PlayAddr); err != nil {
+			return err
+		}
+	}
+	p.idle(cycles)
+	p.cycles += cycles
+	return nil
+}
+
+// idle steps the APU for cycles of playback clock, less what it already
+// ran while a routine executed.
+func (p *Player) idle(cycles int) {
+	n := min(cycles, p.ahead)
+	p.ahead -= n
+	p.apu.Step(cycles - n)
+}
+
+func (p *Player) call(address uint16) error {
+	p.cpu.SP -= 2
+	p.mem.Write(p.cpu.SP, byte(returnAddress&0xFF))
+	p.mem.Write(p.cpu.SP+1, byte(returnAddress>>8))
+	p.cpu.PC = address
+
+	for spent := 0; p.cpu.PC != returnAddress; {
+		cycles := p.cpu.Step()
+		p.mem.StepDMA(cycles)
+		p.apu.Step(cycles)
+		p.ahead += cycles
+		spent += cycles
+		if spent > maxCallCycles {
+			return fmt.Errorf("gbs: routine at 0x%04X did not return", address)
+		}
+	}
+	return nil
+}
+
+func le16(b []byte) uint16 {
+	return uint16(b[0]) | uint16(b[1])<<8
+}
+
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,128 @@
+package gbs
+
+import "testing"
+
+func testGBS() []byte {
+	data := make([]byte, HEADER_SIZE)
+	copy(data, "GBS")
+	data[0x03] = 1    // version
+	data[0x04] = 3    // songs
+	data[0x05] = 1    // first song
+	data[0x06] = 0x00 // load 0x0400
+	data[0x07] = 0x04
+	data[0x08] = 0x00 // init 0x0400
+	data[0x09] = 0x04
+	data[0x0A] = 0x04 // play 0x0404
+	data[0x0B] = 0x04
+	data[0x0C] = 0xFE // SP 0xDFFE
+	data[0x0D] = 0xDF
+	copy(data[0x10:], "Test Tune")
+
+	return append(data,
+		// init: store the selected track
+		0xEA, 0x00, 0xC0, // LD (0xC000),A
+		0xC9, // RET
+		// play: count calls
+		0x21, 0x01, 0xC0, // LD HL,0xC001
+		0x34, // INC (HL)
+		0xC9, // RET
+	)
+}
+
+func TestPlayer_SelectTrack(t *testing.T) {
+	p, err := Load(testGBS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Header.Title != "Test Tune" || p.Header.Songs != 3 {
+		t.Errorf("header = %+v", p.Header)
+	}
+
+	if err := p.SelectTrack(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Memory().Read(0xC000); got != 0x01 {
+		t.Errorf("init stored track %d, want 1", got)
+	}
+
+	// one second of playback at the VBlank rate
+	if err := p.Tick(CLOCK_HZ); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Memory().Read(0xC001); got != 59 {
+		t.Errorf("play called %d times, want 59", got)
+	}
+
+	if err := p.SelectTrack(4); err == nil {
+		t.Error("SelectTrack(4) succeeded on a 3-track file")
+	}
+}
+
+func TestPlayer_Samples(t *testing.T) {
+	data := testGBS()[:HEADER_SIZE]
+	data = append(data,
+		// init: full volume on both outputs, trigger a square on channel 2
+		0x3E, 0x77, 0xE0, 0x24, // LD A,0x77 ; LDH (NR50),A
+		0x3E, 0xFF, 0xE0, 0x25, // LD A,0xFF ; LDH (NR51),A
+		0x3E, 0x80, 0xE0, 0x16, // LD A,0x80 ; LDH (NR21),A
+		0x3E, 0xF0, 0xE0, 0x17, // LD A,0xF0 ; LDH (NR22),A
+		0x3E, 0x00, 0xE0, 0x18, // LD A,0x00 ; LDH (NR23),A
+		0x3E, 0x87, 0xE0, 0x19, // LD A,0x87 ; LDH (NR24),A
+		0xC9, // RET
+	)
+	// play right after init returns
+	data[0x0A] = byte(0x0400 + len(data) - HEADER_SIZE - 1)
+	p, err := Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetSampleRate(48000)
+	if err := p.SelectTrack(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Tick(CLOCK_HZ / 10); err != nil {
+		t.Fatal(err)
+	}
+
+	samples := p.Samples()
+	if len(samples) < 2*4800-8 || len(samples) > 2*4800+8 {
+		t.Fatalf("got %d samples for 0.1s at 48kHz, want about %d", len(samples), 2*4800)
+	}
+	silent := true
+	for _, s := range samples {
+		if s != samples[0] {
+			silent = false
+			break
+		}
+	}
+	if silent {
+		t.Error("output is silent")
+	}
+}
+
+func TestPlayer_RSTRelocated(t *testing.T) {
+	data := testGBS()[:HEADER_SIZE]
+	data = append(data,
+		// init: call the RST 08 handler at LoadAddr+8
+		0xCF,                   // RST 08
+		0xC9,                   // RET
+		0x00, 0x00, 0x00, 0x00, // padding
+		0x00, 0x00,
+		// RST 08 handler
+		0x3E, 0x42, // LD A,0x42
+		0xEA, 0x00, 0xC0, // LD (0xC000),A
+		0xC9, // RET
+	)
+	// play is the RET after RST 08
+	data[0x0A] = 0x01
+	p, err := Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SelectTrack(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Memory().Read(0xC000); got != 0x42 {
+		t.Errorf("RST 08 handler stored 0x%02X, want 0x42", got)
+	}
+}
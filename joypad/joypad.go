@@ -0,0 +1,79 @@
+package joypad
+
+import (
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+const ADDR_P1 uint16 = 0xFF00
+
+type Button byte
+
+// the low nibble are the directions read through P14, the high nibble the
+// action buttons read through P15, both in P1 bit order
+const (
+	BUTTON_RIGHT Button = 1 << iota
+	BUTTON_LEFT
+	BUTTON_UP
+	BUTTON_DOWN
+	BUTTON_A
+	BUTTON_B
+	BUTTON_SELECT
+	BUTTON_START
+)
+
+type Joypad struct {
+	mem *mmu.Memory
+
+	// bitmask of the buttons held down
+	pressed Button
+	// P1 bits 4-5, a 0 bit selects the line
+	selected byte
+}
+
+func New(mem *mmu.Memory) *Joypad {
+	j := &Joypad{mem: mem, selected: 0x30}
+	mem.MapIO(ADDR_P1, j.readP1, func(v byte) { j.update(j.pressed, v&0x30) })
+	return j
+}
+
+func (j *Joypad) Press(b Button) {
+	j.update(j.pressed|b, j.selected)
+}
+
+func (j *Joypad) Release(b Button) {
+	j.update(j.pressed&^b, j.selected)
+}
+
+// Pressed returns the bitmask of the buttons held down.
+func (j *Joypad) Pressed() Button {
+	return j.pressed
+}
+
+func (j *Joypad) readP1() byte {
+	return 0xC0 | j.selected | j.lines(j.pressed, j.selected)
+}
+
+// lines returns P10-P13, active low, for the selected button groups. With
+// no group selected every line reads 1; with both, a line reads 0 when a
+// button of either group pulls it low.
+func (j *Joypad) lines(pressed Button, selected byte) byte {
+	var low byte
+	if selected&0x10 == 0 {
+		low |= byte(pressed) & 0x0F
+	}
+	if selected&0x20 == 0 {
+		low |= byte(pressed) >> 4
+	}
+	return ^low & 0x0F
+}
+
+// update applies a new button and select state, requesting the joypad
+// interrupt when any input line goes from high to low.
+func (j *Joypad) update(pressed Button, selected byte) {
+	before := j.lines(j.pressed, j.selected)
+	j.pressed, j.selected = pressed, selected
+	if before&^j.lines(pressed, selected) != 0 {
+		cpu.RequestInterrupt(j.mem, cpu.INT_JOYPAD)
+	}
+}
@@ -0,0 +1,71 @@
+package joypad
+
+import (
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+func TestJoypad_P1(t *testing.T) {
+	mem := mmu.New()
+	j := New(mem)
+
+	mem.Write(ADDR_P1, 0x20) // directions
+	j.Press(BUTTON_DOWN)
+	j.Press(BUTTON_A)
+	if got := mem.Read(ADDR_P1); got != 0xE7 {
+		t.Errorf("P1 with directions selected = 0x%02X, want 0xE7", got)
+	}
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_JOYPAD == 0 {
+		t.Error("pressing a selected button did not request the joypad interrupt")
+	}
+
+	mem.Write(cpu.ADDR_IF, 0)
+	mem.Write(ADDR_P1, 0x10) // action buttons: A already held goes low
+	if got := mem.Read(ADDR_P1); got != 0xDE {
+		t.Errorf("P1 with buttons selected = 0x%02X, want 0xDE", got)
+	}
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_JOYPAD == 0 {
+		t.Error("selecting a held button did not request the joypad interrupt")
+	}
+
+	mem.Write(cpu.ADDR_IF, 0)
+	j.Press(BUTTON_LEFT) // not selected
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_JOYPAD != 0 {
+		t.Error("unselected button requested the joypad interrupt")
+	}
+}
+
+func TestJoypad_SelectBits(t *testing.T) {
+	tests := []struct {
+		name    string
+		p1      byte
+		pressed Button
+		want    byte // low nibble
+	}{
+		{"none selected", 0x30, BUTTON_A | BUTTON_DOWN, 0x0F},
+		{"none selected, nothing pressed", 0x30, 0, 0x0F},
+		{"directions", 0x20, BUTTON_RIGHT | BUTTON_UP, 0x0A},
+		{"directions ignore buttons", 0x20, BUTTON_A | BUTTON_START, 0x0F},
+		{"buttons", 0x10, BUTTON_B | BUTTON_START, 0x05},
+		{"buttons ignore directions", 0x10, BUTTON_LEFT | BUTTON_DOWN, 0x0F},
+		{"both groups", 0x00, BUTTON_RIGHT | BUTTON_START, 0x06},
+		{"both groups same line", 0x00, BUTTON_LEFT | BUTTON_B, 0x0D},
+		{"both groups nothing pressed", 0x00, 0, 0x0F},
+	}
+	for _, tc := range tests {
+		mem := mmu.New()
+		j := New(mem)
+		mem.Write(ADDR_P1, tc.p1)
+		j.Press(tc.pressed)
+
+		got := mem.Read(ADDR_P1)
+		if got&0x0F != tc.want {
+			t.Errorf("%s: low nibble = 0x%X, want 0x%X", tc.name, got&0x0F, tc.want)
+		}
+		if got&0xF0 != 0xC0|tc.p1 {
+			t.Errorf("%s: high nibble = 0x%X, want 0x%X", tc.name, got>>4, (0xC0|tc.p1)>>4)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package joypad
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+type state struct {
+	Pressed  Button
+	Selected byte
+}
+
+func (j *Joypad) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state{Pressed: j.pressed, Selected: j.selected})
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores the buttons and select bits without raising
+// the joypad interrupt.
+func (j *Joypad) UnmarshalBinary(data []byte) error {
+	var s state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	j.pressed, j.selected = s.Pressed, s.Selected
+	return nil
+}
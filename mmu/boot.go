@@ -0,0 +1,20 @@
+package mmu
+
+// MapBootROM overlays a boot ROM on the cartridge ROM until it is unmapped
+// with nil. rom is laid out at its addresses: 0x0000-0x00FF, and for a CGB
+// boot ROM also 0x0200-0x08FF, the cartridge header showing through the gap.
+func (m *Memory) MapBootROM(rom []byte) {
+	m.boot = rom
+}
+
+// BootROMMapped reports whether the boot ROM still hides the cartridge.
+func (m *Memory) BootROMMapped() bool {
+	return m.boot != nil
+}
+
+func (m *Memory) isBootAddress(address uint16) bool {
+	if address < 0x0100 {
+		return true
+	}
+	return address >= 0x0200 && int(address) < len(m.boot)
+}
@@ -0,0 +1,66 @@
+package mmu
+
+const (
+	ADDR_VBK  uint16 = 0xFF4F
+	ADDR_SVBK uint16 = 0xFF70
+)
+
+// cgbBanks holds the memory a CGB adds on top of the DMG map: a second
+// VRAM bank and WRAM banks 2-7. VRAM bank 0 and WRAM bank 1 stay in data.
+type cgbBanks struct {
+	vram1 [0x2000]byte
+	wram  [6][0x1000]byte
+
+	vramBank byte
+	wramBank byte
+}
+
+// SetCGB enables VRAM banking through VBK and WRAM banking through SVBK.
+// Disabling it maps bank 0 and 1 back and makes both registers read 0xFF.
+func (m *Memory) SetCGB(enabled bool) {
+	if !enabled {
+		m.cgb = nil
+		m.MapIO(ADDR_VBK, func() byte { return 0xFF }, func(byte) {})
+		m.MapIO(ADDR_SVBK, func() byte { return 0xFF }, func(byte) {})
+		return
+	}
+	if m.cgb == nil {
+		m.cgb = &cgbBanks{wramBank: 1}
+	}
+	m.MapIO(ADDR_VBK,
+		func() byte { return 0xFE | m.cgb.vramBank },
+		func(v byte) { m.cgb.vramBank = v & 0x01 })
+	m.MapIO(ADDR_SVBK,
+		func() byte { return 0xF8 | m.cgb.wramBank },
+		func(v byte) {
+			// bank 0 selects bank 1
+			m.cgb.wramBank = max(v&0x07, 1)
+		})
+}
+
+func (m *Memory) CGB() bool {
+	return m.cgb != nil
+}
+
+// VRAMBank returns the 8KB of the given VRAM bank, nil if it doesn't exist.
+func (m *Memory) VRAMBank(bank int) []byte {
+	switch {
+	case bank == 0:
+		return m.data[0x8000:0xA000]
+	case bank == 1 && m.cgb != nil:
+		return m.cgb.vram1[:]
+	}
+	return nil
+}
+
+// banked returns the switchable CGB memory backing address, or nil when
+// the access goes to the plain backing array.
+func (m *Memory) banked(address uint16) *byte {
+	switch {
+	case address >= 0x8000 && address < 0xA000 && m.cgb.vramBank == 1:
+		return &m.cgb.vram1[address-0x8000]
+	case address >= 0xD000 && address < 0xE000 && m.cgb.wramBank > 1:
+		return &m.cgb.wram[m.cgb.wramBank-2][address-0xD000]
+	}
+	return nil
+}
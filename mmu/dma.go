@@ -0,0 +1,67 @@
+package mmu
+
+const (
+	ADDR_DMA uint16 = 0xFF46
+	// 160 bytes, one per M-cycle
+	DMA_CYCLES = 640
+)
+
+// dma is an OAM DMA transfer in progress.
+type dma struct {
+	active bool
+	source uint16
+	// T-cycles elapsed since the transfer started
+	elapsed int
+}
+
+// startDMA begins copying 160 bytes from value<<8 to OAM.
+func (m *Memory) startDMA(value byte) {
+	m.data[ADDR_DMA] = value
+	m.dma = dma{active: true, source: uint16(value) << 8}
+}
+
+// StepDMA advances a running OAM DMA by the given T-cycles.
+func (m *Memory) StepDMA(cycles int) {
+	if !m.dma.active {
+		return
+	}
+	from := m.dma.elapsed / 4
+	m.dma.elapsed += cycles
+	to := min(m.dma.elapsed/4, 0xA0)
+	for i := from; i < to; i++ {
+		m.data[0xFE00+i] = m.dmaRead(m.dma.source + uint16(i))
+	}
+	if to == 0xA0 {
+		m.dma.active = false
+	}
+}
+
+// DMAActive reports whether an OAM DMA is in progress.
+func (m *Memory) DMAActive() bool {
+	return m.dma.active
+}
+
+// dmaRead reads a DMA source byte. Sources above 0xDFFF hit the echo of
+// WRAM rather than OAM and I/O.
+func (m *Memory) dmaRead(address uint16) byte {
+	if address >= 0xE000 {
+		address -= 0x2000
+	}
+	if m.cart != nil && isCartridgeAddress(address) {
+		return m.cart.Read(address)
+	}
+	if m.cgb != nil {
+		if b := m.banked(address); b != nil {
+			return *b
+		}
+	}
+	return m.data[address]
+}
+
+// isDMABlocked reports whether a DMA in progress owns the bus address is
+// on. Only the I/O registers, HRAM and IE stay reachable. Real hardware
+// returns whatever the DMA is transferring; emulating that is best-effort
+// here: blocked reads return 0xFF and blocked writes are dropped.
+func (m *Memory) isDMABlocked(address uint16) bool {
+	return m.dma.active && address < 0xFF00
+}
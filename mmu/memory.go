@@ -1,26 +1,255 @@
 package mmu
 
+import "log/slog"
+
+// Cartridge backs the ROM (0x0000-0x7FFF) and external RAM (0xA000-0xBFFF)
+// regions once inserted.
+type Cartridge interface {
+	Read(address uint16) byte
+	Write(address uint16, value byte)
+}
+
 type Memory struct {
 	// 64KB memory
 	data [0x10000]byte
+
+	cart   Cartridge
+	logger *slog.Logger
+
+	// memory-mapped I/O registers (0xFF00-0xFF7F) with side effects
+	ioRead  [0x80]func() byte
+	ioWrite [0x80]func(byte)
+
+	oamBug func()
+
+	// boot ROM mapped over the cartridge, see MapBootROM
+	boot []byte
+
+	// CGB VRAM/WRAM banks, nil in DMG mode
+	cgb *cgbBanks
+
+	dma dma
+
+	// VRAM and OAM are unreachable by the CPU while the PPU reads them
+	vramLocked, oamLocked bool
+	// whether the last CPU access lost the bus, see LastAccessConflicted
+	conflicted bool
+
+	onWrite func(address uint16, value byte)
+	// called when a CPU write changes a VRAM byte
+	onVRAMChange func(bank int, offset uint16)
+
+	// per-region access counts, nil unless enabled
+	stats *[regionCount]AccessCount
 }
 
 func New() *Memory {
-	return &Memory{}
+	m := &Memory{logger: slog.New(slog.DiscardHandler)}
+	m.MapIO(ADDR_DMA, nil, m.startDMA)
+	return m
+}
+
+func (m *Memory) SetLogger(logger *slog.Logger) {
+	m.logger = logger
 }
 
-func (m Memory) Read(address uint16) byte {
+func (m *Memory) InsertCartridge(cart Cartridge) {
+	m.cart = cart
+}
+
+// MapIO routes CPU accesses of an I/O register to the owning component.
+// A nil read or write falls back to the plain backing byte.
+func (m *Memory) MapIO(address uint16, read func() byte, write func(byte)) {
+	m.ioRead[address-0xFF00] = read
+	m.ioWrite[address-0xFF00] = write
+}
+
+// OnOAMBug registers the handler of the DMG OAM corruption bug.
+func (m *Memory) OnOAMBug(fn func()) {
+	m.oamBug = fn
+}
+
+// TriggerOAMBug is called when the CPU's 16-bit inc/dec unit puts address
+// on the bus; values in 0xFE00-0xFEFF may corrupt OAM.
+func (m *Memory) TriggerOAMBug(address uint16) {
+	if m.oamBug != nil && address >= 0xFE00 && address < 0xFF00 {
+		m.oamBug()
+	}
+}
+
+func (m *Memory) Read(address uint16) byte {
+	if m.stats != nil {
+		m.stats[Region(address)].Reads++
+	}
+	m.conflicted = false
+	if !isHRAMAddress(address) && (m.isDMABlocked(address) || m.isLocked(address)) {
+		m.conflicted = true
+		return 0xFF
+	}
+	return m.Peek(address)
+}
+
+// Peek reads address like the CPU does, cartridge, I/O registers and
+// banks included, but ignores the PPU locks and OAM DMA blocking and
+// counts nothing in the access stats. It is the debugger's view of memory.
+func (m *Memory) Peek(address uint16) byte {
+	if m.boot != nil && m.isBootAddress(address) {
+		return m.boot[address]
+	}
+	if m.cart != nil && isCartridgeAddress(address) {
+		return m.cart.Read(address)
+	}
+	if isUnusableAddress(address) {
+		// DMG reads 0x00 here while OAM is accessible
+		return 0x00
+	}
+	if isIOAddress(address) && m.ioRead[address-0xFF00] != nil {
+		return m.ioRead[address-0xFF00]()
+	}
+	if m.cgb != nil {
+		if b := m.banked(address); b != nil {
+			return *b
+		}
+	}
 	return m.data[address]
 }
 
+// OnWrite registers a callback observing every bus write, nil removes it.
+func (m *Memory) OnWrite(fn func(address uint16, value byte)) {
+	m.onWrite = fn
+}
+
 func (m *Memory) Write(address uint16, payload byte) {
+	if m.stats != nil {
+		m.stats[Region(address)].Writes++
+	}
+	if m.onWrite != nil {
+		m.onWrite(address, payload)
+	}
+	m.conflicted = false
+	if isHRAMAddress(address) {
+		m.data[address] = payload
+		return
+	}
+	if m.isDMABlocked(address) {
+		m.conflicted = true
+		m.logger.Debug("ignored write during OAM DMA", "address", address, "value", payload)
+		return
+	}
+	if m.isLocked(address) {
+		m.conflicted = true
+		m.logger.Debug("ignored write to memory locked by the PPU", "address", address, "value", payload)
+		return
+	}
+	if m.cart != nil && isCartridgeAddress(address) {
+		m.cart.Write(address, payload)
+		return
+	}
+	if isUnusableAddress(address) {
+		m.logger.Debug("ignored write to unusable memory", "address", address, "value", payload)
+		return
+	}
+	if isIOAddress(address) && m.ioWrite[address-0xFF00] != nil {
+		m.ioWrite[address-0xFF00](payload)
+		return
+	}
+	if m.cgb != nil {
+		if b := m.banked(address); b != nil {
+			m.store(b, address, 1, payload)
+			return
+		}
+	}
+	m.store(&m.data[address], address, 0, payload)
+}
+
+// store writes payload to b, the byte backing address in the given bank,
+// reporting VRAM changes.
+func (m *Memory) store(b *byte, address uint16, bank int, payload byte) {
+	if *b != payload && m.onVRAMChange != nil && address >= 0x8000 && address < 0xA000 {
+		*b = payload
+		m.onVRAMChange(bank, address-0x8000)
+		return
+	}
+	*b = payload
+}
+
+// OnVRAMChange registers a callback invoked when a CPU write changes a
+// byte of VRAM, with the bank and the offset from 0x8000.
+func (m *Memory) OnVRAMChange(fn func(bank int, offset uint16)) {
+	m.onVRAMChange = fn
+}
+
+// LastAccessConflicted reports whether the last Read or Write hit memory
+// another unit owned at the time: anything but HRAM and I/O during OAM DMA,
+// or VRAM and OAM while the PPU reads them. Every access takes one
+// M-cycle regardless, the bus never stalls the CPU: a conflicted read gets
+// 0xFF and a conflicted write is dropped.
+func (m *Memory) LastAccessConflicted() bool {
+	return m.conflicted
+}
+
+// LockVRAM makes CPU accesses to VRAM read 0xFF and drop writes.
+func (m *Memory) LockVRAM(locked bool) {
+	m.vramLocked = locked
+}
+
+// LockOAM makes CPU accesses to OAM read 0xFF and drop writes.
+func (m *Memory) LockOAM(locked bool) {
+	m.oamLocked = locked
+}
+
+func (m *Memory) isLocked(address uint16) bool {
+	return m.vramLocked && address >= 0x8000 && address < 0xA000 ||
+		m.oamLocked && address >= 0xFE00 && address < 0xFEA0
+}
+
+// Clear zeroes all memory, CGB banks included, and cancels any DMA, as
+// on power up. I/O mappings and the cartridge stay in place.
+func (m *Memory) Clear() {
+	m.data = [0x10000]byte{}
+	if m.cgb != nil {
+		m.cgb = &cgbBanks{wramBank: 1}
+	}
+	m.dma = dma{}
+}
+
+// WriteRaw stores a byte in the backing memory, bypassing cartridge and I/O
+// mapping. Components use it to keep the plain value of a mapped register.
+func (m *Memory) WriteRaw(address uint16, payload byte) {
 	m.data[address] = payload
 }
 
+// ReadRaw returns the byte in the backing memory, bypassing cartridge and
+// I/O mapping, locks and access stats.
+func (m *Memory) ReadRaw(address uint16) byte {
+	return m.data[address]
+}
+
 func (m *Memory) WriteBytes(address uint16, payload []byte) {
-	copy(m.data[address:address+uint16(len(payload))], payload)
+	// payloads running past 0xFFFF are truncated
+	copy(m.data[address:], payload)
 }
 
+// RangeInclusive returns the memory from start to end as a slice sharing
+// its storage, capped so that appending cannot overwrite what follows.
 func (m *Memory) RangeInclusive(start, end int) []byte {
-	return m.data[start : end+1]
+	return m.data[start : end+1 : end+1]
+}
+
+func isCartridgeAddress(address uint16) bool {
+	return address < 0x8000 || (address >= 0xA000 && address < 0xC000)
+}
+
+// isUnusableAddress reports whether address falls in the unmapped
+// 0xFEA0-0xFEFF gap between OAM and the I/O registers.
+func isUnusableAddress(address uint16) bool {
+	return address >= 0xFEA0 && address < 0xFF00
+}
+
+func isHRAMAddress(address uint16) bool {
+	return address >= 0xFF80 && address < 0xFFFF
+}
+
+func isIOAddress(address uint16) bool {
+	return address >= 0xFF00 && address < 0xFF80
 }
@@ -12,3 +12,129 @@ func TestMemory_Write(t *testing.T) {
 
 	fmt.Println(mem.Read(0))
 }
+
+func TestMemory_UnusableRegion(t *testing.T) {
+	mem := New()
+
+	for addr := 0xFEA0; addr < 0xFF00; addr++ {
+		mem.Write(uint16(addr), 0xAB)
+		if got := mem.Read(uint16(addr)); got != 0x00 {
+			t.Fatalf("Read(%04X) = %02X, want 00", addr, got)
+		}
+	}
+
+	// neighbouring OAM and I/O stay writable
+	mem.Write(0xFE9F, 0x12)
+	mem.Write(0xFF00, 0x34)
+	if mem.Read(0xFE9F) != 0x12 || mem.Read(0xFF00) != 0x34 {
+		t.Error("writes around the unusable region were dropped")
+	}
+}
+
+func TestMemory_Stats(t *testing.T) {
+	mem := New()
+	mem.Write(0xC000, 1) // not counted yet
+
+	mem.EnableStats()
+	for i := uint16(0); i < 16; i++ {
+		mem.Write(0x8000+i, byte(i))
+	}
+	for i := uint16(0); i < 4; i++ {
+		mem.Read(0xC000 + i)
+	}
+	mem.Write(0xD000, 0xFF)
+
+	want := map[RegionKind]AccessCount{
+		REGION_VRAM: {Writes: 16},
+		REGION_WRAM: {Reads: 4, Writes: 1},
+	}
+	got := mem.Stats()
+	if len(got) != len(want) {
+		t.Errorf("Stats() = %v, want %v", got, want)
+	}
+	for region, count := range want {
+		if got[region] != count {
+			t.Errorf("Stats()[%s] = %+v, want %+v", region, got[region], count)
+		}
+	}
+
+	mem.DisableStats()
+	if mem.Stats() != nil {
+		t.Error("Stats() not nil after DisableStats")
+	}
+}
+
+func TestMemory_DMA(t *testing.T) {
+	mem := New()
+	for i := uint16(0); i < 0xA0; i++ {
+		mem.Write(0xC000+i, byte(i))
+	}
+	mem.Write(0xFF80, 0x42)
+
+	mem.Write(ADDR_DMA, 0xC0)
+	if !mem.DMAActive() {
+		t.Fatal("DMA not started")
+	}
+
+	mem.StepDMA(DMA_CYCLES / 2)
+	if got := mem.Read(0xFF80); got != 0x42 {
+		t.Errorf("HRAM read during DMA = 0x%02X, want 0x42", got)
+	}
+	mem.Write(0xFF81, 0x43)
+	if got := mem.Read(0xFF81); got != 0x43 {
+		t.Errorf("HRAM write during DMA read back 0x%02X, want 0x43", got)
+	}
+	if got := mem.Read(0xC000); got != 0xFF {
+		t.Errorf("WRAM read during DMA = 0x%02X, want 0xFF", got)
+	}
+	mem.Write(0xC000, 0x99) // dropped
+
+	mem.StepDMA(DMA_CYCLES / 2)
+	if mem.DMAActive() {
+		t.Fatal("DMA still active after 640 cycles")
+	}
+	for i := uint16(0); i < 0xA0; i++ {
+		if got := mem.Read(0xFE00 + i); got != byte(i) {
+			t.Fatalf("OAM[%d] = 0x%02X, want 0x%02X", i, got, i)
+		}
+	}
+	if got := mem.Read(0xC000); got != 0x00 {
+		t.Errorf("WRAM after DMA = 0x%02X, want 0x00", got)
+	}
+}
+
+func TestMemory_ConflictedAccess(t *testing.T) {
+	mem := New()
+	mem.Write(ADDR_DMA, 0xC0)
+	mem.StepDMA(4)
+
+	mem.Read(0xC000)
+	if !mem.LastAccessConflicted() {
+		t.Error("WRAM read during DMA not flagged as conflicted")
+	}
+	mem.Write(0x8000, 0x01)
+	if !mem.LastAccessConflicted() {
+		t.Error("VRAM write during DMA not flagged as conflicted")
+	}
+	mem.Read(0xFF80)
+	if mem.LastAccessConflicted() {
+		t.Error("HRAM read during DMA flagged as conflicted")
+	}
+	mem.Read(0xFF44)
+	if mem.LastAccessConflicted() {
+		t.Error("I/O read during DMA flagged as conflicted")
+	}
+
+	mem.StepDMA(DMA_CYCLES)
+	mem.Read(0xC000)
+	if mem.LastAccessConflicted() {
+		t.Error("WRAM read after DMA flagged as conflicted")
+	}
+
+	// the PPU owning VRAM conflicts too
+	mem.LockVRAM(true)
+	mem.Read(0x8000)
+	if !mem.LastAccessConflicted() {
+		t.Error("locked VRAM read not flagged as conflicted")
+	}
+}
@@ -0,0 +1,85 @@
+package mmu
+
+type RegionKind byte
+
+const (
+	REGION_ROM0 RegionKind = iota
+	REGION_ROMX
+	REGION_VRAM
+	REGION_EXTERNAL_RAM
+	REGION_WRAM
+	REGION_ECHO
+	REGION_OAM
+	REGION_UNUSABLE
+	REGION_IO
+	REGION_HRAM
+	REGION_IE
+
+	regionCount
+)
+
+var regionNames = [regionCount]string{
+	"ROM0", "ROMX", "VRAM", "External RAM", "WRAM", "Echo RAM", "OAM", "Unusable", "I/O", "HRAM", "IE",
+}
+
+func (r RegionKind) String() string {
+	if r < regionCount {
+		return regionNames[r]
+	}
+	return "Unknown"
+}
+
+// Region classifies an address into the memory map region it belongs to.
+func Region(address uint16) RegionKind {
+	switch {
+	case address < 0x4000:
+		return REGION_ROM0
+	case address < 0x8000:
+		return REGION_ROMX
+	case address < 0xA000:
+		return REGION_VRAM
+	case address < 0xC000:
+		return REGION_EXTERNAL_RAM
+	case address < 0xE000:
+		return REGION_WRAM
+	case address < 0xFE00:
+		return REGION_ECHO
+	case address < 0xFEA0:
+		return REGION_OAM
+	case address < 0xFF00:
+		return REGION_UNUSABLE
+	case address < 0xFF80:
+		return REGION_IO
+	case address < 0xFFFF:
+		return REGION_HRAM
+	}
+	return REGION_IE
+}
+
+type AccessCount struct {
+	Reads, Writes uint64
+}
+
+// EnableStats starts counting reads and writes per region, from zero.
+func (m *Memory) EnableStats() {
+	m.stats = &[regionCount]AccessCount{}
+}
+
+func (m *Memory) DisableStats() {
+	m.stats = nil
+}
+
+// Stats returns the access counts of every region touched since
+// EnableStats, or nil when stats are disabled.
+func (m *Memory) Stats() map[RegionKind]AccessCount {
+	if m.stats == nil {
+		return nil
+	}
+	stats := make(map[RegionKind]AccessCount)
+	for r, count := range m.stats {
+		if count != (AccessCount{}) {
+			stats[RegionKind(r)] = count
+		}
+	}
+	return stats
+}
@@ -0,0 +1,69 @@
+package mmu
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
+
+// state is the memory a save state holds: the whole address space as
+// backed here, the CGB banks and any DMA in progress. The VRAM/OAM locks
+// follow the PPU mode and are restored with it.
+type state struct {
+	Data []byte
+
+	CGB                bool
+	VRAM1, WRAM        []byte
+	VRAMBank, WRAMBank byte
+
+	DMAActive  bool
+	DMASource  uint16
+	DMAElapsed int
+}
+
+func (m *Memory) MarshalBinary() ([]byte, error) {
+	s := state{
+		Data:      m.data[:],
+		DMAActive: m.dma.active, DMASource: m.dma.source, DMAElapsed: m.dma.elapsed,
+	}
+	if m.cgb != nil {
+		s.CGB = true
+		s.VRAM1 = m.cgb.vram1[:]
+		for _, bank := range m.cgb.wram {
+			s.WRAM = append(s.WRAM, bank[:]...)
+		}
+		s.VRAMBank, s.WRAMBank = m.cgb.vramBank, m.cgb.wramBank
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(s)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores memory saved by MarshalBinary. CGB banking must
+// already be set up as when the state was saved, see SetCGB.
+func (m *Memory) UnmarshalBinary(data []byte) error {
+	var s state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	if len(s.Data) != len(m.data) {
+		return fmt.Errorf("state holds %d bytes of memory, want %d", len(s.Data), len(m.data))
+	}
+	if s.CGB != (m.cgb != nil) {
+		return fmt.Errorf("state CGB banking %v, memory has %v", s.CGB, m.cgb != nil)
+	}
+	if s.CGB && (len(s.VRAM1) != len(m.cgb.vram1) || len(s.WRAM) != 6*0x1000) {
+		return errors.New("state CGB banks are truncated")
+	}
+	copy(m.data[:], s.Data)
+	if m.cgb != nil {
+		copy(m.cgb.vram1[:], s.VRAM1)
+		for i := range m.cgb.wram {
+			copy(m.cgb.wram[i][:], s.WRAM[i*0x1000:])
+		}
+		m.cgb.vramBank, m.cgb.wramBank = s.VRAMBank, s.WRAMBank
+	}
+	m.dma = dma{active: s.DMAActive, source: s.DMASource, elapsed: s.DMAElapsed}
+	return nil
+}
@@ -0,0 +1,24 @@
+package ppu
+
+// TileAttributes is a CGB background map attribute, stored in VRAM bank 1
+// at the same offset as the tile number in bank 0.
+type TileAttributes struct {
+	// BG palette 0-7
+	Palette byte
+	// VRAM bank holding the tile data
+	Bank  int
+	XFlip bool
+	YFlip bool
+	// BG colours 1-3 are drawn over sprites
+	Priority bool
+}
+
+func DecodeTileAttributes(b byte) TileAttributes {
+	return TileAttributes{
+		Palette:  b & 0x07,
+		Bank:     int(b>>3) & 0x01,
+		XFlip:    b&0x20 != 0,
+		YFlip:    b&0x40 != 0,
+		Priority: b&0x80 != 0,
+	}
+}
@@ -0,0 +1,125 @@
+package ppu
+
+func (p *PPU) startOAMScan() {
+	p.setMode(MODE_OAM)
+	p.lineSprites = p.lineSprites[:0]
+	p.scanIndex = 0
+}
+
+// scanOAM checks OAM entries up to (excluding) index end for the current
+// line. The hardware looks at one entry every 2 dots of mode 2 and keeps
+// the first 10 (see SetMaxSpritesPerLine) whose Y range covers LY,
+// regardless of X. Y is offset by 16, so Y=0 (zeroed OAM) and Y>=160
+// never cover a visible line.
+func (p *PPU) scanOAM(end int) {
+	end = min(end, OAM_SPRITES)
+	height := 8
+	if p.LCDC()&0x04 != 0 {
+		height = 16
+	}
+
+	oam := p.OAM()
+	for ; p.scanIndex < end; p.scanIndex++ {
+		if len(p.lineSprites) >= p.maxSprites {
+			continue
+		}
+		y := int(oam[p.scanIndex*4])
+		line := int(p.ly) + 16
+		if line >= y && line < y+height {
+			p.lineSprites = append(p.lineSprites, p.scanIndex)
+		}
+	}
+}
+
+// SetMaxSpritesPerLine sets how many sprites the OAM scan selects per
+// line, clamped to 1-40. The hardware limit of MAX_SPRITES_PER_LINE is
+// the default; above it, games multiplexing sprites stop flickering.
+func (p *PPU) SetMaxSpritesPerLine(n int) {
+	p.maxSprites = min(max(n, 1), OAM_SPRITES)
+}
+
+// LineSprites returns the OAM indices of the sprites selected so far by the
+// OAM scan for the current line.
+func (p *PPU) LineSprites() []int {
+	return append([]int(nil), p.lineSprites...)
+}
+
+// corruptOAM emulates the DMG OAM bug for a 16-bit inc/dec of an address in
+// 0xFE00-0xFEFF during mode 2. OAM is 20 rows of 4 words and the PPU reads
+// one row per M-cycle; the row it is on gets mixed with the row before:
+// its first word becomes ((a ^ c) & (b ^ c)) ^ c, with a its own first
+// word, b and c the first and third words of the preceding row, and its
+// last three words are copied from the preceding row. CGB has no bug.
+func (p *PPU) corruptOAM() {
+	if p.mode != MODE_OAM || p.cgb {
+		return
+	}
+	row := p.clock / 4
+	if row == 0 || row >= 20 {
+		return
+	}
+
+	oam := p.OAM()
+	cur, prev := oam[row*8:row*8+8], oam[row*8-8:row*8]
+	word := func(b []byte, i int) uint16 { return uint16(b[i*2]) | uint16(b[i*2+1])<<8 }
+
+	a, b, c := word(cur, 0), word(prev, 0), word(prev, 2)
+	w0 := ((a ^ c) & (b ^ c)) ^ c
+	cur[0], cur[1] = byte(w0), byte(w0>>8)
+	copy(cur[2:], prev[2:])
+}
+
+// OAMEntry is one of the 40 sprites of OAM. Y and X are offset by 16 and
+// 8 from screen coordinates, see scanOAM and renderSprites.
+type OAMEntry struct {
+	Y, X       byte
+	Tile       byte
+	Attributes byte
+}
+
+// Priority reports whether background colours 1-3 are drawn over the
+// sprite.
+func (e OAMEntry) Priority() bool {
+	return e.Attributes&0x80 != 0
+}
+
+func (e OAMEntry) FlipY() bool {
+	return e.Attributes&0x40 != 0
+}
+
+func (e OAMEntry) FlipX() bool {
+	return e.Attributes&0x20 != 0
+}
+
+// Palette returns the DMG sprite palette, 0 for OBP0 and 1 for OBP1.
+func (e OAMEntry) Palette() byte {
+	return e.Attributes >> 4 & 0x01
+}
+
+// CGBPalette returns the CGB sprite palette, 0-7.
+func (e OAMEntry) CGBPalette() byte {
+	return e.Attributes & 0x07
+}
+
+// Bank returns the CGB VRAM bank holding the sprite's tile.
+func (e OAMEntry) Bank() int {
+	return int(e.Attributes>>3) & 0x01
+}
+
+// Sprite returns OAM entry index, 0-39. Other indexes return a zero entry.
+func (p *PPU) Sprite(index int) OAMEntry {
+	if index < 0 || index >= OAM_SPRITES {
+		return OAMEntry{}
+	}
+	b := p.OAM()[index*4 : index*4+4]
+	return OAMEntry{Y: b[0], X: b[1], Tile: b[2], Attributes: b[3]}
+}
+
+// SetSprite overwrites OAM entry index, 0-39, regardless of the PPU mode.
+// Other indexes are ignored.
+func (p *PPU) SetSprite(index int, e OAMEntry) {
+	if index < 0 || index >= OAM_SPRITES {
+		return
+	}
+	copy(p.OAM()[index*4:], []byte{e.Y, e.X, e.Tile, e.Attributes})
+}
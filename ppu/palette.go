@@ -0,0 +1,71 @@
+package ppu
+
+const (
+	ADDR_BCPS uint16 = 0xFF68
+	ADDR_BCPD uint16 = 0xFF69
+	ADDR_OCPS uint16 = 0xFF6A
+	ADDR_OCPD uint16 = 0xFF6B
+)
+
+// paletteRAM is one of the two CGB colour palette memories: 8 palettes of
+// 4 little-endian RGB555 colours, accessed through an index register that
+// optionally auto-increments on writes.
+type paletteRAM struct {
+	data  [64]byte
+	index byte
+}
+
+func (r *paletteRAM) readSpec() byte {
+	return 0x40 | r.index
+}
+
+func (r *paletteRAM) writeSpec(v byte) {
+	r.index = v & 0xBF
+}
+
+func (r *paletteRAM) readData() byte {
+	return r.data[r.index&0x3F]
+}
+
+func (r *paletteRAM) writeData(v byte) {
+	r.data[r.index&0x3F] = v
+	if r.index&0x80 != 0 {
+		r.index = 0x80 | (r.index+1)&0x3F
+	}
+}
+
+func (r *paletteRAM) color(palette, n int) uint16 {
+	i := palette*8 + n*2
+	return uint16(r.data[i+1])<<8 | uint16(r.data[i])
+}
+
+// SetCGB maps the CGB palette registers. On DMG they read 0xFF and ignore
+// writes.
+func (p *PPU) SetCGB(enabled bool) {
+	if !enabled {
+		for addr := ADDR_BCPS; addr <= ADDR_OCPD; addr++ {
+			p.mem.MapIO(addr, func() byte { return 0xFF }, func(byte) {})
+		}
+		p.cgb = false
+		return
+	}
+	p.mem.MapIO(ADDR_BCPS, p.bgPalette.readSpec, p.bgPalette.writeSpec)
+	p.mem.MapIO(ADDR_BCPD, p.bgPalette.readData, p.bgPalette.writeData)
+	p.mem.MapIO(ADDR_OCPS, p.objPalette.readSpec, p.objPalette.writeSpec)
+	p.mem.MapIO(ADDR_OCPD, p.objPalette.readData, p.objPalette.writeData)
+	p.cgb = true
+}
+
+func (p *PPU) CGB() bool {
+	return p.cgb
+}
+
+// BGColor returns colour n of CGB background palette as RGB555.
+func (p *PPU) BGColor(palette, n int) uint16 {
+	return p.bgPalette.color(palette, n)
+}
+
+// OBJColor returns colour n of CGB sprite palette as RGB555.
+func (p *PPU) OBJColor(palette, n int) uint16 {
+	return p.objPalette.color(palette, n)
+}
@@ -1,8 +1,242 @@
 package ppu
 
-import "github.com/duyquang6/go-retroid/mmu"
+import (
+	"log/slog"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+const (
+	MODE_HBLANK   byte = 0x00
+	MODE_VBLANK   byte = 0x01
+	MODE_OAM      byte = 0x02
+	MODE_TRANSFER byte = 0x03
+)
+
+const (
+	ADDR_LCDC uint16 = 0xFF40
+	ADDR_STAT uint16 = 0xFF41
+	ADDR_LY   uint16 = 0xFF44
+	ADDR_LYC  uint16 = 0xFF45
+)
+
+const (
+	SCANLINE_DOTS = 456
+	OAM_DOTS      = 80
+	TRANSFER_DOTS = 172
+	VISIBLE_LINES = 144
+	TOTAL_LINES   = 154
+
+	// the hardware limit, see SetMaxSpritesPerLine
+	MAX_SPRITES_PER_LINE = 10
+	OAM_SPRITES          = 40
+)
 
 type PPU struct {
 	// SharedMem with CPU
-	mem *mmu.Memory
+	mem    *mmu.Memory
+	logger *slog.Logger
+
+	mode byte
+	ly   byte
+	// dots elapsed in the current scanline
+	clock int
+	// STAT interrupt enable bits (3-6), and the STAT interrupt line they
+	// drive, see updateSTAT
+	statEnable byte
+	statLine   bool
+
+	// OAM indices of the sprites selected for the current line, and the
+	// next OAM entry the mode 2 scan will look at
+	lineSprites []int
+	scanIndex   int
+	maxSprites  int
+
+	onVBlank func()
+
+	frame [SCREEN_HEIGHT][SCREEN_WIDTH]byte
+	// RGB555 colours of the frame in CGB mode, where frame holds colour
+	// indices instead of shades
+	colors   [SCREEN_HEIGHT][SCREEN_WIDTH]uint16
+	lineRegs [SCREEN_HEIGHT]LineRegisters
+	onFrame  func()
+	// frames skipped out of every frameSkip+1, see SetFrameSkip
+	frameSkip int
+	// frames completed so far; skipping is set while the current frame
+	// is not rendered
+	frames   uint64
+	skipping bool
+	// window rows drawn so far this frame
+	windowLine byte
+
+	// CGB colour palettes, mapped only in CGB mode
+	cgb        bool
+	bgPalette  paletteRAM
+	objPalette paletteRAM
+
+	// tiles changed by the CPU since the last DirtyTiles
+	dirtyTiles [2 * TILES_PER_BANK]bool
+}
+
+func New(mem *mmu.Memory) *PPU {
+	p := &PPU{
+		mem:         mem,
+		logger:      slog.New(slog.DiscardHandler),
+		lineSprites: make([]int, 0, OAM_SPRITES),
+		maxSprites:  MAX_SPRITES_PER_LINE,
+	}
+	// power on: the first frame starts with the OAM scan of line 0, OAM
+	// locked like on any other line
+	p.startOAMScan()
+	mem.MapIO(ADDR_LY, p.readLY, func(byte) {})
+	mem.MapIO(ADDR_LCDC, nil, p.writeLCDC)
+	mem.OnOAMBug(p.corruptOAM)
+	mem.OnVRAMChange(p.markDirty)
+	mem.MapIO(ADDR_STAT, p.readSTAT, p.writeSTAT)
+	mem.MapIO(ADDR_LYC, nil, p.writeLYC)
+	return p
+}
+
+func (p *PPU) SetLogger(logger *slog.Logger) {
+	p.logger = logger
+}
+
+// SkipBoot positions the PPU where the DMG boot ROM leaves it: at the end
+// of the last VBlank line, a few dots before the first frame starts.
+func (p *PPU) SkipBoot() {
+	p.ly = TOTAL_LINES - 1
+	p.setMode(MODE_VBLANK)
+	p.clock = SCANLINE_DOTS - 4
+}
+
+// OnVBlank sets a callback invoked each time the PPU enters VBlank.
+func (p *PPU) OnVBlank(fn func()) {
+	p.onVBlank = fn
+}
+
+func (p *PPU) Mode() byte {
+	return p.mode
+}
+
+// Step advances the PPU by the given T-cycles (one dot each).
+func (p *PPU) Step(cycles int) {
+	for range cycles {
+		p.StepDot()
+	}
+}
+
+// StepDot advances the PPU by a single dot, so that callers can observe
+// every mode transition.
+func (p *PPU) StepDot() {
+	// the backing byte, LCDC has no read side effects and reading it
+	// through the bus would count in the access stats on every dot
+	if p.mem.ReadRaw(ADDR_LCDC)&0x80 == 0 {
+		// LCD off: the PPU idles at the start of the frame
+		if p.ly != 0 || p.clock != 0 || p.mode != MODE_HBLANK {
+			p.ly, p.clock = 0, 0
+			p.setMode(MODE_HBLANK)
+			p.windowLine = 0
+		}
+		p.statLine = false
+		return
+	}
+
+	p.clock++
+	if p.mode == MODE_OAM {
+		p.scanOAM(p.clock / 2)
+	}
+	if p.clock >= p.nextBoundary() {
+		p.updateMode()
+	}
+	p.updateSTAT()
+}
+
+func (p *PPU) nextBoundary() int {
+	switch p.mode {
+	case MODE_OAM:
+		return OAM_DOTS
+	case MODE_TRANSFER:
+		return OAM_DOTS + TRANSFER_DOTS
+	}
+	return SCANLINE_DOTS
+}
+
+func (p *PPU) updateMode() {
+	switch {
+	case p.clock >= SCANLINE_DOTS:
+		p.clock -= SCANLINE_DOTS
+		p.ly++
+		if p.ly == TOTAL_LINES {
+			p.ly = 0
+		}
+		switch {
+		case p.ly == VISIBLE_LINES:
+			p.setMode(MODE_VBLANK)
+			p.windowLine = 0
+			cpu.RequestInterrupt(p.mem, cpu.INT_VBLANK)
+			p.endFrame()
+			if p.onVBlank != nil {
+				p.onVBlank()
+			}
+		case p.ly < VISIBLE_LINES:
+			p.startOAMScan()
+		}
+	case p.mode == MODE_OAM && p.clock >= OAM_DOTS:
+		p.captureRegisters()
+		p.setMode(MODE_TRANSFER)
+	case p.mode == MODE_TRANSFER && p.clock >= OAM_DOTS+TRANSFER_DOTS:
+		if !p.skipping {
+			p.renderLine()
+		}
+		p.setMode(MODE_HBLANK)
+	}
+}
+
+// setMode switches mode, locking VRAM and OAM for the CPU while the PPU
+// reads them: OAM in mode 2, both in mode 3.
+func (p *PPU) setMode(mode byte) {
+	p.mode = mode
+	p.mem.LockOAM(mode == MODE_OAM || mode == MODE_TRANSFER)
+	p.mem.LockVRAM(mode == MODE_TRANSFER)
+}
+
+// ForceState jumps the PPU timing to the given mode, line and dot within
+// the line, with the matching memory locks. It is meant for tests that
+// need the PPU at a precise point; nothing is rendered or scanned.
+func (p *PPU) ForceState(mode byte, ly byte, clock int) {
+	p.ly, p.clock = ly, clock
+	p.setMode(mode)
+}
+
+// Line returns the scanline the PPU is on, 0-153. Unlike LY it does not
+// read 0 during most of line 153.
+func (p *PPU) Line() byte {
+	return p.ly
+}
+
+func (p *PPU) writeLCDC(value byte) {
+	lcdc := p.LCDC()
+	if lcdc&0x80 != 0 && value&0x80 == 0 && p.mode != MODE_VBLANK {
+		// can damage a real DMG screen, games only do it during VBlank
+		p.logger.Warn("LCD turned off outside VBlank", "ly", p.ly, "mode", p.mode)
+	}
+	p.mem.WriteRaw(ADDR_LCDC, value)
+}
+
+// readLY returns the LY register. On line 153 LY already reads 0 after the
+// first 4 dots.
+func (p *PPU) readLY() byte {
+	if p.ly == TOTAL_LINES-1 && p.clock >= 4 {
+		return 0
+	}
+	return p.ly
+}
+
+func (p *PPU) readSTAT() byte {
+	stat := 0x80 | p.statEnable | p.mode
+	if p.lycMatch() {
+		stat |= 0x04
+	}
+	return stat
 }
@@ -0,0 +1,589 @@
+package ppu
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+func newTestPPU() (*mmu.Memory, *PPU) {
+	mem := mmu.New()
+	mem.Write(ADDR_LCDC, 0x91)
+	return mem, New(mem)
+}
+
+func TestPPU_OAMScan(t *testing.T) {
+	mem, p := newTestPPU()
+
+	// sprites covering line 0 (Y=16) at odd indices, off-line ones between
+	for i := 0; i < 40; i++ {
+		y := byte(100)
+		if i%2 == 1 {
+			y = 16
+		}
+		if i == 4 {
+			// 8x8 sprite ending right above line 0
+			y = 8
+		}
+		mem.WriteBytes(0xFE00+uint16(i*4), []byte{y})
+	}
+
+	p.Step(40)
+	if got, want := p.LineSprites(), []int{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}; !slices.Equal(got, want) {
+		t.Errorf("after 40 dots LineSprites() = %v, want %v", got, want)
+	}
+
+	// the scan is capped at 10 sprites
+	p.Step(40)
+	if got := len(p.LineSprites()); got != MAX_SPRITES_PER_LINE {
+		t.Errorf("len(LineSprites()) = %d, want %d", got, MAX_SPRITES_PER_LINE)
+	}
+	if p.Mode() != MODE_TRANSFER {
+		t.Errorf("mode = %d after OAM scan, want %d", p.Mode(), MODE_TRANSFER)
+	}
+}
+
+func TestPPU_OAMScanTallSprites(t *testing.T) {
+	mem, p := newTestPPU()
+	mem.Write(ADDR_LCDC, 0x95) // 8x16 sprites
+	// OAM is locked from power on, the scan of line 0 runs
+	mem.WriteBytes(0xFE00, []byte{8}) // covers lines -8..7
+	mem.WriteBytes(0xFE04, []byte{1}) // covers lines -15..0
+	mem.WriteBytes(0xFE08, []byte{0}) // fully above the screen
+
+	p.Step(OAM_DOTS)
+	if got, want := p.LineSprites(), []int{0, 1}; !slices.Equal(got, want) {
+		t.Errorf("LineSprites() = %v, want %v", got, want)
+	}
+}
+
+func TestPPU_OAMCorruptionOnIncHL(t *testing.T) {
+	mem, p := newTestPPU()
+	c := cpu.New(mem)
+	c.WriteHL(0xFE00)
+	mem.Write(0x0100, 0x23) // INC HL
+
+	for i := 0; i < 0xA0; i++ {
+		mem.Write(0xFE00+uint16(i), byte(i*7+3))
+	}
+	before := append([]byte(nil), p.OAM()...)
+
+	p.Step(8) // PPU on row 2 of the OAM scan
+	p.Step(c.Step())
+
+	oam := p.OAM()
+	row, prev := before[16:24], before[8:16]
+	a := uint16(row[0]) | uint16(row[1])<<8
+	b := uint16(prev[0]) | uint16(prev[1])<<8
+	cw := uint16(prev[4]) | uint16(prev[5])<<8
+	w0 := ((a ^ cw) & (b ^ cw)) ^ cw
+
+	want := append([]byte{byte(w0), byte(w0 >> 8)}, prev[2:]...)
+	if got := oam[16:24]; !slices.Equal(got, want) {
+		t.Errorf("row 2 = % X, want % X", got, want)
+	}
+	if !slices.Equal(oam[:16], before[:16]) || !slices.Equal(oam[24:], before[24:]) {
+		t.Error("rows other than 2 were modified")
+	}
+	if c.HL() != 0xFE01 {
+		t.Errorf("HL = %04X, want FE01", c.HL())
+	}
+}
+
+func TestPPU_NoOAMCorruptionOutsideMode2(t *testing.T) {
+	mem, p := newTestPPU()
+	for i := 0; i < 0xA0; i++ {
+		mem.Write(0xFE00+uint16(i), byte(i))
+	}
+	before := append([]byte(nil), p.OAM()...)
+
+	p.Step(OAM_DOTS + 8) // mode 3
+	mem.TriggerOAMBug(0xFE40)
+
+	if !slices.Equal(p.OAM(), before) {
+		t.Error("OAM corrupted outside mode 2")
+	}
+}
+
+func TestPPU_NoOAMCorruptionOnCGB(t *testing.T) {
+	mem, p := newTestPPU()
+	mem.SetCGB(true)
+	p.SetCGB(true)
+	for i := 0; i < 0xA0; i++ {
+		mem.WriteRaw(0xFE00+uint16(i), byte(i))
+	}
+	before := append([]byte(nil), p.OAM()...)
+
+	p.Step(8) // PPU on row 2 of the OAM scan
+	mem.TriggerOAMBug(0xFE40)
+
+	if !slices.Equal(p.OAM(), before) {
+		t.Error("OAM corrupted in CGB mode")
+	}
+}
+
+func TestPPU_SpriteClipping(t *testing.T) {
+	tests := []struct {
+		x       byte
+		visible []int // screen columns covered by the sprite
+	}{
+		{0, nil},
+		{4, []int{0, 1, 2, 3}},
+		{8, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{164, []int{156, 157, 158, 159}},
+		{168, nil},
+		{255, nil},
+	}
+	for _, tc := range tests {
+		mem, p := newTestPPU()
+		mem.Write(ADDR_LCDC, 0x93) // sprites on
+		mem.Write(0xFF47, 0xE4)    // BGP
+		mem.Write(0xFF48, 0xE4)    // OBP0
+		for i := uint16(0); i < 16; i++ {
+			mem.Write(0x8010+i, 0xFF) // tile 1: colour 3
+		}
+		mem.WriteBytes(0xFE00, []byte{16, tc.x, 1, 0})
+
+		p.Step(OAM_DOTS + TRANSFER_DOTS)
+
+		for x := 0; x < SCREEN_WIDTH; x++ {
+			want := byte(0)
+			if slices.Contains(tc.visible, x) {
+				want = 3
+			}
+			if got := p.Pixel(x, 0); got != want {
+				t.Errorf("X=%d: pixel %d = %d, want %d", tc.x, x, got, want)
+			}
+		}
+	}
+}
+
+func TestPPU_MaxSpritesPerLine(t *testing.T) {
+	for _, limit := range []int{MAX_SPRITES_PER_LINE, OAM_SPRITES} {
+		mem, p := newTestPPU()
+		p.SetMaxSpritesPerLine(limit)
+		mem.Write(ADDR_LCDC, 0x93) // sprites on
+		mem.Write(0xFF48, 0xE4)    // OBP0
+		for i := uint16(0); i < 16; i++ {
+			mem.Write(0x8010+i, 0x80) // tile 1: colour 3 in its left column
+		}
+		// 40 overlapping sprites on line 0, 4 pixels apart
+		for i := range OAM_SPRITES {
+			mem.WriteBytes(0xFE00+uint16(i*4), []byte{16, byte(8 + 4*i), 1, 0})
+		}
+
+		p.Step(OAM_DOTS + TRANSFER_DOTS)
+		for i := range OAM_SPRITES {
+			want := byte(0)
+			if i < limit {
+				want = 3
+			}
+			if got := p.Pixel(4*i, 0); got != want {
+				t.Errorf("limit %d: sprite %d pixel = %d, want %d", limit, i, got, want)
+			}
+		}
+	}
+}
+
+func TestPPU_SpritePriority(t *testing.T) {
+	tests := []struct {
+		cgb  bool
+		want []byte
+	}{
+		// the sprite at the smaller X wins where they overlap, even with
+		// a higher OAM index
+		{false, []byte{2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 0}},
+		// CGB only looks at the OAM index
+		{true, []byte{2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 0}},
+	}
+	for _, tc := range tests {
+		mem, p := newTestPPU()
+		if tc.cgb {
+			mem.SetCGB(true)
+			p.SetCGB(true)
+		}
+		mem.Write(ADDR_LCDC, 0x93)
+		mem.Write(0xFF47, 0xE4)
+		mem.Write(0xFF48, 0xE4)
+		for i := uint16(0); i < 16; i += 2 {
+			mem.Write(0x8010+i, 0xFF) // tile 1: colour 1
+			mem.Write(0x8021+i, 0xFF) // tile 2: colour 2
+		}
+		mem.WriteBytes(0xFE00, []byte{16, 12, 1, 0, 16, 8, 2, 0})
+
+		p.Step(OAM_DOTS + TRANSFER_DOTS)
+		for x, want := range tc.want {
+			if got := p.Pixel(x, 0); got != want {
+				t.Errorf("CGB %v: pixel %d = %d, want %d", tc.cgb, x, got, want)
+			}
+		}
+	}
+}
+
+func TestPPU_FrameSkip(t *testing.T) {
+	mem, p := newTestPPU()
+	mem.Write(0xFF47, 0xE4) // BGP
+	p.SetFrameSkip(2)
+
+	var frames, vblanks int
+	p.OnFrame(func() { frames++ })
+	p.OnVBlank(func() { vblanks++ })
+
+	const frameDots = SCANLINE_DOTS * TOTAL_LINES
+	for range 9 {
+		p.Step(frameDots)
+	}
+	if vblanks != 9 {
+		t.Errorf("VBlank %d times, want 9", vblanks)
+	}
+	if frames != 3 {
+		t.Errorf("OnFrame %d times, want 3", frames)
+	}
+
+	// a skipped frame doesn't touch the frame buffer
+	mem.Write(0xFF47, 0xFF) // every colour black
+	p.Step(frameDots)       // frame 9 rendered
+	if got := p.Pixel(0, 0); got != 3 {
+		t.Errorf("rendered frame pixel = %d, want 3", got)
+	}
+	mem.Write(0xFF47, 0x00)
+	p.Step(frameDots) // frame 10 skipped
+	if got := p.Pixel(0, 0); got != 3 {
+		t.Errorf("skipped frame changed pixel to %d", got)
+	}
+
+	// timing is unaffected: LY still goes through every line
+	p.Step(SCANLINE_DOTS * 50)
+	if got := p.LY(); got != 50 {
+		t.Errorf("LY = %d, want 50", got)
+	}
+}
+
+func TestPPU_ForceStateAccessGating(t *testing.T) {
+	mem, p := newTestPPU()
+	mem.Write(0x8000, 0x11)
+	mem.WriteBytes(0xFE00, []byte{0x22})
+
+	tests := []struct {
+		mode              byte
+		wantVRAM, wantOAM byte // 0xFF when locked
+	}{
+		{MODE_HBLANK, 0x11, 0x22},
+		{MODE_VBLANK, 0x11, 0x22},
+		{MODE_OAM, 0x11, 0xFF},
+		{MODE_TRANSFER, 0xFF, 0xFF},
+	}
+	for _, tc := range tests {
+		p.ForceState(tc.mode, 10, 100)
+		if p.Mode() != tc.mode || p.LY() != 10 {
+			t.Errorf("ForceState(%d): mode %d LY %d", tc.mode, p.Mode(), p.LY())
+		}
+		if got := mem.Read(0x8000); got != tc.wantVRAM {
+			t.Errorf("mode %d: VRAM read = 0x%02X, want 0x%02X", tc.mode, got, tc.wantVRAM)
+		}
+		if got := mem.Read(0xFE00); got != tc.wantOAM {
+			t.Errorf("mode %d: OAM read = 0x%02X, want 0x%02X", tc.mode, got, tc.wantOAM)
+		}
+	}
+
+	// writes in mode 3 are dropped
+	p.ForceState(MODE_TRANSFER, 10, 100)
+	mem.Write(0x8000, 0x99)
+	mem.Write(0xFE00, 0x99)
+	p.ForceState(MODE_HBLANK, 10, 300)
+	if mem.Read(0x8000) != 0x11 || mem.Read(0xFE00) != 0x22 {
+		t.Error("writes during mode 3 reached VRAM or OAM")
+	}
+}
+
+func TestPPU_ScrollSampledPerLine(t *testing.T) {
+	mem, p := newTestPPU()
+	mem.Write(0xFF47, 0xE4) // BGP
+	mem.Write(0x8010, 0x80) // tile 1: column 0 has colour 3
+	mem.Write(0x8011, 0x80)
+	mem.Write(0x8012, 0x80) // row 1
+	mem.Write(0x8013, 0x80)
+	for i := uint16(0); i < 32; i++ {
+		mem.Write(0x9800+i, 1)
+	}
+
+	// a write during mode 3 waits for the next line
+	p.Step(OAM_DOTS + 10)
+	mem.Write(0xFF43, 3) // SCX
+	p.Step(SCANLINE_DOTS - OAM_DOTS - 10)
+	p.Step(OAM_DOTS + TRANSFER_DOTS)
+
+	for _, tc := range []struct{ line, x int }{{0, 0}, {1, 5}} {
+		if got := p.LineRegisters(tc.line).SCX; got != byte(tc.line*3) {
+			t.Errorf("line %d: SCX = %d, want %d", tc.line, got, tc.line*3)
+		}
+		for x := range 8 {
+			want := byte(0)
+			if x == tc.x {
+				want = 3
+			}
+			if got := p.Pixel(x, tc.line); got != want {
+				t.Errorf("line %d: pixel %d = %d, want %d", tc.line, x, got, want)
+			}
+		}
+	}
+}
+
+func TestPPU_OffscreenOAMDrawsNothing(t *testing.T) {
+	for _, lcdc := range []byte{0x92, 0x96} { // BG off, 8x8 and 8x16 sprites
+		mem, p := newTestPPU()
+		mem.Write(ADDR_LCDC, lcdc)
+		mem.Write(0xFF47, 0xE4) // BGP
+		mem.Write(0xFF48, 0xE4) // OBP0
+		for i := uint16(0); i < 32; i++ {
+			mem.Write(0x8000+i, 0xFF) // tiles 0 and 1: colour 3
+		}
+		// below the screen even at 16 pixels tall
+		mem.WriteBytes(0xFE00, []byte{160, 8, 0, 0})
+
+		for y := range VISIBLE_LINES {
+			p.Step(OAM_DOTS)
+			if got := p.LineSprites(); len(got) != 0 {
+				t.Fatalf("LCDC=%02X line %d: LineSprites() = %v, want none", lcdc, y, got)
+			}
+			p.Step(SCANLINE_DOTS - OAM_DOTS)
+		}
+		for y := range SCREEN_HEIGHT {
+			for x := range SCREEN_WIDTH {
+				if p.Pixel(x, y) != 0 || p.PixelPalette(x, y) != PALETTE_BG {
+					t.Fatalf("LCDC=%02X: sprite pixel at (%d, %d)", lcdc, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestPPU_StepDotModeTransitions(t *testing.T) {
+	_, p := newTestPPU()
+
+	want := func(dot int) byte {
+		switch {
+		case dot < OAM_DOTS:
+			return MODE_OAM
+		case dot < OAM_DOTS+TRANSFER_DOTS:
+			return MODE_TRANSFER
+		}
+		return MODE_HBLANK
+	}
+	for dot := 0; dot < SCANLINE_DOTS; dot++ {
+		if p.Mode() != want(dot) || p.Line() != 0 {
+			t.Fatalf("dot %d: line %d mode %d, want line 0 mode %d", dot, p.Line(), p.Mode(), want(dot))
+		}
+		p.StepDot()
+	}
+	if p.Mode() != MODE_OAM || p.Line() != 1 {
+		t.Errorf("after a scanline: line %d mode %d, want line 1 mode %d", p.Line(), p.Mode(), MODE_OAM)
+	}
+}
+
+func TestPPU_PowerOn(t *testing.T) {
+	mem := mmu.New()
+	mem.Write(ADDR_LCDC, 0x91)
+	mem.WriteBytes(0xFE00, []byte{16, 8, 0, 0}) // a sprite on line 0
+	p := New(mem)
+
+	if p.Mode() != MODE_OAM || p.Line() != 0 || mem.Read(0xFE00) != 0xFF {
+		t.Fatalf("at power on: line %d mode %d OAM 0x%02X, want line 0 mode %d and OAM locked",
+			p.Line(), p.Mode(), mem.Read(0xFE00), MODE_OAM)
+	}
+
+	modes := []byte{p.Mode()}
+	for range SCANLINE_DOTS {
+		p.StepDot()
+		if m := p.Mode(); m != modes[len(modes)-1] {
+			modes = append(modes, m)
+		}
+		if p.Line() == 0 && p.Mode() == MODE_TRANSFER && !slices.Equal(p.LineSprites(), []int{0}) {
+			t.Fatalf("line 0 sprites = %v, want [0]", p.LineSprites())
+		}
+	}
+	if want := []byte{MODE_OAM, MODE_TRANSFER, MODE_HBLANK, MODE_OAM}; !slices.Equal(modes, want) {
+		t.Errorf("modes = %v, want %v", modes, want)
+	}
+	if p.Line() != 1 {
+		t.Errorf("line %d after a scanline, want 1", p.Line())
+	}
+}
+
+func TestPPU_CGBTileAttributes(t *testing.T) {
+	mem, p := newTestPPU()
+	mem.SetCGB(true)
+	p.SetCGB(true)
+
+	// BG palette 3 colour 1
+	mem.Write(ADDR_BCPS, 0x80|3*8+2)
+	mem.Write(ADDR_BCPD, 0x1F)
+	mem.Write(ADDR_BCPD, 0x7C)
+
+	mem.Write(0x9800, 1)    // tile 1
+	mem.Write(0xFF4F, 0x01) // VRAM bank 1
+	mem.Write(0x8010, 0x80) // tile 1 in bank 1: only its top-left pixel, colour 1
+	mem.Write(0x9800, 0x6B) // palette 3, bank 1, X and Y flip
+	mem.Write(0xFF4F, 0x00)
+
+	if a := DecodeTileAttributes(0x6B); a != (TileAttributes{Palette: 3, Bank: 1, XFlip: true, YFlip: true}) {
+		t.Errorf("DecodeTileAttributes(0x6B) = %+v", a)
+	}
+
+	p.Step(8 * SCANLINE_DOTS)
+	for y := range 8 {
+		for x := range 8 {
+			want, wantColor := byte(0), uint16(0)
+			if x == 7 && y == 7 {
+				want, wantColor = 1, 0x7C1F
+			}
+			if got := p.Pixel(x, y); got != want {
+				t.Errorf("pixel (%d, %d) = %d, want %d", x, y, got, want)
+			}
+			if got := p.PixelColor(x, y); got != wantColor {
+				t.Errorf("colour (%d, %d) = %04X, want %04X", x, y, got, wantColor)
+			}
+		}
+	}
+}
+
+func TestPPU_DirtyTiles(t *testing.T) {
+	mem, p := newTestPPU()
+	// LCD off so VRAM is never locked
+	mem.Write(ADDR_LCDC, 0x00)
+
+	// tile 5 gets new bytes, tile 6 is rewritten with what it holds
+	mem.Write(0x8000+5*TILE_BYTES, 0xFF)
+	mem.Write(0x8000+5*TILE_BYTES+15, 0x3C)
+	mem.Write(0x8000+6*TILE_BYTES, 0x00)
+	// tile maps aren't tiles
+	mem.Write(0x9800, 0x01)
+
+	if got, want := p.DirtyTiles(), []int{5}; !slices.Equal(got, want) {
+		t.Errorf("DirtyTiles() = %v, want %v", got, want)
+	}
+	if got := p.DirtyTiles(); len(got) != 0 {
+		t.Errorf("second DirtyTiles() = %v, want none", got)
+	}
+}
+
+// countSTATInterrupts steps n dots and counts the STAT interrupts
+// requested, acknowledging each one.
+func countSTATInterrupts(mem *mmu.Memory, p *PPU, n int) int {
+	count := 0
+	for range n {
+		p.StepDot()
+		if mem.Read(cpu.ADDR_IF)&cpu.INT_LCD_STAT != 0 {
+			count++
+			mem.Write(cpu.ADDR_IF, mem.Read(cpu.ADDR_IF)&^cpu.INT_LCD_STAT)
+		}
+	}
+	return count
+}
+
+func TestPPU_STATLineEdges(t *testing.T) {
+	const frame = TOTAL_LINES * SCANLINE_DOTS
+
+	// LYC=144 matches as mode 1 starts: one rising edge, one interrupt
+	mem, p := newTestPPU()
+	p.ForceState(MODE_HBLANK, VISIBLE_LINES-1, SCANLINE_DOTS-8)
+	mem.Write(ADDR_LYC, VISIBLE_LINES)
+	mem.Write(ADDR_STAT, STAT_VBLANK|STAT_LYC)
+	if got := countSTATInterrupts(mem, p, frame); got != 1 {
+		t.Errorf("VBlank and LYC=144 sources: %d STAT interrupts per frame, want 1", got)
+	}
+
+	// HBlank runs straight into mode 2, the line stays high: only line 0's
+	// mode 2 and each HBlank raise it
+	mem, p = newTestPPU()
+	p.ForceState(MODE_VBLANK, TOTAL_LINES-1, SCANLINE_DOTS-8)
+	mem.Write(ADDR_STAT, STAT_HBLANK|STAT_OAM)
+	if got, want := countSTATInterrupts(mem, p, frame), 1+VISIBLE_LINES; got != want {
+		t.Errorf("HBlank and OAM sources: %d STAT interrupts per frame, want %d", got, want)
+	}
+}
+
+func TestPPU_LCDCBit0(t *testing.T) {
+	for _, cgb := range []bool{false, true} {
+		mem, p := newTestPPU()
+		if cgb {
+			mem.SetCGB(true)
+			p.SetCGB(true)
+		}
+		for i := uint16(0); i < 16; i++ {
+			mem.Write(0x8010+i, 0xFF) // tile 1: colour 3
+		}
+		for i := uint16(0); i < 16; i += 2 {
+			mem.Write(0x8020+i, 0xF0) // tile 2: colour 1 on its left half
+		}
+		mem.Write(0x9800, 1)
+		// sprite Y, X (screen 0-7) and tile, behind background colours 1-3
+		mem.WriteBytes(0xFE00, []byte{16, 8, 2, 0x80})
+		mem.Write(0xFF47, 0xE4) // BGP
+		mem.Write(0xFF48, 0xE4) // OBP0
+		mem.Write(ADDR_LCDC, 0x92)
+
+		p.Step(8 * SCANLINE_DOTS)
+		// the sprite covers (0, 0), (5, 0) only has the background
+		sprite, bg := p.Pixel(0, 0), p.Pixel(5, 0)
+		spritePal, bgPal := p.PixelPalette(0, 0), p.PixelPalette(5, 0)
+		if cgb {
+			// background drawn, the sprite on top of it regardless
+			if bg != 3 || bgPal != PALETTE_BG {
+				t.Errorf("CGB: background pixel = %d from palette %d, want 3 from BG", bg, bgPal)
+			}
+			if sprite != 1 || spritePal != PALETTE_OBP0 {
+				t.Errorf("CGB: sprite pixel = %d from palette %d, want 1 from OBP0", sprite, spritePal)
+			}
+			continue
+		}
+		// blank background, so nothing hides the sprite
+		if bg != 0 || bgPal != PALETTE_BG {
+			t.Errorf("DMG: background pixel = %d from palette %d, want blank", bg, bgPal)
+		}
+		if sprite != 1 || spritePal != PALETTE_OBP0 {
+			t.Errorf("DMG: sprite pixel = %d from palette %d, want 1 from OBP0", sprite, spritePal)
+		}
+	}
+}
+
+func TestPPU_OAMEntry(t *testing.T) {
+	mem, p := newTestPPU()
+	// entry 3: Y, X, tile, then priority, X flip, OBP1, bank 1, CGB palette 5
+	for i, b := range []byte{0x50, 0x28, 0x7A, 0xBD} {
+		mem.WriteRaw(0xFE0C+uint16(i), b)
+	}
+
+	e := p.Sprite(3)
+	if e.Y != 0x50 || e.X != 0x28 || e.Tile != 0x7A || e.Attributes != 0xBD {
+		t.Errorf("Sprite(3) = %+v", e)
+	}
+	if !e.Priority() || e.FlipY() || !e.FlipX() || e.Palette() != 1 || e.CGBPalette() != 5 || e.Bank() != 1 {
+		t.Errorf("Sprite(3) flags: priority %v flipY %v flipX %v palette %d CGB palette %d bank %d",
+			e.Priority(), e.FlipY(), e.FlipX(), e.Palette(), e.CGBPalette(), e.Bank())
+	}
+
+	p.SetSprite(39, OAMEntry{Y: 16, X: 8, Tile: 0x01, Attributes: 0x40})
+	if got, want := p.OAM()[39*4:], []byte{16, 8, 0x01, 0x40}; !slices.Equal(got, want) {
+		t.Errorf("OAM after SetSprite(39) = %X, want %X", got, want)
+	}
+	if !p.Sprite(39).FlipY() {
+		t.Error("Sprite(39).FlipY() = false after SetSprite")
+	}
+
+	// out of range indexes stay out of the memory past OAM
+	mem.WriteRaw(0xFEA0, 0x5A)
+	p.SetSprite(40, OAMEntry{Y: 1, X: 2, Tile: 3, Attributes: 4})
+	p.SetSprite(-1, OAMEntry{Y: 1, X: 2, Tile: 3, Attributes: 4})
+	if got := mem.RangeInclusive(0xFEA0, 0xFEA0)[0]; got != 0x5A {
+		t.Errorf("SetSprite(40) wrote 0x%02X past OAM", got)
+	}
+	if e := p.Sprite(40); e != (OAMEntry{}) {
+		t.Errorf("Sprite(40) = %+v, want a zero entry", e)
+	}
+	if c := cap(p.OAM()); c != 0xA0 {
+		t.Errorf("cap(OAM()) = %d, want 0xA0", c)
+	}
+}
@@ -0,0 +1,257 @@
+package ppu
+
+import "slices"
+
+const (
+	SCREEN_WIDTH  = 160
+	SCREEN_HEIGHT = VISIBLE_LINES
+)
+
+// palette a pixel was drawn with, kept in the frame buffer above the shade
+const (
+	PALETTE_BG byte = iota
+	PALETTE_OBP0
+	PALETTE_OBP1
+)
+
+// LineRegisters are the rendering registers as sampled when a scanline
+// enters mode 3. Writes later in the line only affect the next one.
+type LineRegisters struct {
+	LCDC, SCX, SCY, WX, WY byte
+	BGP, OBP0, OBP1        byte
+}
+
+func (p *PPU) captureRegisters() {
+	p.lineRegs[p.ly] = LineRegisters{
+		LCDC: p.LCDC(), SCX: p.SCX(), SCY: p.SCY(), WX: p.WX(), WY: p.WY(),
+		BGP: p.BGP(), OBP0: p.OBP0(), OBP1: p.OBP1(),
+	}
+}
+
+// LineRegisters returns the registers line y was last rendered with.
+func (p *PPU) LineRegisters(y int) LineRegisters {
+	return p.lineRegs[y]
+}
+
+// renderLine draws the current line into the frame buffer at the end of
+// mode 3 with the registers captured at its start: background, window,
+// then sprites.
+func (p *PPU) renderLine() {
+	regs := &p.lineRegs[p.ly]
+	vram, vram1 := p.mem.VRAMBank(0), p.mem.VRAMBank(1)
+	cgb := p.cgb && vram1 != nil
+	line := &p.frame[p.ly]
+
+	// background colour indices and CGB attributes, sprites need them for
+	// priority. LCDC bit 0 blanks background and window on DMG; on CGB
+	// they are always drawn and the bit only decides sprite priority.
+	var bg, attrs [SCREEN_WIDTH]byte
+	if cgb || regs.LCDC&0x01 != 0 {
+		p.renderBackground(regs, vram, vram1, &bg, &attrs)
+	}
+	for x, c := range bg {
+		if cgb {
+			line[x] = c
+			p.colors[p.ly][x] = p.bgPalette.color(int(attrs[x]&0x07), int(c))
+			continue
+		}
+		line[x] = (regs.BGP >> (c * 2)) & 0x03
+	}
+
+	if regs.LCDC&0x02 != 0 {
+		p.renderSprites(regs, vram, vram1, &bg, &attrs, line)
+	}
+}
+
+func (p *PPU) renderBackground(regs *LineRegisters, vram, vram1 []byte, bg, attrs *[SCREEN_WIDTH]byte) {
+	lcdc, scx, scy := regs.LCDC, regs.SCX, regs.SCY
+	bgMap := 0x1800
+	if lcdc&0x08 != 0 {
+		bgMap = 0x1C00
+	}
+	y := p.ly + scy
+	for x := range SCREEN_WIDTH {
+		px := byte(x) + scx
+		bg[x], attrs[x] = p.mapPixel(lcdc, vram, vram1, bgMap+int(y/8)*32+int(px/8), px%8, y%8)
+	}
+
+	// the window needs WY reached this frame and WX-7 on screen
+	wx := int(regs.WX) - 7
+	if lcdc&0x20 == 0 || p.ly < regs.WY || wx >= SCREEN_WIDTH {
+		return
+	}
+	winMap := 0x1800
+	if lcdc&0x40 != 0 {
+		winMap = 0x1C00
+	}
+	wy := p.windowLine
+	for x := max(wx, 0); x < SCREEN_WIDTH; x++ {
+		px := byte(x - wx)
+		bg[x], attrs[x] = p.mapPixel(lcdc, vram, vram1, winMap+int(wy/8)*32+int(px/8), px%8, wy%8)
+	}
+	p.windowLine++
+}
+
+// mapPixel returns the colour index of pixel (x, y) of the tile at a
+// background map offset, and the tile's CGB attributes. Without VRAM bank
+// 1 there are no attributes.
+func (p *PPU) mapPixel(lcdc byte, vram, vram1 []byte, offset int, x, y byte) (byte, byte) {
+	tile := vram[offset]
+	if !p.cgb || vram1 == nil {
+		return tilePixel(lcdc, vram, tile, x, y), 0
+	}
+	attr := vram1[offset]
+	a := DecodeTileAttributes(attr)
+	if a.XFlip {
+		x = 7 - x
+	}
+	if a.YFlip {
+		y = 7 - y
+	}
+	if a.Bank == 1 {
+		vram = vram1
+	}
+	return tilePixel(lcdc, vram, tile, x, y), attr
+}
+
+// tilePixel returns the colour index of pixel (x, y) of a background tile,
+// addressed from 0x8000 or signed from 0x9000 depending on LCDC bit 4.
+func tilePixel(lcdc byte, vram []byte, tile, x, y byte) byte {
+	addr := int(tile) * 16
+	if lcdc&0x10 == 0 {
+		addr = 0x1000 + int(int8(tile))*16
+	}
+	return pixel(vram[addr+int(y)*2], vram[addr+int(y)*2+1], x)
+}
+
+func pixel(low, high, x byte) byte {
+	bit := 7 - x
+	return (high>>bit&0x01)<<1 | low>>bit&0x01
+}
+
+// renderSprites draws the sprites selected by the OAM scan. Sprites with a
+// smaller X win, then the lower OAM index. X is offset by 8: sprites at
+// X=0 or X>=168 are fully off-screen, those in between are clipped at the
+// screen edges.
+//
+// In CGB mode the lower OAM index alone wins, the tile bank and palette
+// come from attribute bits 3 and 0-2, and background tiles with the
+// priority attribute cover sprites, unless LCDC bit 0 is clear: then
+// sprites are always on top.
+func (p *PPU) renderSprites(regs *LineRegisters, vram, vram1 []byte, bg, attrs *[SCREEN_WIDTH]byte, line *[SCREEN_WIDTH]byte) {
+	cgb := p.cgb && vram1 != nil
+	oam := p.OAM()
+	height := byte(8)
+	if regs.LCDC&0x04 != 0 {
+		height = 16
+	}
+
+	// the OAM scan selects in index order
+	sprites := p.lineSprites
+	if !cgb {
+		sprites = slices.Clone(sprites)
+		slices.SortStableFunc(sprites, func(a, b int) int {
+			return int(oam[a*4+1]) - int(oam[b*4+1])
+		})
+	}
+
+	bgPriority := !cgb || regs.LCDC&0x01 != 0
+
+	var drawn [SCREEN_WIDTH]bool
+	for _, i := range sprites {
+		y, x, tile, attr := oam[i*4], oam[i*4+1], oam[i*4+2], oam[i*4+3]
+		row := p.ly + 16 - y
+		if attr&0x40 != 0 {
+			row = height - 1 - row
+		}
+		if height == 16 {
+			tile &= 0xFE
+		}
+		addr := int(tile)*16 + int(row)*2
+		bank := vram
+		if cgb && attr&0x08 != 0 {
+			bank = vram1
+		}
+		low, high := bank[addr], bank[addr+1]
+
+		palette, source := regs.OBP0, PALETTE_OBP0
+		if attr&0x10 != 0 {
+			palette, source = regs.OBP1, PALETTE_OBP1
+		}
+		for col := range byte(8) {
+			sx := int(x) - 8 + int(col)
+			if sx < 0 || sx >= SCREEN_WIDTH || drawn[sx] {
+				continue
+			}
+			px := col
+			if attr&0x20 != 0 {
+				px = 7 - col
+			}
+			c := pixel(low, high, px)
+			if c == 0 {
+				continue
+			}
+			drawn[sx] = true
+			if bgPriority && (attr&0x80 != 0 || attrs[sx]&0x80 != 0) && bg[sx] != 0 {
+				continue
+			}
+			if cgb {
+				line[sx] = source<<2 | c
+				p.colors[p.ly][sx] = p.objPalette.color(int(attr&0x07), int(c))
+				continue
+			}
+			line[sx] = source<<2 | (palette>>(c*2))&0x03
+		}
+	}
+}
+
+// Pixel returns the shade (0 white to 3 black) of a pixel of the last
+// rendered frame. In CGB mode it is the colour index within the pixel's
+// palette, see PixelColor.
+func (p *PPU) Pixel(x, y int) byte {
+	return p.frame[y][x] & 0x03
+}
+
+// PixelColor returns the RGB555 colour of a pixel of the last frame
+// rendered in CGB mode.
+func (p *PPU) PixelColor(x, y int) uint16 {
+	return p.colors[y][x]
+}
+
+// PixelPalette returns which palette a pixel of the last rendered frame
+// went through: PALETTE_BG, PALETTE_OBP0 or PALETTE_OBP1.
+func (p *PPU) PixelPalette(x, y int) byte {
+	return p.frame[y][x] >> 2
+}
+
+// Frame returns a copy of the frame buffer, row by row, one shade per
+// pixel.
+func (p *PPU) Frame() []byte {
+	frame := make([]byte, 0, SCREEN_WIDTH*SCREEN_HEIGHT)
+	for _, row := range p.frame {
+		for _, px := range row {
+			frame = append(frame, px&0x03)
+		}
+	}
+	return frame
+}
+
+// SetFrameSkip skips rendering n out of every n+1 frames, keeping the
+// timing untouched. 0 renders every frame.
+func (p *PPU) SetFrameSkip(n int) {
+	p.frameSkip = max(n, 0)
+}
+
+// OnFrame sets a callback invoked at VBlank after each rendered frame.
+// Skipped frames don't invoke it.
+func (p *PPU) OnFrame(fn func()) {
+	p.onFrame = fn
+}
+
+func (p *PPU) endFrame() {
+	if !p.skipping && p.onFrame != nil {
+		p.onFrame()
+	}
+	p.frames++
+	p.skipping = p.frameSkip > 0 && p.frames%uint64(p.frameSkip+1) != 0
+}
@@ -0,0 +1,55 @@
+package ppu
+
+import "github.com/duyquang6/go-retroid/cpu"
+
+// STAT interrupt sources, enabled by STAT bits 3-6
+const (
+	STAT_HBLANK byte = 0x08
+	STAT_VBLANK byte = 0x10
+	STAT_OAM    byte = 0x20
+	STAT_LYC    byte = 0x40
+)
+
+// lycMatch reports whether LY equals LYC.
+func (p *PPU) lycMatch() bool {
+	return p.readLY() == p.mem.ReadRaw(ADDR_LYC)
+}
+
+// statSources returns the enabled STAT sources whose condition holds.
+func (p *PPU) statSources() byte {
+	var active byte
+	switch p.mode {
+	case MODE_HBLANK:
+		active |= STAT_HBLANK
+	case MODE_VBLANK:
+		active |= STAT_VBLANK
+	case MODE_OAM:
+		active |= STAT_OAM
+	}
+	if p.lycMatch() {
+		active |= STAT_LYC
+	}
+	return active & p.statEnable
+}
+
+// updateSTAT recomputes the STAT interrupt line, the OR of all enabled
+// sources, and requests the interrupt only when it rises. While one source
+// holds the line high, others becoming true don't interrupt again: LYC
+// matching as VBlank starts, or HBlank running into mode 2, fire once.
+func (p *PPU) updateSTAT() {
+	line := p.LCDC()&0x80 != 0 && p.statSources() != 0
+	if line && !p.statLine {
+		cpu.RequestInterrupt(p.mem, cpu.INT_LCD_STAT)
+	}
+	p.statLine = line
+}
+
+func (p *PPU) writeSTAT(v byte) {
+	p.statEnable = v & 0x78
+	p.updateSTAT()
+}
+
+func (p *PPU) writeLYC(v byte) {
+	p.mem.WriteRaw(ADDR_LYC, v)
+	p.updateSTAT()
+}
@@ -0,0 +1,62 @@
+package ppu
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// state is the part of the PPU a save state holds. Palette mapping follows
+// SetCGB, which the owner restores before loading.
+type state struct {
+	Mode, LY   byte
+	Clock      int
+	StatEnable byte
+	StatLine   bool
+
+	LineSprites []int
+	ScanIndex   int
+
+	Frame      [SCREEN_HEIGHT][SCREEN_WIDTH]byte
+	Colors     [SCREEN_HEIGHT][SCREEN_WIDTH]uint16
+	LineRegs   [SCREEN_HEIGHT]LineRegisters
+	Frames     uint64
+	Skipping   bool
+	WindowLine byte
+
+	BGPalette, OBJPalette       [64]byte
+	BGPaletteIdx, OBJPaletteIdx byte
+}
+
+func (p *PPU) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state{
+		Mode: p.mode, LY: p.ly, Clock: p.clock, StatEnable: p.statEnable,
+		StatLine:    p.statLine,
+		LineSprites: p.lineSprites, ScanIndex: p.scanIndex,
+		Frame: p.frame, Colors: p.colors, LineRegs: p.lineRegs, Frames: p.frames, Skipping: p.skipping,
+		WindowLine: p.windowLine,
+		BGPalette:  p.bgPalette.data, OBJPalette: p.objPalette.data,
+		BGPaletteIdx: p.bgPalette.index, OBJPaletteIdx: p.objPalette.index,
+	})
+	return buf.Bytes(), err
+}
+
+func (p *PPU) UnmarshalBinary(data []byte) error {
+	var s state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	p.ly, p.clock, p.statEnable, p.statLine = s.LY, s.Clock, s.StatEnable, s.StatLine
+	p.setMode(s.Mode)
+	p.lineSprites = append(p.lineSprites[:0], s.LineSprites...)
+	p.scanIndex = s.ScanIndex
+	p.frame, p.colors = s.Frame, s.Colors
+	p.lineRegs, p.frames, p.skipping = s.LineRegs, s.Frames, s.Skipping
+	p.windowLine = s.WindowLine
+	p.bgPalette.data, p.objPalette.data = s.BGPalette, s.OBJPalette
+	p.bgPalette.index, p.objPalette.index = s.BGPaletteIdx, s.OBJPaletteIdx
+	for i := range p.dirtyTiles {
+		p.dirtyTiles[i] = true
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package ppu
+
+// TILEMAP_SIZE is the width and height in pixels of a background map.
+const TILEMAP_SIZE = 256
+
+// TilemapPixel returns the colour index of pixel (x, y) of background map
+// mapSelect (0 at 0x9800, 1 at 0x9C00) drawn with the current tile data
+// and addressing mode, along with the tile's CGB attributes.
+func (p *PPU) TilemapPixel(mapSelect, x, y int) (byte, byte) {
+	base := 0x1800
+	if mapSelect != 0 {
+		base = 0x1C00
+	}
+	offset := base + y/8*32 + x/8
+	return p.mapPixel(p.LCDC(), p.mem.VRAMBank(0), p.mem.VRAMBank(1), offset, byte(x%8), byte(y%8))
+}
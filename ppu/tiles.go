@@ -0,0 +1,32 @@
+package ppu
+
+const (
+	// tiles in the 0x8000-0x97FF tile data area of one VRAM bank
+	TILES_PER_BANK = 384
+	TILE_BYTES     = 16
+)
+
+// markDirty records the tile a changed VRAM byte belongs to. Tiles of
+// VRAM bank 1 follow those of bank 0.
+func (p *PPU) markDirty(bank int, offset uint16) {
+	tile := int(offset) / TILE_BYTES
+	if tile >= TILES_PER_BANK {
+		// tile maps
+		return
+	}
+	p.dirtyTiles[bank*TILES_PER_BANK+tile] = true
+}
+
+// DirtyTiles returns in ascending order the indices of the tiles whose
+// bytes the CPU changed since the last call, 384-767 being VRAM bank 1,
+// and clears them. All tiles are dirty after loading a save state.
+func (p *PPU) DirtyTiles() []int {
+	var tiles []int
+	for i, dirty := range p.dirtyTiles {
+		if dirty {
+			tiles = append(tiles, i)
+		}
+	}
+	p.dirtyTiles = [2 * TILES_PER_BANK]bool{}
+	return tiles
+}
@@ -0,0 +1,117 @@
+package serial
+
+import (
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+const (
+	ADDR_SB uint16 = 0xFF01
+	ADDR_SC uint16 = 0xFF02
+
+	SC_TRANSFER byte = 0x80
+	// set when this side drives the shift clock
+	SC_INTERNAL_CLOCK byte = 0x01
+
+	// the internal clock shifts one bit at 8192Hz
+	BIT_CYCLES = 512
+)
+
+// Peer is the other end of the link cable. Exchange shifts out the local
+// byte and returns the byte shifted in; ok is false when the peer isn't
+// ready to transfer (no external-clock transfer pending on its side).
+type Peer interface {
+	Exchange(out byte) (in byte, ok bool)
+}
+
+type Serial struct {
+	mem  *mmu.Memory
+	peer Peer
+
+	sb, sc byte
+
+	onTransfer func(out byte)
+
+	// internal-clock transfer in progress: the byte received from the
+	// peer, shifted into SB one bit every BIT_CYCLES
+	shifting bool
+	out, in  byte
+	bits     int
+	clock    int
+}
+
+func New(mem *mmu.Memory) *Serial {
+	s := &Serial{mem: mem, sc: 0x7E}
+	mem.MapIO(ADDR_SB, func() byte { return s.sb }, func(v byte) { s.sb = v })
+	mem.MapIO(ADDR_SC, func() byte { return s.sc | 0x7E }, s.writeSC)
+	return s
+}
+
+func (s *Serial) Connect(peer Peer) {
+	s.peer = peer
+}
+
+func (s *Serial) writeSC(value byte) {
+	s.sc = value
+	s.shifting = false
+	if value&(SC_TRANSFER|SC_INTERNAL_CLOCK) != SC_TRANSFER|SC_INTERNAL_CLOCK {
+		// external clock: wait for the peer to drive the transfer
+		return
+	}
+
+	// with nothing on the other end the line floats high
+	in := byte(0xFF)
+	if s.peer != nil {
+		if v, ok := s.peer.Exchange(s.sb); ok {
+			in = v
+		}
+	}
+	s.shifting = true
+	s.out, s.in, s.bits, s.clock = s.sb, in, 0, 0
+}
+
+// Step shifts the bits of an internal-clock transfer, completing it after
+// 8 bits.
+func (s *Serial) Step(cycles int) {
+	if !s.shifting {
+		return
+	}
+	s.clock += cycles
+	for s.clock >= BIT_CYCLES {
+		s.clock -= BIT_CYCLES
+		s.sb = s.sb<<1 | s.in>>7
+		s.in <<= 1
+		s.bits++
+		if s.bits == 8 {
+			s.shifting = false
+			s.complete(s.out, s.sb)
+			return
+		}
+	}
+}
+
+// Exchange is called by a peer driving the clock. It only succeeds while
+// an external-clock transfer is pending on this side.
+func (s *Serial) Exchange(out byte) (byte, bool) {
+	if s.sc&(SC_TRANSFER|SC_INTERNAL_CLOCK) != SC_TRANSFER {
+		return 0xFF, false
+	}
+	in := s.sb
+	s.complete(in, out)
+	return in, true
+}
+
+func (s *Serial) complete(out, in byte) {
+	s.sb = in
+	s.sc &^= SC_TRANSFER
+	cpu.RequestInterrupt(s.mem, cpu.INT_SERIAL)
+	if s.onTransfer != nil {
+		s.onTransfer(out)
+	}
+}
+
+// OnTransfer sets a callback invoked with the byte sent each time a
+// transfer completes.
+func (s *Serial) OnTransfer(fn func(out byte)) {
+	s.onTransfer = fn
+}
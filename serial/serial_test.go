@@ -0,0 +1,49 @@
+package serial
+
+import (
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+type fixedPeer byte
+
+func (p fixedPeer) Exchange(byte) (byte, bool) { return byte(p), true }
+
+func TestSerial_InternalClockTiming(t *testing.T) {
+	mem := mmu.New()
+	s := New(mem)
+	s.Connect(fixedPeer(0xA5))
+
+	mem.Write(ADDR_SB, 0x00)
+	mem.Write(ADDR_SC, SC_TRANSFER|SC_INTERNAL_CLOCK)
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_SERIAL != 0 {
+		t.Fatal("serial interrupt requested as soon as the transfer started")
+	}
+
+	// four bits in: the high nibble of the received byte
+	s.Step(4 * BIT_CYCLES)
+	if got := mem.Read(ADDR_SB); got != 0x0A {
+		t.Errorf("SB after 4 bits = 0x%02X, want 0x0A", got)
+	}
+
+	s.Step(4*BIT_CYCLES - 1)
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_SERIAL != 0 {
+		t.Fatal("serial interrupt requested before the 8th bit")
+	}
+	if mem.Read(ADDR_SC)&SC_TRANSFER == 0 {
+		t.Error("SC transfer bit cleared before the 8th bit")
+	}
+
+	s.Step(1)
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_SERIAL == 0 {
+		t.Error("no serial interrupt after 8 bits")
+	}
+	if got := mem.Read(ADDR_SB); got != 0xA5 {
+		t.Errorf("SB = 0x%02X, want 0xA5", got)
+	}
+	if mem.Read(ADDR_SC)&SC_TRANSFER != 0 {
+		t.Error("SC transfer bit still set")
+	}
+}
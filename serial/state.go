@@ -0,0 +1,34 @@
+package serial
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// state is the part of the serial port a save state holds. The peer stays
+// connected.
+type state struct {
+	SB, SC   byte
+	Shifting bool
+	Out, In  byte
+	Bits     int
+	Clock    int
+}
+
+func (s *Serial) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state{
+		SB: s.sb, SC: s.sc, Shifting: s.shifting, Out: s.out, In: s.in, Bits: s.bits, Clock: s.clock,
+	})
+	return buf.Bytes(), err
+}
+
+func (s *Serial) UnmarshalBinary(data []byte) error {
+	var st state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&st); err != nil {
+		return err
+	}
+	s.sb, s.sc, s.shifting = st.SB, st.SC, st.Shifting
+	s.out, s.in, s.bits, s.clock = st.Out, st.In, st.Bits, st.Clock
+	return nil
+}
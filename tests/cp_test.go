@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cpu"
+)
+
+// CP is SUB discarding the result: both must set the same flags for every
+// pair of operands, through registers and immediates.
+func TestCP_MatchesSUB(t *testing.T) {
+	pairs := []struct {
+		name    string
+		sub, cp []byte
+	}{
+		{"B", []byte{0x90}, []byte{0xB8}},
+		{"d8", []byte{0xD6, 0x00}, []byte{0xFE, 0x00}},
+	}
+	for _, p := range pairs {
+		for a := 0; a < 0x100; a++ {
+			for b := 0; b < 0x100; b++ {
+				regs := cpu.Registers{A: byte(a), B: byte(b), F: 0xF0, PC: 0xC000}
+
+				run := func(code []byte) cpu.Registers {
+					ram := &flatRAM{}
+					copy(ram[0xC000:], code)
+					if len(code) == 2 {
+						ram[0xC001] = byte(b)
+					}
+					out, _ := cpu.ExecuteOnce(regs, ram)
+					return out
+				}
+				sub, cp := run(p.sub), run(p.cp)
+				if sub.F != cp.F {
+					t.Fatalf("%s: A=%02X operand=%02X: CP F=%02X, SUB F=%02X", p.name, a, b, cp.F, sub.F)
+				}
+				if cp.A != byte(a) {
+					t.Fatalf("%s: CP changed A from %02X to %02X", p.name, a, cp.A)
+				}
+			}
+		}
+	}
+}
+
+func TestCP_Vectors(t *testing.T) {
+	tests := []SM83Test{
+		{
+			Name:    "B8 CP B equal",
+			Initial: State{PC: 0x0100, A: 0x3C, B: 0x3C, F: 0x00, Ram: [][2]uint16{{0x0100, 0xB8}}},
+			Final:   State{PC: 0x0101, A: 0x3C, B: 0x3C, F: 0xC0},
+		},
+		{
+			Name:    "BF CP A",
+			Initial: State{PC: 0x0100, A: 0x00, F: 0x30, Ram: [][2]uint16{{0x0100, 0xBF}}},
+			Final:   State{PC: 0x0101, A: 0x00, F: 0xC0},
+		},
+		{
+			Name:    "FE CP d8 half borrow and borrow",
+			Initial: State{PC: 0x0100, A: 0x10, F: 0x00, Ram: [][2]uint16{{0x0100, 0xFE}, {0x0101, 0x21}}},
+			Final:   State{PC: 0x0102, A: 0x10, F: 0x70},
+		},
+	}
+	for _, tc := range tests {
+		runVector(t, tc)
+	}
+}
@@ -0,0 +1,54 @@
+package tests
+
+import "testing"
+
+func TestLD_HLIncDec(t *testing.T) {
+	tests := []SM83Test{
+		{
+			Name:    "2A LD A,(HL+)",
+			Initial: State{PC: 0x0100, F: 0xB0, H: 0xC0, L: 0x00, Ram: [][2]uint16{{0x0100, 0x2A}, {0xC000, 0x42}}},
+			Final:   State{PC: 0x0101, F: 0xB0, A: 0x42, H: 0xC0, L: 0x01},
+		},
+		{
+			Name:    "2A LD A,(HL+) wraps HL=FFFF",
+			Initial: State{PC: 0x0100, F: 0x50, H: 0xFF, L: 0xFF, Ram: [][2]uint16{{0x0100, 0x2A}, {0xFFFF, 0x17}}},
+			Final:   State{PC: 0x0101, F: 0x50, A: 0x17, H: 0x00, L: 0x00},
+		},
+		{
+			Name:    "3A LD A,(HL-)",
+			Initial: State{PC: 0x0100, F: 0x00, H: 0xC0, L: 0x00, Ram: [][2]uint16{{0x0100, 0x3A}, {0xC000, 0x99}}},
+			Final:   State{PC: 0x0101, F: 0x00, A: 0x99, H: 0xBF, L: 0xFF},
+		},
+		{
+			Name:    "3A LD A,(HL-) wraps HL=0000",
+			Initial: State{PC: 0x0100, F: 0xF0, H: 0x00, L: 0x00, Ram: [][2]uint16{{0x0100, 0x3A}, {0x0000, 0x00}}},
+			Final:   State{PC: 0x0101, F: 0xF0, A: 0x00, H: 0xFF, L: 0xFF},
+		},
+		{
+			Name:    "22 LD (HL+),A",
+			Initial: State{PC: 0x0100, A: 0x5A, F: 0x20, H: 0xC1, L: 0xFF, Ram: [][2]uint16{{0x0100, 0x22}}},
+			Final:   State{PC: 0x0101, A: 0x5A, F: 0x20, H: 0xC2, L: 0x00, Ram: [][2]uint16{{0xC1FF, 0x5A}}},
+		},
+		{
+			Name:    "22 LD (HL+),A wraps HL=FFFF",
+			Initial: State{PC: 0x0100, A: 0x01, F: 0x80, H: 0xFF, L: 0xFF, Ram: [][2]uint16{{0x0100, 0x22}}},
+			Final:   State{PC: 0x0101, A: 0x01, F: 0x80, H: 0x00, L: 0x00, Ram: [][2]uint16{{0xFFFF, 0x01}}},
+		},
+		{
+			Name:    "32 LD (HL-),A",
+			Initial: State{PC: 0x0100, A: 0xA5, F: 0x10, H: 0xC1, L: 0x00, Ram: [][2]uint16{{0x0100, 0x32}}},
+			Final:   State{PC: 0x0101, A: 0xA5, F: 0x10, H: 0xC0, L: 0xFF, Ram: [][2]uint16{{0xC100, 0xA5}}},
+		},
+		{
+			Name:    "32 LD (HL-),A wraps HL=0000",
+			Initial: State{PC: 0x0100, A: 0x3C, F: 0xF0, H: 0x00, L: 0x00, Ram: [][2]uint16{{0x0100, 0x32}}},
+			Final:   State{PC: 0x0101, A: 0x3C, F: 0xF0, H: 0xFF, L: 0xFF, Ram: [][2]uint16{{0x0000, 0x3C}}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runVector(t, tc)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/gbc"
+)
+
+// mooneye test ROMs finish by loading a register fingerprint and executing
+// LD B,B as a software breakpoint
+const (
+	MOONEYE_BREAKPOINT  = 0x40
+	MOONEYE_MAX_SECONDS = 30
+)
+
+var errMooneyeTimeout = errors.New("no LD B,B breakpoint reached")
+
+// runMooneye runs rom headless until it hits LD B,B and reports whether
+// the registers hold the pass fingerprint: the Fibonacci numbers 3, 5, 8,
+// 13, 21, 34 in B, C, D, E, H, L. A failing test loads 0x42 everywhere.
+func runMooneye(rom []byte) (bool, error) {
+	gb := gbc.NewGameBoy()
+	if err := gb.LoadROM(rom); err != nil {
+		return false, err
+	}
+
+	for gb.Cycles() < MOONEYE_MAX_SECONDS*gbc.CLOCK_HZ {
+		c := gb.CPU()
+		if gb.Peek(c.PC) == MOONEYE_BREAKPOINT {
+			r := c.Registers()
+			return r == cpu.Registers{
+				A: r.A, F: r.F, PC: r.PC, SP: r.SP, IME: r.IME,
+				B: 3, C: 5, D: 8, E: 13, H: 21, L: 34,
+			}, nil
+		}
+		gb.Step()
+	}
+	return false, errMooneyeTimeout
+}
+
+func TestMooneye(t *testing.T) {
+	files, err := filepath.Glob("testdata/mooneye/*.gb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			rom, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			passed, err := runMooneye(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !passed {
+				t.Error("fail fingerprint")
+			}
+		})
+	}
+}
+
+// mooneyeFixture builds a ROM ending like a mooneye test, loading the
+// given register values before the LD B,B breakpoint.
+func mooneyeFixture(b, c, d, e, h, l byte) []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], []byte{
+		0x00,             // NOP
+		0xC3, 0x50, 0x01, // JP 0x0150
+	})
+	copy(rom[0x0150:], []byte{
+		0x06, b, // LD B,b
+		0x0E, c, // LD C,c
+		0x16, d, // LD D,d
+		0x1E, e, // LD E,e
+		0x26, h, // LD H,h
+		0x2E, l, // LD L,l
+		0x40,       // LD B,B
+		0x18, 0xFE, // JR -2
+	})
+	return rom
+}
+
+func TestMooneyeFingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		rom  []byte
+		pass bool
+	}{
+		{"pass", mooneyeFixture(3, 5, 8, 13, 21, 34), true},
+		{"fail", mooneyeFixture(0x42, 0x42, 0x42, 0x42, 0x42, 0x42), false},
+	}
+	for _, tc := range tests {
+		passed, err := runMooneye(tc.rom)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if passed != tc.pass {
+			t.Errorf("%s: passed = %v, want %v", tc.name, passed, tc.pass)
+		}
+	}
+
+	never := make([]byte, 0x8000)
+	copy(never[0x0100:], []byte{0x18, 0xFE}) // JR -2
+	if _, err := runMooneye(never); !errors.Is(err, errMooneyeTimeout) {
+		t.Errorf("ROM without breakpoint: err = %v, want %v", err, errMooneyeTimeout)
+	}
+}
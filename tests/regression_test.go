@@ -0,0 +1,72 @@
+package tests
+
+import "testing"
+
+// vectors of CPU bugs fixed in the past, kept so they don't come back
+func TestRegression_Vectors(t *testing.T) {
+	tests := []SM83Test{
+		{
+			Name:    "1B DEC DE leaves BC alone",
+			Initial: State{PC: 0x0100, B: 0x11, C: 0x22, D: 0x12, E: 0x00, Ram: [][2]uint16{{0x0100, 0x1B}}},
+			Final:   State{PC: 0x0101, B: 0x11, C: 0x22, D: 0x11, E: 0xFF},
+		},
+		{
+			Name:    "CD CALL pushes the next instruction over its operand",
+			Initial: State{PC: 0xC000, SP: 0xC003, Ram: [][2]uint16{{0xC000, 0xCD}, {0xC001, 0x34}, {0xC002, 0x12}}},
+			Final:   State{PC: 0x1234, SP: 0xC001, Ram: [][2]uint16{{0xC001, 0x03}, {0xC002, 0xC0}}},
+		},
+		{
+			Name:    "08 LD (a16),SP writes at the operand address",
+			Initial: State{PC: 0x0100, SP: 0xBEEF, Ram: [][2]uint16{{0x0100, 0x08}, {0x0101, 0x00}, {0x0102, 0xC1}}},
+			Final:   State{PC: 0x0103, SP: 0xBEEF, Ram: [][2]uint16{{0x0101, 0x00}, {0x0102, 0xC1}, {0xC100, 0xEF}, {0xC101, 0xBE}}},
+		},
+		{
+			Name:    "09 ADD HL,BC half carry out of bit 11",
+			Initial: State{PC: 0x0100, F: 0x80, B: 0x00, C: 0x01, H: 0x0F, L: 0xFF, Ram: [][2]uint16{{0x0100, 0x09}}},
+			Final:   State{PC: 0x0101, F: 0xA0, B: 0x00, C: 0x01, H: 0x10, L: 0x00},
+		},
+		{
+			Name:    "09 ADD HL,BC no half carry out of bit 7",
+			Initial: State{PC: 0x0100, F: 0x00, B: 0x00, C: 0x01, H: 0x00, L: 0xFF, Ram: [][2]uint16{{0x0100, 0x09}}},
+			Final:   State{PC: 0x0101, F: 0x00, B: 0x00, C: 0x01, H: 0x01, L: 0x00},
+		},
+		{
+			Name:    "27 DAA judges the carry on the original A",
+			Initial: State{PC: 0x0100, A: 0x94, F: 0x20, Ram: [][2]uint16{{0x0100, 0x27}}},
+			Final:   State{PC: 0x0101, A: 0x9A, F: 0x00},
+		},
+		{
+			Name:    "27 DAA after SUB with half borrow",
+			Initial: State{PC: 0x0100, A: 0x0F, F: 0x60, Ram: [][2]uint16{{0x0100, 0x27}}},
+			Final:   State{PC: 0x0101, A: 0x09, F: 0x40},
+		},
+		{
+			Name:    "20 JR NZ not taken skips its offset",
+			Initial: State{PC: 0x0100, F: 0x80, Ram: [][2]uint16{{0x0100, 0x20}, {0x0101, 0xFD}}},
+			Final:   State{PC: 0x0102, F: 0x80},
+		},
+		{
+			Name:    "30 JR NC taken without carry",
+			Initial: State{PC: 0x0100, F: 0x00, Ram: [][2]uint16{{0x0100, 0x30}, {0x0101, 0x10}}},
+			Final:   State{PC: 0x0112, F: 0x00},
+		},
+		{
+			Name:    "30 JR NC not taken on carry",
+			Initial: State{PC: 0x0100, F: 0x10, Ram: [][2]uint16{{0x0100, 0x30}, {0x0101, 0x10}}},
+			Final:   State{PC: 0x0102, F: 0x10},
+		},
+		{
+			Name:    "C2 JP NZ not taken skips its operand",
+			Initial: State{PC: 0x0100, F: 0x80, Ram: [][2]uint16{{0x0100, 0xC2}, {0x0101, 0x00}, {0x0102, 0xC0}}},
+			Final:   State{PC: 0x0103, F: 0x80},
+		},
+		{
+			Name:    "35 DEC (HL) clears a stale zero flag",
+			Initial: State{PC: 0x0100, F: 0xA0, H: 0xC0, L: 0x00, Ram: [][2]uint16{{0x0100, 0x35}, {0xC000, 0x12}}},
+			Final:   State{PC: 0x0101, F: 0x40, H: 0xC0, L: 0x00, Ram: [][2]uint16{{0xC000, 0x11}}},
+		},
+	}
+	for _, tc := range tests {
+		runVector(t, tc)
+	}
+}
@@ -0,0 +1,54 @@
+package tests
+
+import "testing"
+
+// RLCA, RRCA, RLA and RRA always clear Z, even for a zero result, while
+// their CB-prefixed counterparts on A set it.
+func TestRotateA_ZeroFlag(t *testing.T) {
+	tests := []SM83Test{
+		{
+			Name:    "07 RLCA zero result",
+			Initial: State{PC: 0x0100, A: 0x00, F: 0xF0, Ram: [][2]uint16{{0x0100, 0x07}}},
+			Final:   State{PC: 0x0101, A: 0x00, F: 0x00},
+		},
+		{
+			Name:    "0F RRCA zero result",
+			Initial: State{PC: 0x0100, A: 0x00, F: 0x80, Ram: [][2]uint16{{0x0100, 0x0F}}},
+			Final:   State{PC: 0x0101, A: 0x00, F: 0x00},
+		},
+		{
+			Name:    "17 RLA zero result",
+			Initial: State{PC: 0x0100, A: 0x80, F: 0x00, Ram: [][2]uint16{{0x0100, 0x17}}},
+			Final:   State{PC: 0x0101, A: 0x00, F: 0x10},
+		},
+		{
+			Name:    "1F RRA zero result",
+			Initial: State{PC: 0x0100, A: 0x01, F: 0xE0, Ram: [][2]uint16{{0x0100, 0x1F}}},
+			Final:   State{PC: 0x0101, A: 0x00, F: 0x10},
+		},
+		{
+			Name:    "CB 07 RLC A zero result",
+			Initial: State{PC: 0x0100, A: 0x00, F: 0x00, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x07}}},
+			Final:   State{PC: 0x0102, A: 0x00, F: 0x80},
+		},
+		{
+			Name:    "CB 0F RRC A zero result",
+			Initial: State{PC: 0x0100, A: 0x00, F: 0x00, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x0F}}},
+			Final:   State{PC: 0x0102, A: 0x00, F: 0x80},
+		},
+		{
+			Name:    "CB 17 RL A zero result",
+			Initial: State{PC: 0x0100, A: 0x80, F: 0x00, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x17}}},
+			Final:   State{PC: 0x0102, A: 0x00, F: 0x90},
+		},
+		{
+			Name:    "CB 1F RR A zero result",
+			Initial: State{PC: 0x0100, A: 0x01, F: 0x00, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x1F}}},
+			Final:   State{PC: 0x0102, A: 0x00, F: 0x90},
+		},
+	}
+
+	for _, tc := range tests {
+		runVector(t, tc)
+	}
+}
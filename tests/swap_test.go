@@ -0,0 +1,93 @@
+package tests
+
+import "testing"
+
+// SWAP exchanges the nibbles and leaves Z as the only flag that may be set.
+func TestSWAP_Flags(t *testing.T) {
+	tests := []SM83Test{
+		{
+			Name:    "CB 30 SWAP B 0xAB",
+			Initial: State{PC: 0x0100, B: 0xAB, F: 0xF0, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x30}}},
+			Final:   State{PC: 0x0102, B: 0xBA, F: 0x00},
+		},
+		{
+			Name:    "CB 30 SWAP B zero result",
+			Initial: State{PC: 0x0100, B: 0x00, F: 0x70, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x30}}},
+			Final:   State{PC: 0x0102, B: 0x00, F: 0x80},
+		},
+		{
+			Name:    "CB 31 SWAP C 0xAB",
+			Initial: State{PC: 0x0100, C: 0xAB, F: 0xF0, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x31}}},
+			Final:   State{PC: 0x0102, C: 0xBA, F: 0x00},
+		},
+		{
+			Name:    "CB 31 SWAP C zero result",
+			Initial: State{PC: 0x0100, C: 0x00, F: 0x70, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x31}}},
+			Final:   State{PC: 0x0102, C: 0x00, F: 0x80},
+		},
+		{
+			Name:    "CB 32 SWAP D 0xAB",
+			Initial: State{PC: 0x0100, D: 0xAB, F: 0xF0, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x32}}},
+			Final:   State{PC: 0x0102, D: 0xBA, F: 0x00},
+		},
+		{
+			Name:    "CB 32 SWAP D zero result",
+			Initial: State{PC: 0x0100, D: 0x00, F: 0x70, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x32}}},
+			Final:   State{PC: 0x0102, D: 0x00, F: 0x80},
+		},
+		{
+			Name:    "CB 33 SWAP E 0xAB",
+			Initial: State{PC: 0x0100, E: 0xAB, F: 0xF0, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x33}}},
+			Final:   State{PC: 0x0102, E: 0xBA, F: 0x00},
+		},
+		{
+			Name:    "CB 33 SWAP E zero result",
+			Initial: State{PC: 0x0100, E: 0x00, F: 0x70, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x33}}},
+			Final:   State{PC: 0x0102, E: 0x00, F: 0x80},
+		},
+		{
+			Name:    "CB 34 SWAP H 0xAB",
+			Initial: State{PC: 0x0100, H: 0xAB, F: 0xF0, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x34}}},
+			Final:   State{PC: 0x0102, H: 0xBA, F: 0x00},
+		},
+		{
+			Name:    "CB 34 SWAP H zero result",
+			Initial: State{PC: 0x0100, H: 0x00, F: 0x70, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x34}}},
+			Final:   State{PC: 0x0102, H: 0x00, F: 0x80},
+		},
+		{
+			Name:    "CB 35 SWAP L 0xAB",
+			Initial: State{PC: 0x0100, L: 0xAB, F: 0xF0, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x35}}},
+			Final:   State{PC: 0x0102, L: 0xBA, F: 0x00},
+		},
+		{
+			Name:    "CB 35 SWAP L zero result",
+			Initial: State{PC: 0x0100, L: 0x00, F: 0x70, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x35}}},
+			Final:   State{PC: 0x0102, L: 0x00, F: 0x80},
+		},
+		{
+			Name:    "CB 36 SWAP (HL) 0xAB",
+			Initial: State{PC: 0x0100, F: 0xF0, H: 0xC0, L: 0x00, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x36}, {0xC000, 0xAB}}},
+			Final:   State{PC: 0x0102, F: 0x00, H: 0xC0, L: 0x00, Ram: [][2]uint16{{0xC000, 0xBA}}},
+		},
+		{
+			Name:    "CB 36 SWAP (HL) zero result",
+			Initial: State{PC: 0x0100, F: 0x70, H: 0xC0, L: 0x00, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x36}, {0xC000, 0x00}}},
+			Final:   State{PC: 0x0102, F: 0x80, H: 0xC0, L: 0x00, Ram: [][2]uint16{{0xC000, 0x00}}},
+		},
+		{
+			Name:    "CB 37 SWAP A 0xAB",
+			Initial: State{PC: 0x0100, A: 0xAB, F: 0xF0, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x37}}},
+			Final:   State{PC: 0x0102, A: 0xBA, F: 0x00},
+		},
+		{
+			Name:    "CB 37 SWAP A zero result",
+			Initial: State{PC: 0x0100, A: 0x00, F: 0x70, Ram: [][2]uint16{{0x0100, 0xCB}, {0x0101, 0x37}}},
+			Final:   State{PC: 0x0102, A: 0x00, F: 0x80},
+		},
+	}
+
+	for _, tc := range tests {
+		runVector(t, tc)
+	}
+}
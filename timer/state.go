@@ -0,0 +1,29 @@
+package timer
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+type state struct {
+	Counter        uint16
+	TIMA, TMA, TAC byte
+	Reload         int
+}
+
+func (t *Timer) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state{
+		Counter: t.counter, TIMA: t.tima, TMA: t.tma, TAC: t.tac, Reload: t.reload,
+	})
+	return buf.Bytes(), err
+}
+
+func (t *Timer) UnmarshalBinary(data []byte) error {
+	var s state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	t.counter, t.tima, t.tma, t.tac, t.reload = s.Counter, s.TIMA, s.TMA, s.TAC, s.Reload
+	return nil
+}
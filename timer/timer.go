@@ -0,0 +1,113 @@
+package timer
+
+import (
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+const (
+	ADDR_DIV  uint16 = 0xFF04
+	ADDR_TIMA uint16 = 0xFF05
+	ADDR_TMA  uint16 = 0xFF06
+	ADDR_TAC  uint16 = 0xFF07
+
+	TAC_ENABLE byte = 0x04
+
+	// cycles between TIMA overflowing and TMA being loaded into it
+	RELOAD_CYCLES = 4
+)
+
+// the bit of the internal counter whose falling edge increments TIMA, for
+// each TAC clock select: 4096Hz, 262144Hz, 65536Hz, 16384Hz
+var tacBits = [4]uint{9, 3, 5, 7}
+
+// Timer is DIV and TIMA. Both are driven by a 16-bit counter incremented
+// every cycle: DIV is its upper byte, and TIMA increments when the bit
+// selected by TAC falls, so anything resetting the counter also moves the
+// next TIMA increment.
+type Timer struct {
+	mem *mmu.Memory
+
+	counter        uint16
+	tima, tma, tac byte
+	// cycles until TMA is reloaded after an overflow, 0 when none is due
+	reload int
+}
+
+func New(mem *mmu.Memory) *Timer {
+	t := &Timer{mem: mem}
+	mem.MapIO(ADDR_DIV, t.DIV, t.writeDIV)
+	mem.MapIO(ADDR_TIMA, func() byte { return t.tima }, t.writeTIMA)
+	mem.MapIO(ADDR_TMA, func() byte { return t.tma }, func(v byte) { t.tma = v })
+	mem.MapIO(ADDR_TAC, func() byte { return t.tac | 0xF8 }, t.writeTAC)
+	return t
+}
+
+// DIV returns the upper byte of the internal counter.
+func (t *Timer) DIV() byte {
+	return byte(t.counter >> 8)
+}
+
+// Counter returns the 16-bit internal counter.
+func (t *Timer) Counter() uint16 {
+	return t.counter
+}
+
+// SetCounter sets the internal counter without the side effects of a DIV
+// write, e.g. to where the boot ROM leaves it.
+func (t *Timer) SetCounter(counter uint16) {
+	t.counter = counter
+}
+
+// signal is the input of the TIMA falling edge detector.
+func (t *Timer) signal() bool {
+	return t.tac&TAC_ENABLE != 0 && t.counter>>tacBits[t.tac&0x03]&1 != 0
+}
+
+// writeDIV zeroes the whole internal counter, whatever the value. If the
+// selected bit was set, that is a falling edge and TIMA increments.
+func (t *Timer) writeDIV(byte) {
+	high := t.signal()
+	t.counter = 0
+	if high {
+		t.increment()
+	}
+}
+
+func (t *Timer) writeTIMA(value byte) {
+	// a write during the reload delay cancels the reload
+	t.tima, t.reload = value, 0
+}
+
+func (t *Timer) writeTAC(value byte) {
+	high := t.signal()
+	t.tac = value & 0x07
+	if high && !t.signal() {
+		t.increment()
+	}
+}
+
+func (t *Timer) increment() {
+	t.tima++
+	if t.tima == 0 {
+		t.reload = RELOAD_CYCLES
+	}
+}
+
+// Step advances the counter by the given cycles.
+func (t *Timer) Step(cycles int) {
+	for range cycles {
+		if t.reload > 0 {
+			t.reload--
+			if t.reload == 0 {
+				t.tima = t.tma
+				cpu.RequestInterrupt(t.mem, cpu.INT_TIMER)
+			}
+		}
+		high := t.signal()
+		t.counter++
+		if high && !t.signal() {
+			t.increment()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package timer
+
+import (
+	"testing"
+
+	"github.com/duyquang6/go-retroid/cpu"
+	"github.com/duyquang6/go-retroid/mmu"
+)
+
+func TestTimer_DIVWriteResetsCounter(t *testing.T) {
+	mem := mmu.New()
+	tm := New(mem)
+	// TIMA at 65536Hz: increments when counter bit 5 falls, every 64 cycles
+	mem.Write(ADDR_TAC, TAC_ENABLE|0x02)
+
+	tm.Step(0x1234)
+	if got := mem.Read(ADDR_DIV); got != 0x12 {
+		t.Fatalf("DIV = 0x%02X, want 0x12", got)
+	}
+	tima := mem.Read(ADDR_TIMA)
+
+	// counter 0x1234 has bit 5 set: the reset is a falling edge
+	mem.Write(ADDR_DIV, 0x5A)
+	if got := mem.Read(ADDR_DIV); got != 0 {
+		t.Errorf("DIV after write = 0x%02X, want 0", got)
+	}
+	if got := tm.Counter(); got != 0 {
+		t.Errorf("counter after DIV write = 0x%04X, want 0", got)
+	}
+	if got := mem.Read(ADDR_TIMA); got != tima+1 {
+		t.Errorf("TIMA after DIV write = %d, want %d", got, tima+1)
+	}
+
+	// the low bits were cleared too, so the next increment is a full
+	// period away instead of the 12 cycles left before the write
+	tm.Step(63)
+	if got := mem.Read(ADDR_TIMA); got != tima+1 {
+		t.Errorf("TIMA 63 cycles after DIV write = %d, want %d", got, tima+1)
+	}
+	tm.Step(1)
+	if got := mem.Read(ADDR_TIMA); got != tima+2 {
+		t.Errorf("TIMA 64 cycles after DIV write = %d, want %d", got, tima+2)
+	}
+}
+
+func TestTimer_DIVWriteNoEdge(t *testing.T) {
+	mem := mmu.New()
+	tm := New(mem)
+	mem.Write(ADDR_TAC, TAC_ENABLE|0x02)
+
+	// bit 5 clear: no falling edge, TIMA is left alone
+	tm.Step(0x1210)
+	tima := mem.Read(ADDR_TIMA)
+	mem.Write(ADDR_DIV, 0)
+	if got := mem.Read(ADDR_TIMA); got != tima {
+		t.Errorf("TIMA after DIV write = %d, want %d", got, tima)
+	}
+}
+
+func TestTimer_Overflow(t *testing.T) {
+	mem := mmu.New()
+	tm := New(mem)
+	mem.Write(ADDR_TMA, 0xF0)
+	mem.Write(ADDR_TIMA, 0xFF)
+	mem.Write(ADDR_TAC, TAC_ENABLE|0x01) // every 16 cycles
+
+	tm.Step(16)
+	if got := mem.Read(ADDR_TIMA); got != 0 {
+		t.Errorf("TIMA right after overflow = 0x%02X, want 0", got)
+	}
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_TIMER != 0 {
+		t.Error("timer interrupt requested before the reload")
+	}
+	tm.Step(RELOAD_CYCLES)
+	if got := mem.Read(ADDR_TIMA); got != 0xF0 {
+		t.Errorf("TIMA after reload = 0x%02X, want TMA 0xF0", got)
+	}
+	if mem.Read(cpu.ADDR_IF)&cpu.INT_TIMER == 0 {
+		t.Error("no timer interrupt after the reload")
+	}
+}